@@ -0,0 +1,76 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sso-go/dao"
+	"sso-go/utils"
+	"strings"
+)
+
+// 客户端管理命令
+// ./ssoService client create -name 业务系统 -redirect_uris "https://a.com/cb https://b.com/cb" -scopes "openid profile" [-public] [-org slug] [-public_key key.pem] [-first_party]
+// 只用客户端凭证换取服务token的后台服务可以不传redirect_uris
+func clientCommand(args []string) {
+	if len(args) == 0 {
+		exit("usage: client create -name <name> [-redirect_uris <uris>] [-scopes <scopes>] [-public] [-org <slug>] [-public_key <pem file>] [-first_party]")
+	}
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("client create", flag.ExitOnError)
+		name := fs.String("name", "", "业务系统名称")
+		redirectUris := fs.String("redirect_uris", "", "允许的回调地址，多个用空格分隔")
+		scopes := fs.String("scopes", "", "允许申请的scope，多个用空格分隔")
+		public := fs.Bool("public", false, "公开客户端（SPA、移动端），不生成密钥，必须使用PKCE")
+		orgSlug := fs.String("org", "", "所属组织的标识，指定后只有该组织的成员可以授权")
+		publicKeyFile := fs.String("public_key", "", "PEM格式的公钥文件，指定后客户端用私钥签名的JWT认证，不生成密钥")
+		firstParty := fs.Bool("first_party", false, "自家业务系统，用户授权时不需要确认")
+		_ = fs.Parse(args[1:])
+		if *name == "" {
+			exit("name不得为空")
+		}
+		if *public && strings.TrimSpace(*redirectUris) == "" {
+			exit("公开客户端的redirect_uris不得为空")
+		}
+		if *public && *publicKeyFile != "" {
+			exit("公开客户端不能配置公钥")
+		}
+		publicKey := ""
+		if *publicKeyFile != "" {
+			data, err := os.ReadFile(*publicKeyFile)
+			if err != nil {
+				exit("读取公钥失败：%s", err.Error())
+			}
+			if _, err := utils.ParseClientPublicKey(string(data)); err != nil {
+				exit("公钥无效：%s", err.Error())
+			}
+			publicKey = string(data)
+		}
+		var orgID uint
+		if *orgSlug != "" {
+			org, ok := dao.GetOrgBySlug(*orgSlug)
+			if !ok {
+				exit("组织不存在：%s", *orgSlug)
+			}
+			orgID = org.ID
+		}
+		client, secret, err := dao.CreateClient(*name, strings.Join(strings.Fields(*redirectUris), " "), strings.Join(strings.Fields(*scopes), " "), *public, orgID, publicKey, *firstParty)
+		if err != nil {
+			exit("创建客户端失败：%s", err.Error())
+		}
+		fmt.Printf("client_id:     %s\n", client.ClientID)
+		if *public {
+			fmt.Println("公开客户端没有client_secret，换取token时必须使用PKCE")
+			return
+		}
+		if publicKey != "" {
+			fmt.Println("客户端使用私钥签名的client_assertion认证，没有client_secret")
+			return
+		}
+		fmt.Printf("client_secret: %s\n", secret)
+		fmt.Println("请妥善保存client_secret，它不会再次显示")
+	default:
+		exit("未知的子命令：client %s", args[0])
+	}
+}
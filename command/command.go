@@ -0,0 +1,30 @@
+package command
+
+import (
+	"fmt"
+	"os"
+)
+
+// Run 执行命令行子命令，没有匹配到子命令时返回false，继续启动服务
+func Run(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "client":
+		clientCommand(args[1:])
+	case "key":
+		keyCommand(args[1:])
+	case "scim":
+		scimCommand(args[1:])
+	default:
+		return false
+	}
+	return true
+}
+
+// 打印错误并退出
+func exit(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", a...)
+	os.Exit(1)
+}
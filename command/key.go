@@ -0,0 +1,164 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sso-go/global"
+	"sso-go/middlewares"
+	"sso-go/utils"
+	"time"
+)
+
+// 签名密钥轮换命令，需要在env.toml的[jwt]中配置keyring
+// ./ssoService key list
+// ./ssoService key generate -alg ES256 [-activate_in 24h]
+// ./ssoService key promote -kid <kid>
+// ./ssoService key retire -kid <kid>
+func keyCommand(args []string) {
+	if len(args) == 0 {
+		exit("usage: key list | generate -alg <alg> [-activate_in <duration>] | promote -kid <kid> | retire -kid <kid>")
+	}
+	path := global.Settings.JWTKey.Keyring
+	if path == "" {
+		exit("请先在env.toml的[jwt]中配置keyring")
+	}
+	file, err := loadKeyringFile(path)
+	if err != nil {
+		exit("读取密钥环失败：%s", err.Error())
+	}
+	now := time.Now()
+
+	switch args[0] {
+	case "list":
+		for _, entry := range file.Keys {
+			retireAt := "-"
+			if entry.RetireAt != nil {
+				retireAt = entry.RetireAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%-24s %-6s %-10s active_at=%s retire_at=%s\n", entry.Kid, entry.Alg, keyStatus(entry, now), entry.ActiveAt.Format(time.RFC3339), retireAt)
+		}
+		return
+	case "generate":
+		fs := flag.NewFlagSet("key generate", flag.ExitOnError)
+		alg := fs.String("alg", "ES256", "签名算法：RS256、ES256、EdDSA等")
+		activateIn := fs.Duration("activate_in", 24*time.Hour, "多久后启用，预留时间让业务系统刷新JWKS缓存")
+		_ = fs.Parse(args[1:])
+		pemBytes, err := middlewares.GenerateKeyPEM(*alg)
+		if err != nil {
+			exit("%s", err.Error())
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			exit("创建密钥目录失败：%s", err.Error())
+		}
+		kid := fmt.Sprintf("%s-%s", now.Format("20060102"), utils.GenerateHexCode(4))
+		keyFile := kid + ".pem"
+		if err := os.WriteFile(filepath.Join(filepath.Dir(path), keyFile), pemBytes, 0600); err != nil {
+			exit("写入私钥失败：%s", err.Error())
+		}
+		file.Keys = append(file.Keys, middlewares.KeyringEntry{
+			Kid:        kid,
+			Alg:        *alg,
+			PrivateKey: keyFile,
+			ActiveAt:   now.Add(*activateIn),
+		})
+		fmt.Printf("已生成密钥 %s，将于 %s 启用\n", kid, now.Add(*activateIn).Format(time.RFC3339))
+	case "promote":
+		kid := parseKid("key promote", args[1:])
+		entry := findEntry(file, kid)
+		if entry == nil {
+			exit("密钥不存在：%s", kid)
+		}
+		// 立即启用新密钥，之前的签名密钥在token最长有效期后停用，已签发的token仍可验签
+		retireAt := now.Add(time.Duration(utils.AccessTokenExpireSeconds()) * time.Second)
+		for i := range file.Keys {
+			other := &file.Keys[i]
+			if other.Kid == kid || other.ActiveAt.After(now) {
+				continue
+			}
+			if other.RetireAt == nil || other.RetireAt.After(retireAt) {
+				other.RetireAt = &retireAt
+			}
+		}
+		entry.ActiveAt = now
+		entry.RetireAt = nil
+		fmt.Printf("已启用密钥 %s，旧密钥将于 %s 停用\n", kid, retireAt.Format(time.RFC3339))
+	case "retire":
+		kid := parseKid("key retire", args[1:])
+		entry := findEntry(file, kid)
+		if entry == nil {
+			exit("密钥不存在：%s", kid)
+		}
+		// 立即停用，用该密钥签发的token全部失效
+		entry.RetireAt = &now
+		if !hasSigningKey(file, now) {
+			exit("停用后将没有可用于签名的密钥，请先 generate 并 promote 新密钥")
+		}
+		fmt.Printf("已停用密钥 %s\n", kid)
+	default:
+		exit("未知的子命令：key %s", args[0])
+	}
+
+	if err := middlewares.WriteKeyringFile(path, file); err != nil {
+		exit("写入密钥环失败：%s", err.Error())
+	}
+	fmt.Println("服务会在一分钟内自动加载新的密钥环")
+}
+
+// 读取密钥环，第一次使用时把env.toml中配置的密钥作为初始密钥加入密钥环，保证已签发的token可以继续验签
+func loadKeyringFile(path string) (*middlewares.KeyringFile, error) {
+	file, err := middlewares.ReadKeyringFile(path)
+	if err != nil || len(file.Keys) > 0 {
+		return file, err
+	}
+	key, err := middlewares.ParseConfigKey(global.Settings.JWTKey)
+	if err != nil {
+		return nil, err
+	}
+	file.Keys = append(file.Keys, middlewares.KeyringEntry{
+		Kid: key.Kid,
+		Alg: key.Method.Alg(),
+	})
+	return file, nil
+}
+
+func parseKid(name string, args []string) string {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	kid := fs.String("kid", "", "密钥ID")
+	_ = fs.Parse(args)
+	if *kid == "" {
+		exit("kid不得为空")
+	}
+	return *kid
+}
+
+func findEntry(file *middlewares.KeyringFile, kid string) *middlewares.KeyringEntry {
+	for i := range file.Keys {
+		if file.Keys[i].Kid == kid {
+			return &file.Keys[i]
+		}
+	}
+	return nil
+}
+
+// 是否还有已启用、未停用的签名密钥
+func hasSigningKey(file *middlewares.KeyringFile, now time.Time) bool {
+	for _, entry := range file.Keys {
+		if keyStatus(entry, now) == "active" {
+			return true
+		}
+	}
+	return false
+}
+
+func keyStatus(entry middlewares.KeyringEntry, now time.Time) string {
+	switch {
+	case entry.RetireAt != nil && !now.Before(*entry.RetireAt):
+		return "retired"
+	case now.Before(entry.ActiveAt):
+		return "pending"
+	default:
+		return "active"
+	}
+}
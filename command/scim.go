@@ -0,0 +1,62 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"sso-go/dao"
+)
+
+// SCIM token管理命令
+// ./ssoService scim token create -name HR系统
+// ./ssoService scim token list
+// ./ssoService scim token delete -id 1
+func scimCommand(args []string) {
+	if len(args) < 2 || args[0] != "token" {
+		exit("usage: scim token create -name <name> | scim token list | scim token delete -id <id>")
+	}
+	switch args[1] {
+	case "create":
+		fs := flag.NewFlagSet("scim token create", flag.ExitOnError)
+		name := fs.String("name", "", "使用方名称，如 HR系统")
+		_ = fs.Parse(args[2:])
+		if *name == "" {
+			exit("name不得为空")
+		}
+		record, token, err := dao.CreateScimToken(*name)
+		if err != nil {
+			exit("创建SCIM token失败：%s", err.Error())
+		}
+		fmt.Printf("id:    %d\n", record.ID)
+		fmt.Printf("token: %s\n", token)
+		fmt.Println("请妥善保存token，它不会再次显示")
+	case "list":
+		tokens, err := dao.ListScimTokens()
+		if err != nil {
+			exit("查询SCIM token失败：%s", err.Error())
+		}
+		for _, t := range tokens {
+			lastUsed := "-"
+			if t.LastUsedAt != nil {
+				lastUsed = t.LastUsedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%d\t%s\t创建于 %s\t最后使用 %s\n", t.ID, t.Name, t.CreatedAt.Format("2006-01-02 15:04:05"), lastUsed)
+		}
+	case "delete":
+		fs := flag.NewFlagSet("scim token delete", flag.ExitOnError)
+		id := fs.Uint("id", 0, "token的id，通过 scim token list 查看")
+		_ = fs.Parse(args[2:])
+		if *id == 0 {
+			exit("id不得为空")
+		}
+		ok, err := dao.DeleteScimToken(*id)
+		if err != nil {
+			exit("删除SCIM token失败：%s", err.Error())
+		}
+		if !ok {
+			exit("SCIM token不存在：%d", *id)
+		}
+		fmt.Println("已删除")
+	default:
+		exit("未知的子命令：scim token %s", args[1])
+	}
+}
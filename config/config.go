@@ -1,13 +1,30 @@
 package config
 
 type ServerConfig struct {
-	Name        string      `mapstructure:"appName"`
-	Port        int         `mapstructure:"port"`
-	MysqlInfo   MysqlConfig `mapstructure:"mysql"`
-	RedisInfo   RedisConfig `mapstructure:"redis"`
-	EmailInfo   EmailConfig `mapstructure:"email"`
-	LogsAddress string      `mapstructure:"logsAddress"`
-	JWTKey      JWTConfig   `mapstructure:"jwt"`
+	Name        string         `mapstructure:"appName"`
+	Port        int            `mapstructure:"port"`
+	Issuer      string         `mapstructure:"issuer"`
+	MysqlInfo   MysqlConfig    `mapstructure:"mysql"`
+	RedisInfo   RedisConfig    `mapstructure:"redis"`
+	EmailInfo   EmailConfig    `mapstructure:"email"`
+	LogsAddress string         `mapstructure:"logsAddress"`
+	JWTKey      JWTConfig      `mapstructure:"jwt"`
+	MfaInfo     MfaConfig      `mapstructure:"mfa"`
+	WebAuthn    WebAuthnConfig `mapstructure:"webauthn"`
+	Security    SecurityConfig `mapstructure:"security"`
+	// 回调地址通配规则
+	RedirectRules []RedirectRule `mapstructure:"redirectRules"`
+	// 前端重置密码页面，重置密码邮件中的链接会带上token参数
+	ResetPasswordUrl string `mapstructure:"resetPasswordUrl"`
+	// 前端设备授权页面，用户在这里输入CLI、电视等设备上显示的user_code
+	DeviceVerificationUrl string `mapstructure:"deviceVerificationUrl"`
+	// 前端登录页面，浏览器访问授权接口时没有登录会跳转到这里，并带上登录后返回的return_to
+	LoginUrl string `mapstructure:"loginUrl"`
+	// 前端授权确认页面，浏览器访问授权接口时需要确认授权会跳转到这里，并带上return_to
+	ConsentUrl string        `mapstructure:"consentUrl"`
+	Session    SessionConfig `mapstructure:"session"`
+	Storage    StorageConfig `mapstructure:"storage"`
+	Avatar     AvatarConfig  `mapstructure:"avatar"`
 }
 
 type MysqlConfig struct {
@@ -32,6 +49,62 @@ type EmailConfig struct {
 	Password  string `mapstructure:"password"`
 }
 
+type MfaConfig struct {
+	Issuer        string   `mapstructure:"issuer"`        // 验证器App中显示的名称，默认使用appName
+	RequiredUsers []string `mapstructure:"requiredUsers"` // 必须开启两步验证的账号（用户名或邮箱），如内部管理员
+}
+
+type WebAuthnConfig struct {
+	RPID    string   `mapstructure:"rpId"`    // 依赖方ID，SSO登录页的域名，如 account.djp.org.cn，为空表示不开启WebAuthn
+	RPName  string   `mapstructure:"rpName"`  // 认证器上显示的名称，默认使用appName
+	Origins []string `mapstructure:"origins"` // 允许发起WebAuthn的页面地址，如 https://account.djp.org.cn
+}
+
+type SecurityConfig struct {
+	MaxAccountFailures int64    `mapstructure:"maxAccountFailures"` // 账号连续登录失败多少次后锁定，默认5
+	MaxIPFailures      int64    `mapstructure:"maxIpFailures"`      // 同一IP登录失败多少次后锁定，默认20
+	LockoutSeconds     int64    `mapstructure:"lockoutSeconds"`     // 首次锁定时长（秒），之后每失败一次翻倍，默认300
+	MaxLockoutSeconds  int64    `mapstructure:"maxLockoutSeconds"`  // 锁定时长上限（秒），默认86400
+	FailureWindow      int64    `mapstructure:"failureWindow"`      // 失败次数统计窗口（秒），默认900
+	TrustedProxies     []string `mapstructure:"trustedProxies"`     // 前置代理的IP或网段，配置后只信任这些代理传递的X-Forwarded-For
+	EmailCodeCooldown  int64    `mapstructure:"emailCodeCooldown"`  // 同一邮箱两次发送验证码的最小间隔（秒），默认60
+	EmailDailyLimit    int64    `mapstructure:"emailDailyLimit"`    // 同一邮箱每天最多发送验证码次数，默认10
+	IPEmailDailyLimit  int64    `mapstructure:"ipEmailDailyLimit"`  // 同一IP每天最多发送验证码次数，默认50
+	EmailCodeAttempts  int64    `mapstructure:"emailCodeAttempts"`  // 验证码最多输错次数，达到后作废，默认5
+	Admins             []string `mapstructure:"admins"`             // 管理员账号的邮箱（必须已验证），可以调用 /v1/admin 接口，必须开启两步验证
+}
+
+type SessionConfig struct {
+	CookieName string `mapstructure:"cookieName"` // 会话cookie名称，默认sso_session
+	Domain     string `mapstructure:"domain"`     // cookie的域名，不填则只发送给SSO服务自身的域名
+	MaxAge     int64  `mapstructure:"maxAge"`     // 会话有效期（秒），默认与refresh_token相同
+	SameSite   string `mapstructure:"sameSite"`   // lax、strict或none，默认lax；前端与SSO服务跨站时需要none
+	Insecure   bool   `mapstructure:"insecure"`   // 本地http调试时设为true，cookie不带Secure
+}
+
+type StorageConfig struct {
+	Driver   string `mapstructure:"driver"`   // 存储后端，目前支持local，默认local
+	LocalDir string `mapstructure:"localDir"` // local：文件保存目录，默认./uploads
+	BaseURL  string `mapstructure:"baseUrl"`  // 上传文件对外访问的地址前缀，默认issuer加/uploads；local时地址的路径部分同时作为静态文件路由
+}
+
+type AvatarConfig struct {
+	Default string `mapstructure:"default"` // 没有上传头像时使用的默认头像
+	MaxSize int64  `mapstructure:"maxSize"` // 上传图片大小上限（字节），默认2MB
+	Sizes   []int  `mapstructure:"sizes"`   // 生成的缩略图边长（像素），第一个作为head_url，默认[256, 64]
+}
+
+type RedirectRule struct {
+	ClientID string   `mapstructure:"clientId"` // 为空表示对所有客户端生效
+	Patterns []string `mapstructure:"patterns"` // 如 https://*.example.com/，*.只匹配子域名
+}
+
 type JWTConfig struct {
-	SigningKey string `mapstructure:"key"`
+	SigningKey string `mapstructure:"key"`        // HS256的共享密钥
+	Algorithm  string `mapstructure:"alg"`        // 签名算法：HS256、RS256、ES256、EdDSA等，默认HS256
+	PrivateKey string `mapstructure:"privateKey"` // 非对称算法的私钥PEM文件路径
+	Kid        string `mapstructure:"kid"`        // 密钥ID，不填则使用公钥指纹
+	Keyring    string `mapstructure:"keyring"`    // 密钥环文件路径，存在时忽略上面的单个密钥配置
+	AccessTTL  int64  `mapstructure:"accessTTL"`  // access_token有效期（秒），默认15分钟
+	RefreshTTL int64  `mapstructure:"refreshTTL"` // refresh_token有效期（秒），默认30天
 }
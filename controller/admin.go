@@ -0,0 +1,342 @@
+package controller
+
+import (
+	"net/http"
+	"sso-go/dao"
+	"sso-go/forms"
+	"sso-go/global"
+	"sso-go/middlewares"
+	"sso-go/model"
+	"sso-go/response"
+	"sso-go/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 解除账号或IP的登录锁定
+func UnlockLogin(c *gin.Context) {
+	unlockParams := forms.UnlockLoginForm{}
+	if err := c.ShouldBind(&unlockParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	if unlockParams.Account != "" {
+		dao.ClearLoginFailures(dao.LoginAccountKey(unlockParams.Account))
+	}
+	if unlockParams.IP != "" {
+		dao.UnlockLoginIP(unlockParams.IP)
+	}
+	global.Lg.Info("UnlockLogin", zap.Any("admin", c.GetUint("userId")), zap.Any("account", unlockParams.Account), zap.Any("ip", unlockParams.IP))
+	response.Success(c, 200, "success", nil)
+}
+
+// 管理接口返回的用户信息，不包含密码
+func adminUserMap(user *model.User) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                user.ID,
+		"username":          user.Name,
+		"email":             user.Email,
+		"head_url":          utils.UserHeadUrl(user),
+		"email_verified_at": user.EmailVerifiedAt,
+		"disabled":          user.Disabled(),
+		"disabled_at":       user.DisabledAt,
+		"created_at":        user.CreatedAt,
+		"updated_at":        user.UpdatedAt,
+	}
+}
+
+// 校验用户名、邮箱没有被其他账号使用，excludeID为当前修改的用户
+func checkUserUnique(c *gin.Context, name string, email string, excludeID uint) bool {
+	if name != "" {
+		// 用户名也用来登录，不能是邮箱格式
+		if utils.IsEmail(name) {
+			response.Err(c, http.StatusOK, 400, "用户名不能是邮箱", nil)
+			return false
+		}
+		if other, ok := dao.GetUserByAccount(name); ok && other.ID != excludeID {
+			response.Err(c, http.StatusOK, 400, "该昵称已注册", nil)
+			return false
+		}
+	}
+	if email != "" {
+		if other, ok := dao.GetUserByEmail(email); ok && other.ID != excludeID {
+			response.Err(c, http.StatusOK, 400, "该邮箱已注册", nil)
+			return false
+		}
+		// 管理员按邮箱识别，不能通过管理接口把其他账号的邮箱设为管理员邮箱
+		if middlewares.IsAdminEmail(email) && !isCurrentAdmin(c) {
+			response.Err(c, http.StatusOK, 403, "不能使用管理员邮箱", nil)
+			return false
+		}
+	}
+	return true
+}
+
+// 当前token是否为env.toml中配置的管理员
+func isCurrentAdmin(c *gin.Context) bool {
+	claims, ok := getClaims(c)
+	return ok && middlewares.IsAdmin(claims)
+}
+
+// 取要操作的用户，不能对自己执行禁用、删除等操作；token选择了组织时只能操作该组织的成员
+func adminTargetUser(c *gin.Context, id uint, allowSelf bool) (*model.User, bool) {
+	if !allowSelf && id == c.GetUint("userId") {
+		response.Err(c, http.StatusOK, 400, "不能对自己的账号执行该操作", nil)
+		return nil, false
+	}
+	user, ok := dao.GetUserByID(id)
+	if ok {
+		if orgID := currentOrgID(c); orgID != 0 {
+			_, ok = dao.GetOrgMember(orgID, id)
+		}
+	}
+	if !ok {
+		response.Err(c, http.StatusOK, 404, "用户不存在", nil)
+		return nil, false
+	}
+	// env.toml中配置的管理员只能由管理员自己操作
+	if middlewares.IsAdminUser(user.ID) && !isCurrentAdmin(c) {
+		response.Err(c, http.StatusOK, 403, "没有权限", nil)
+		return nil, false
+	}
+	return user, true
+}
+
+// 用户列表，支持按用户名、邮箱、注册日期搜索，按ID倒序游标分页；token选择了组织时只返回该组织的成员
+func ListUsers(c *gin.Context) {
+	listParams := forms.UserListForm{}
+	if err := c.ShouldBindQuery(&listParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	query := dao.UserQuery{
+		Keyword:  strings.TrimSpace(listParams.Keyword),
+		Name:     listParams.Name,
+		Email:    listParams.Email,
+		Disabled: listParams.Disabled,
+		OrgID:    currentOrgID(c),
+		Cursor:   listParams.Cursor,
+		Limit:    listParams.Limit,
+	}
+	if query.Limit == 0 {
+		query.Limit = 20
+	}
+	if listParams.CreatedFrom != "" {
+		from, _ := time.ParseInLocation("2006-01-02", listParams.CreatedFrom, time.Local)
+		query.CreatedFrom = &from
+	}
+	if listParams.CreatedTo != "" {
+		to, _ := time.ParseInLocation("2006-01-02", listParams.CreatedTo, time.Local)
+		to = to.AddDate(0, 0, 1)
+		query.CreatedTo = &to
+	}
+	// 多查一条判断是否还有下一页
+	pageSize := query.Limit
+	query.Limit++
+	users, err := dao.ListUsers(query)
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "查询失败", err.Error())
+		return
+	}
+	var nextCursor uint
+	if len(users) > pageSize {
+		users = users[:pageSize]
+		nextCursor = users[pageSize-1].ID
+	}
+	list := make([]map[string]interface{}, 0, len(users))
+	for i := range users {
+		list = append(list, adminUserMap(&users[i]))
+	}
+	response.Success(c, 200, "success", map[string]interface{}{
+		"list":        list,
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor > 0,
+	})
+}
+
+// 用户详情，包含两步验证状态
+func GetUser(c *gin.Context) {
+	idParams := forms.UserIDForm{}
+	if err := c.ShouldBindQuery(&idParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	user, ok := adminTargetUser(c, idParams.ID, true)
+	if !ok {
+		return
+	}
+	data := adminUserMap(user)
+	data["totp_enabled"] = totpEnabled(user.ID)
+	data["webauthn_credentials"] = len(dao.GetWebauthnCredentials(user.ID))
+	response.Success(c, 200, "success", data)
+}
+
+// 创建用户，管理员创建的账号邮箱视为已验证
+func CreateUser(c *gin.Context) {
+	createParams := forms.AdminCreateUserForm{}
+	if err := c.ShouldBind(&createParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	name := strings.TrimSpace(createParams.Username)
+	if !checkUserUnique(c, name, createParams.Email, 0) {
+		return
+	}
+	user := model.User{
+		Name:            name,
+		Email:           createParams.Email,
+		EmailVerifiedAt: utils.GetNowFormatTime(),
+		Password:        utils.HashAndSalt(createParams.PassWord),
+	}
+	if err := dao.CreateUser(&user); err != nil {
+		response.Err(c, http.StatusOK, 500, "创建失败", err.Error())
+		return
+	}
+	// 在组织内创建的用户自动加入该组织
+	if orgID := currentOrgID(c); orgID != 0 {
+		if err := dao.SaveOrgMember(orgID, user.ID, model.OrgRoleMember); err != nil {
+			response.Err(c, http.StatusOK, 500, "加入组织失败", err.Error())
+			return
+		}
+	}
+	global.Lg.Info("AdminCreateUser", zap.Any("admin", c.GetUint("userId")), zap.Any("user_id", user.ID))
+	response.Success(c, 200, "success", adminUserMap(&user))
+}
+
+// 修改用户名、邮箱、密码，修改邮箱或密码后该用户所有登录失效
+func UpdateUser(c *gin.Context) {
+	updateParams := forms.AdminUpdateUserForm{}
+	if err := c.ShouldBind(&updateParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	user, ok := adminTargetUser(c, updateParams.ID, true)
+	if !ok {
+		return
+	}
+	name := strings.TrimSpace(updateParams.Username)
+	if !checkUserUnique(c, name, updateParams.Email, user.ID) {
+		return
+	}
+	fields := map[string]interface{}{}
+	if name != "" && name != user.Name {
+		fields["name"] = name
+	}
+	if updateParams.Email != "" && updateParams.Email != user.Email {
+		fields["email"] = updateParams.Email
+		fields["email_verified_at"] = utils.GetNowFormatTime()
+	}
+	if updateParams.PassWord != "" {
+		fields["password"] = utils.HashAndSalt(updateParams.PassWord)
+	}
+	if len(fields) == 0 {
+		response.Success(c, 200, "success", adminUserMap(user))
+		return
+	}
+	if err := dao.UpdateUserProfile(user.ID, fields); err != nil {
+		response.Err(c, http.StatusOK, 500, "修改失败", err.Error())
+		return
+	}
+	_, emailChanged := fields["email"]
+	_, passwordChanged := fields["password"]
+	if emailChanged || passwordChanged {
+		revokeUserSessions(user.ID)
+	}
+	global.Lg.Info("AdminUpdateUser", zap.Any("admin", c.GetUint("userId")), zap.Any("user_id", user.ID), zap.Bool("email", emailChanged), zap.Bool("password", passwordChanged))
+	user, _ = dao.GetUserByID(user.ID)
+	response.Success(c, 200, "success", adminUserMap(user))
+}
+
+// 禁用账号并作废所有登录，已签发的access_token立即不能再使用
+func disableUser(user *model.User) error {
+	if !user.Disabled() {
+		now := time.Now()
+		if err := dao.SetUserDisabledAt(user.ID, &now); err != nil {
+			return err
+		}
+		user.DisabledAt = &now
+	}
+	revokeUserSessions(user.ID)
+	middlewares.SetUserDisabled(user.ID, time.Duration(utils.AccessTokenExpireSeconds())*time.Second)
+	return nil
+}
+
+// 恢复被禁用的账号
+func enableUser(user *model.User) error {
+	if err := dao.SetUserDisabledAt(user.ID, nil); err != nil {
+		return err
+	}
+	user.DisabledAt = nil
+	middlewares.ClearUserDisabled(user.ID)
+	return nil
+}
+
+// 删除账号及关联数据，作废所有登录
+func deleteUser(user *model.User) error {
+	if err := dao.DeleteUser(user.ID); err != nil {
+		return err
+	}
+	revokeUserSessions(user.ID)
+	deleteAvatarFiles(user.HeadUrl)
+	return nil
+}
+
+// 禁用账号，已签发的token立即失效，不能再登录
+func DisableUser(c *gin.Context) {
+	idParams := forms.UserIDForm{}
+	if err := c.ShouldBind(&idParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	user, ok := adminTargetUser(c, idParams.ID, false)
+	if !ok {
+		return
+	}
+	if err := disableUser(user); err != nil {
+		response.Err(c, http.StatusOK, 500, "禁用失败", err.Error())
+		return
+	}
+	global.Lg.Info("AdminDisableUser", zap.Any("admin", c.GetUint("userId")), zap.Any("user_id", user.ID))
+	response.Success(c, 200, "success", adminUserMap(user))
+}
+
+// 恢复被禁用的账号，需要重新登录
+func EnableUser(c *gin.Context) {
+	idParams := forms.UserIDForm{}
+	if err := c.ShouldBind(&idParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	user, ok := adminTargetUser(c, idParams.ID, false)
+	if !ok {
+		return
+	}
+	if err := enableUser(user); err != nil {
+		response.Err(c, http.StatusOK, 500, "恢复失败", err.Error())
+		return
+	}
+	global.Lg.Info("AdminEnableUser", zap.Any("admin", c.GetUint("userId")), zap.Any("user_id", user.ID))
+	response.Success(c, 200, "success", adminUserMap(user))
+}
+
+// 删除用户，同时删除两步验证、WebAuthn凭证和头像，所有登录失效
+func DeleteUser(c *gin.Context) {
+	idParams := forms.UserIDForm{}
+	if err := c.ShouldBind(&idParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	user, ok := adminTargetUser(c, idParams.ID, false)
+	if !ok {
+		return
+	}
+	if err := deleteUser(user); err != nil {
+		response.Err(c, http.StatusOK, 500, "删除失败", err.Error())
+		return
+	}
+	global.Lg.Info("AdminDeleteUser", zap.Any("admin", c.GetUint("userId")), zap.Any("user_id", user.ID), zap.String("email", user.Email))
+	response.Success(c, 200, "success", nil)
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"net/http"
+	"sso-go/dao"
+	"sso-go/forms"
+	"sso-go/global"
+	"sso-go/middlewares"
+	"sso-go/model"
+	"sso-go/response"
+	"sso-go/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 需要用户确认的scope：自家客户端不需要确认，第三方客户端只需要确认之前没有同意过的scope
+// 第二个返回值为true表示需要用户确认
+func pendingConsent(client *model.Client, userID uint, scope string) ([]string, bool) {
+	if client.FirstParty {
+		return nil, false
+	}
+	consent, ok := dao.GetConsent(userID, client.ClientID)
+	if !ok {
+		return strings.Fields(scope), true
+	}
+	missing := consent.Missing(scope)
+	return missing, len(missing) > 0
+}
+
+// 授权确认凭证有效期
+const consentTicketExpire = 10 * time.Minute
+
+// 返回需要用户确认授权，前端据此展示授权确认页面，同意时带上consent_ticket
+// 浏览器直接访问时跳转到前端授权确认页面，由前端重新请求获取凭证
+func consentRequired(c *gin.Context, client *model.Client, claims *middlewares.CustomClaims, scope string, missing []string) {
+	if redirectToFrontend(c, global.Settings.ConsentUrl) {
+		return
+	}
+	ticket, err := dao.SaveConsentTicket(&model.ConsentTicket{
+		UserID:    claims.ID,
+		SessionID: claims.SessionID,
+		ClientID:  client.ClientID,
+		Scope:     strings.Join(strings.Fields(scope), " "),
+	}, consentTicketExpire)
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "授权确认凭证生成失败", err.Error())
+		return
+	}
+	response.Err(c, http.StatusOK, 403, "consent_required", map[string]interface{}{
+		"client_id":      client.ClientID,
+		"client_name":    client.Name,
+		"scope":          scope,
+		"missing_scopes": missing,
+		"consent_ticket": ticket,
+		"expires_in":     int64(consentTicketExpire / time.Second),
+	})
+}
+
+// 用户是否确认了授权：只接受带SSO登录token的POST请求，并且必须带上签发给同一用户、会话、客户端和scope的凭证
+// 浏览器跳转（GET）或只带cookie的请求可以由客户端构造，签发给客户端的token由客户端持有，都不能用来确认授权
+func consentApproved(c *gin.Context, authorizeParams *forms.AuthorizeForm, claims *middlewares.CustomClaims, client *model.Client) bool {
+	if authorizeParams.Consent != "approve" || c.Request.Method != http.MethodPost || c.GetHeader("Authorization") == "" {
+		return false
+	}
+	if !ssoLoginClaims(claims) {
+		return false
+	}
+	ticket, ok := dao.UseConsentTicket(authorizeParams.ConsentTicket)
+	if !ok {
+		return false
+	}
+	return ticket.UserID == claims.ID && ticket.SessionID == claims.SessionID &&
+		ticket.ClientID == client.ClientID && ticket.Scope == strings.Join(strings.Fields(authorizeParams.Scope), " ")
+}
+
+// 当前用户已授权的第三方应用
+func MyConsents(c *gin.Context) {
+	user, ok := currentAccountUser(c)
+	if !ok {
+		return
+	}
+	response.Success(c, 200, "success", dao.ListConsents(user.ID))
+}
+
+// 撤销对第三方应用的授权，同时作废签发给该应用的token
+func RevokeConsent(c *gin.Context) {
+	consentParams := forms.ConsentForm{}
+	if err := c.ShouldBind(&consentParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	user, ok := currentAccountUser(c)
+	if !ok {
+		return
+	}
+	if !dao.DeleteConsent(user.ID, consentParams.ClientID) {
+		response.Err(c, http.StatusOK, 400, "没有授权该应用", "")
+		return
+	}
+	revokeUserClientSessions(user.ID, consentParams.ClientID)
+	global.Lg.Info("RevokeConsent", zap.Any("user_id", user.ID), zap.Any("client_id", consentParams.ClientID))
+	response.Success(c, 200, "success", nil)
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sso-go/dao"
+	"sso-go/forms"
+	"sso-go/global"
+	"sso-go/middlewares"
+	"sso-go/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+)
+
+func setupRedis(t *testing.T) *miniredis.Miniredis {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	global.Redis = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		global.Redis.Close()
+		mr.Close()
+	})
+	return mr
+}
+
+// 创建登录会话，返回会话ID
+func createTestSsoSession(t *testing.T, userID uint) string {
+	session := &model.SsoSession{UserID: userID, AuthTime: time.Now().Unix()}
+	if _, err := dao.CreateSsoSession(session, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	return session.ID
+}
+
+func TestConsentApproved(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupRedis(t)
+	sid := createTestSsoSession(t, 1)
+	otherSid := createTestSsoSession(t, 1)
+	client := &model.Client{ClientID: "client-1"}
+	ssoClaims := &middlewares.CustomClaims{ID: 1, SessionID: sid}
+
+	tests := []struct {
+		name    string
+		method  string
+		auth    string
+		consent string
+		scope   string
+		claims  *middlewares.CustomClaims
+		ticket  model.ConsentTicket
+		want    bool
+	}{
+		{"确认授权", http.MethodPost, "Bearer x", "approve", "openid  profile", ssoClaims,
+			model.ConsentTicket{UserID: 1, SessionID: sid, ClientID: "client-1", Scope: "openid profile"}, true},
+		{"GET请求", http.MethodGet, "Bearer x", "approve", "openid", ssoClaims,
+			model.ConsentTicket{UserID: 1, SessionID: sid, ClientID: "client-1", Scope: "openid"}, false},
+		{"只带cookie", http.MethodPost, "", "approve", "openid", ssoClaims,
+			model.ConsentTicket{UserID: 1, SessionID: sid, ClientID: "client-1", Scope: "openid"}, false},
+		{"拒绝授权", http.MethodPost, "Bearer x", "deny", "openid", ssoClaims,
+			model.ConsentTicket{UserID: 1, SessionID: sid, ClientID: "client-1", Scope: "openid"}, false},
+		{"签发给客户端的token", http.MethodPost, "Bearer x", "approve", "openid",
+			&middlewares.CustomClaims{ID: 1, SessionID: sid, ClientID: "client-1"},
+			model.ConsentTicket{UserID: 1, SessionID: sid, ClientID: "client-1", Scope: "openid"}, false},
+		{"没有会话的token", http.MethodPost, "Bearer x", "approve", "openid", &middlewares.CustomClaims{ID: 1},
+			model.ConsentTicket{UserID: 1, ClientID: "client-1", Scope: "openid"}, false},
+		{"会话已结束", http.MethodPost, "Bearer x", "approve", "openid", &middlewares.CustomClaims{ID: 1, SessionID: "ended"},
+			model.ConsentTicket{UserID: 1, SessionID: "ended", ClientID: "client-1", Scope: "openid"}, false},
+		{"其他用户的凭证", http.MethodPost, "Bearer x", "approve", "openid", ssoClaims,
+			model.ConsentTicket{UserID: 2, SessionID: sid, ClientID: "client-1", Scope: "openid"}, false},
+		{"其他会话的凭证", http.MethodPost, "Bearer x", "approve", "openid", ssoClaims,
+			model.ConsentTicket{UserID: 1, SessionID: otherSid, ClientID: "client-1", Scope: "openid"}, false},
+		{"其他客户端的凭证", http.MethodPost, "Bearer x", "approve", "openid", ssoClaims,
+			model.ConsentTicket{UserID: 1, SessionID: sid, ClientID: "client-2", Scope: "openid"}, false},
+		{"scope不一致", http.MethodPost, "Bearer x", "approve", "openid profile email", ssoClaims,
+			model.ConsentTicket{UserID: 1, SessionID: sid, ClientID: "client-1", Scope: "openid profile"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticket, err := dao.SaveConsentTicket(&tt.ticket, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(tt.method, "/oauth/authorize", strings.NewReader(""))
+			if tt.auth != "" {
+				c.Request.Header.Set("Authorization", tt.auth)
+			}
+			params := &forms.AuthorizeForm{Consent: tt.consent, ConsentTicket: ticket, Scope: tt.scope}
+			if got := consentApproved(c, params, tt.claims, client); got != tt.want {
+				t.Fatalf("consentApproved() = %v, want %v", got, tt.want)
+			}
+			// 凭证只能使用一次
+			if tt.want && consentApproved(c, params, tt.claims, client) {
+				t.Fatal("凭证被使用了两次")
+			}
+		})
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(""))
+	c.Request.Header.Set("Authorization", "Bearer x")
+	if consentApproved(c, &forms.AuthorizeForm{Consent: "approve", Scope: "openid"}, ssoClaims, client) {
+		t.Fatal("没有凭证时不应确认授权")
+	}
+}
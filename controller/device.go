@@ -0,0 +1,233 @@
+package controller
+
+import (
+	"net/http"
+	"net/url"
+	"sso-go/dao"
+	"sso-go/forms"
+	"sso-go/global"
+	"sso-go/model"
+	"sso-go/response"
+	"sso-go/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 设备授权（RFC 8628）的grant_type
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+const (
+	// device_code、user_code有效期
+	deviceCodeExpire = 10 * time.Minute
+	// 默认最小轮询间隔（秒）
+	deviceCodeInterval = 5
+)
+
+// 统一user_code格式：转大写并去掉横线、空格等分隔符
+func normalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return -1
+	}, userCode)
+}
+
+// 展示给用户的user_code，中间加横线方便输入，如 BDWP-HQRT
+func displayUserCode(userCode string) string {
+	if len(userCode) != 8 {
+		return userCode
+	}
+	return userCode[:4] + "-" + userCode[4:]
+}
+
+// 拼接带user_code的设备授权页面地址
+func deviceVerificationLink(userCode string) string {
+	link := global.Settings.DeviceVerificationUrl
+	u, err := url.Parse(link)
+	if err != nil || link == "" {
+		return link
+	}
+	query := u.Query()
+	query.Set("user_code", displayUserCode(userCode))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// 设备发起授权，返回device_code供设备轮询，user_code和授权页面地址展示给用户
+func DeviceAuthorization(c *gin.Context) {
+	client, ok := authenticateClient(c)
+	if !ok {
+		response.OAuthErr(c, http.StatusUnauthorized, "invalid_client", "客户端认证失败")
+		return
+	}
+	scope := strings.Join(strings.Fields(c.PostForm("scope")), " ")
+	if !client.AllowScopes(scope) {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_scope", "申请的授权范围不被允许")
+		return
+	}
+	deviceCode := utils.GenerateCode()
+	record := model.DeviceCode{
+		ClientID:  client.ClientID,
+		Scope:     scope,
+		Status:    model.DeviceCodePending,
+		Interval:  deviceCodeInterval,
+		ExpiresAt: time.Now().Add(deviceCodeExpire).Unix(),
+	}
+	// 过期后保留一段时间，设备继续轮询时返回expired_token而不是invalid_grant
+	var err error
+	for i := 0; i < 3; i++ {
+		record.UserCode = utils.GenerateUserCode()
+		if err = dao.SaveDeviceCode(deviceCode, &record, 2*deviceCodeExpire); err != dao.ErrUserCodeTaken {
+			break
+		}
+	}
+	if err != nil {
+		response.OAuthErr(c, http.StatusInternalServerError, "server_error", "device_code生成失败")
+		return
+	}
+	global.Lg.Info("DeviceAuthorization", zap.Any("client_id", client.ClientID), zap.Any("scope", scope))
+	response.OAuth(c, map[string]interface{}{
+		"device_code":               deviceCode,
+		"user_code":                 displayUserCode(record.UserCode),
+		"verification_uri":          global.Settings.DeviceVerificationUrl,
+		"verification_uri_complete": deviceVerificationLink(record.UserCode),
+		"expires_in":                int64(deviceCodeExpire / time.Second),
+		"interval":                  record.Interval,
+	})
+}
+
+// 查询user_code对应的授权请求，授权页面展示给用户确认
+func DeviceInfo(c *gin.Context) {
+	codeParams := forms.DeviceCodeForm{}
+	if err := c.ShouldBindQuery(&codeParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	record, ok := dao.GetDeviceCodeByUserCode(normalizeUserCode(codeParams.UserCode))
+	if !ok {
+		response.Err(c, http.StatusOK, 400, dao.ErrUserCodeInvalid.Error(), nil)
+		return
+	}
+	client, ok := dao.GetClientByClientID(record.ClientID)
+	if !ok {
+		response.Err(c, http.StatusOK, 400, dao.ErrUserCodeInvalid.Error(), nil)
+		return
+	}
+	response.Success(c, 200, "success", map[string]interface{}{
+		"client_id":   client.ClientID,
+		"client_name": client.Name,
+		"scope":       record.Scope,
+		"expires_in":  record.ExpiresAt - time.Now().Unix(),
+	})
+}
+
+// 登录用户确认或拒绝设备授权，确认后设备下一次轮询即可换取token
+func DeviceDecision(c *gin.Context) {
+	decisionParams := forms.DeviceDecisionForm{}
+	if err := c.ShouldBind(&decisionParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	// 只能用SSO自身登录的token确认，签发给客户端的token不能替用户确认设备授权
+	claims, ok := getClaims(c)
+	if !ok || !ssoLoginClaims(claims) {
+		response.Err(c, http.StatusOK, 401, "未登录", "")
+		return
+	}
+	userCode := normalizeUserCode(decisionParams.UserCode)
+	record, ok := dao.GetDeviceCodeByUserCode(userCode)
+	if !ok {
+		response.Err(c, http.StatusOK, 400, dao.ErrUserCodeInvalid.Error(), nil)
+		return
+	}
+	client, ok := dao.GetClientByClientID(record.ClientID)
+	if !ok {
+		response.Err(c, http.StatusOK, 400, dao.ErrUserCodeInvalid.Error(), nil)
+		return
+	}
+	status := model.DeviceCodeDenied
+	var orgID uint
+	if decisionParams.Action == "approve" {
+		status = model.DeviceCodeApproved
+		if orgID, ok = authCodeOrg(client, claims); !ok {
+			response.Err(c, http.StatusOK, 403, "access_denied", dao.ErrNotOrgMember.Error())
+			return
+		}
+		// 用户在授权页面确认即视为同意，记录下来供撤销授权使用
+		if !client.FirstParty {
+			if err := dao.SaveConsent(claims.ID, client.ClientID, record.Scope); err != nil {
+				response.Err(c, http.StatusOK, 500, "授权记录保存失败", err.Error())
+				return
+			}
+		}
+	}
+	if err := dao.DecideDeviceCode(userCode, status, claims.ID, orgID, claims.AuthTime); err != nil {
+		response.Err(c, http.StatusOK, 400, dao.ErrUserCodeInvalid.Error(), nil)
+		return
+	}
+	global.Lg.Info("DeviceDecision", zap.Any("client_id", client.ClientID), zap.Any("user_id", claims.ID), zap.String("status", status))
+	response.Success(c, 200, "success", nil)
+}
+
+// 设备轮询换取token，用户确认前返回authorization_pending，轮询过快返回slow_down
+func exchangeDeviceCode(c *gin.Context, tokenParams *forms.TokenForm) {
+	client, ok := authenticateClient(c)
+	if !ok {
+		response.OAuthErr(c, http.StatusUnauthorized, "invalid_client", "客户端认证失败")
+		return
+	}
+	if tokenParams.DeviceCode == "" {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_request", "device_code不得为空")
+		return
+	}
+	record, err := dao.PollDeviceCode(tokenParams.DeviceCode, client.ClientID)
+	switch err {
+	case nil:
+	case dao.ErrDeviceCodePending:
+		response.OAuthErr(c, http.StatusBadRequest, "authorization_pending", err.Error())
+		return
+	case dao.ErrDeviceCodeSlowDown:
+		response.OAuthErr(c, http.StatusBadRequest, "slow_down", err.Error())
+		return
+	case dao.ErrDeviceCodeExpired:
+		response.OAuthErr(c, http.StatusBadRequest, "expired_token", err.Error())
+		return
+	case dao.ErrDeviceCodeDenied:
+		response.OAuthErr(c, http.StatusBadRequest, "access_denied", err.Error())
+		return
+	case dao.ErrDeviceCodeInvalid:
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	default:
+		response.OAuthErr(c, http.StatusInternalServerError, "server_error", "device_code校验失败")
+		return
+	}
+	user, ok := dao.GetActiveUserByID(record.UserID)
+	if !ok {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_grant", "用户不存在")
+		return
+	}
+	tokens, err := issueTokenPair(user, client.ClientID, record.Scope, record.OrgID, record.AuthTime, "", "")
+	if err == dao.ErrNotOrgMember {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	}
+	if err != nil {
+		response.OAuthErr(c, http.StatusInternalServerError, "server_error", "token生成失败")
+		return
+	}
+	tokenInfo := tokenResponse(tokens, record.Scope)
+	if err := addIDToken(tokenInfo, user, client.ClientID, record.Scope, "", record.AuthTime); err != nil {
+		response.OAuthErr(c, http.StatusInternalServerError, "server_error", "id_token生成失败")
+		return
+	}
+	global.Lg.Info("DeviceToken", zap.Any("client_id", client.ClientID), zap.Any("user_id", user.ID))
+	response.OAuth(c, tokenInfo)
+}
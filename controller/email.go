@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"sso-go/dao"
+	"sso-go/forms"
+	"sso-go/global"
+	"sso-go/response"
+	"sso-go/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 更换邮箱的验证码只能由申请的账号使用
+func changeEmailScene(userID uint) string {
+	return fmt.Sprintf("%s:%d", dao.EmailCodeChangeEmail, userID)
+}
+
+// 更换邮箱，向新邮箱发送验证码
+func SendChangeEmailCode(c *gin.Context) {
+	emailParams := forms.EmailParams{}
+	if err := c.ShouldBind(&emailParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	user, ok := currentAccountUser(c)
+	if !ok {
+		return
+	}
+	email := emailParams.Email
+	if strings.EqualFold(email, user.Email) {
+		response.Err(c, http.StatusOK, 400, "新邮箱不能和当前邮箱相同", nil)
+		return
+	}
+	if dao.HasEmail(email) {
+		response.Err(c, http.StatusOK, 400, "该邮箱已注册", nil)
+		return
+	}
+	if err := dao.AcquireEmailCodeQuota(email, c.ClientIP()); err != nil {
+		response.Err(c, http.StatusOK, 429, err.Error(), nil)
+		return
+	}
+	vCode, err := utils.SendEmailValidate([]string{email})
+	if err != nil {
+		dao.ReleaseEmailCodeCooldown(email)
+		response.Err(c, http.StatusOK, 500, "验证码发送失败", err.Error())
+		return
+	}
+	if err := dao.SaveEmailCode(changeEmailScene(user.ID), email, vCode); err != nil {
+		response.Err(c, http.StatusOK, 500, "验证码发送失败", err.Error())
+		return
+	}
+	response.Success(c, 200, "success", nil)
+}
+
+// 确认更换邮箱，需要新邮箱的验证码和当前密码，更换后通知旧邮箱，其他登录全部失效
+func ChangeEmail(c *gin.Context) {
+	changeParams := forms.ChangeEmailForm{}
+	if err := c.ShouldBind(&changeParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	user, ok := currentAccountUser(c)
+	if !ok {
+		return
+	}
+	if !verifyCurrentPassword(c, user, changeParams.PassWord) {
+		return
+	}
+	scene := changeEmailScene(user.ID)
+	if err := dao.VerifyEmailCode(scene, changeParams.Email, changeParams.Code); err != nil {
+		response.Err(c, http.StatusOK, 400, err.Error(), nil)
+		return
+	}
+	// 发送验证码后新邮箱可能已被其他账号注册
+	if dao.HasEmail(changeParams.Email) {
+		response.Err(c, http.StatusOK, 400, "该邮箱已注册", nil)
+		return
+	}
+	oldEmail := user.Email
+	verifiedAt := utils.GetNowFormatTime()
+	if err := dao.UpdateUserEmail(user.ID, changeParams.Email, verifiedAt); err != nil {
+		response.Err(c, http.StatusOK, 500, "更换邮箱失败", err.Error())
+		return
+	}
+	dao.ConsumeEmailCode(scene, changeParams.Email)
+	user.Email = changeParams.Email
+	user.EmailVerifiedAt = verifiedAt
+
+	revokeUserSessions(user.ID)
+	notifySecurityChange(oldEmail, fmt.Sprintf("将登录邮箱更换为 %s", changeParams.Email))
+	global.Lg.Info("ChangeEmail", zap.Any("user_id", user.ID), zap.String("old_email", oldEmail), zap.String("email", user.Email))
+
+	userInfoMap, err := loginTokens(c, user, currentOrgID(c))
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "token生成失败,请重新登录", err.Error())
+		return
+	}
+	response.Success(c, 200, "success", userInfoMap)
+}
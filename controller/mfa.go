@@ -0,0 +1,363 @@
+package controller
+
+import (
+	"net/http"
+	"sso-go/dao"
+	"sso-go/forms"
+	"sso-go/global"
+	"sso-go/middlewares"
+	"sso-go/model"
+	"sso-go/response"
+	"sso-go/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// mfa_token有效期
+	mfaChallengeExpire = 5 * time.Minute
+	// 每次生成的恢复码数量
+	recoveryCodeCount = 10
+)
+
+// 密码验证通过后是否需要两步验证：开启了TOTP或注册了WebAuthn凭证的账号需要验证，强制两步验证但都没有的账号需要先绑定TOTP
+func mfaChallengeFor(user *model.User) (*model.MfaChallenge, bool) {
+	if methods := mfaMethods(user.ID); len(methods) > 0 {
+		return &model.MfaChallenge{UserID: user.ID, Methods: methods}, true
+	}
+	if mfaRequired(user) {
+		return &model.MfaChallenge{UserID: user.ID, Enroll: true, Methods: []string{"totp"}}, true
+	}
+	return nil, false
+}
+
+// 用户可以使用的第二因素
+func mfaMethods(userID uint) []string {
+	methods := []string{}
+	if totpEnabled(userID) {
+		methods = append(methods, "totp")
+	}
+	if global.WebAuthn != nil && dao.HasWebauthnCredential(userID) {
+		methods = append(methods, "webauthn")
+	}
+	return methods
+}
+
+func totpEnabled(userID uint) bool {
+	totp, ok := dao.GetUserTotp(userID)
+	return ok && totp.Enabled()
+}
+
+// 是否为env.toml中配置的必须开启两步验证的账号，管理员以及拥有SSO管理权限的账号都必须开启
+func mfaRequired(user *model.User) bool {
+	for _, account := range global.Settings.MfaInfo.RequiredUsers {
+		if account == user.Name || account == user.Email {
+			return true
+		}
+	}
+	if middlewares.IsAdminUser(user.ID) {
+		return true
+	}
+	_, permissions := dao.GetUserAuthorization(user.ID)
+	for _, p := range permissions {
+		if strings.HasPrefix(p, "sso:") {
+			return true
+		}
+	}
+	return false
+}
+
+// 校验第二因素：6位数字按TOTP校验，其余按恢复码校验，连续失败次数过多时拒绝
+func verifySecondFactor(userID uint, code string, allowRecovery bool) (bool, string) {
+	if dao.MfaLocked(userID) {
+		return false, "验证失败次数过多，请稍后再试"
+	}
+	totp, ok := dao.GetUserTotp(userID)
+	if !ok || !totp.Enabled() {
+		return false, "未开启两步验证"
+	}
+	verified := false
+	if utils.IsTotpCode(code) {
+		step, ok := utils.VerifyTotp(totp.Secret, code, time.Now())
+		verified = ok && dao.UseTotpStep(totp, step)
+	} else if allowRecovery {
+		verified = dao.UseRecoveryCode(userID, code)
+	}
+	if !verified {
+		dao.AddMfaFailure(userID)
+		return false, "验证码错误"
+	}
+	dao.ClearMfaFailures(userID)
+	return true, ""
+}
+
+// 生成待确认的TOTP密钥，返回密钥、otpauth地址和二维码
+func startTotpSetup(user *model.User) (map[string]interface{}, string) {
+	if totp, ok := dao.GetUserTotp(user.ID); ok && totp.Enabled() {
+		return nil, "已开启两步验证"
+	}
+	secret := utils.GenerateTotpSecret()
+	if err := dao.SaveTotpSecret(user.ID, secret); err != nil {
+		global.Lg.Error("TotpSetup", zap.Error(err))
+		return nil, "密钥生成失败"
+	}
+	issuer := global.Settings.MfaInfo.Issuer
+	if issuer == "" {
+		issuer = global.Settings.Name
+	}
+	uri := utils.TotpURI(issuer, user.Email, secret)
+	qrCode, err := utils.QRCodeDataURI(uri)
+	if err != nil {
+		return nil, "二维码生成失败"
+	}
+	return map[string]interface{}{
+		"secret":      secret,
+		"otpauth_uri": uri,
+		"qr_code":     qrCode,
+	}, ""
+}
+
+// 用验证器上的验证码确认绑定，返回恢复码，恢复码只在这里显示一次
+// 和登录时的两步验证共用失败次数限制，避免知道密码的人暴力猜测绑定验证码
+func confirmTotpSetup(userID uint, code string) ([]string, string) {
+	if dao.MfaLocked(userID) {
+		return nil, "验证失败次数过多，请稍后再试"
+	}
+	totp, ok := dao.GetUserTotp(userID)
+	if !ok {
+		return nil, "请先获取TOTP密钥"
+	}
+	if totp.Enabled() {
+		return nil, "已开启两步验证"
+	}
+	step, ok := utils.VerifyTotp(totp.Secret, code, time.Now())
+	if !ok {
+		dao.AddMfaFailure(userID)
+		return nil, "验证码错误"
+	}
+	dao.ClearMfaFailures(userID)
+	codes := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err := dao.ConfirmTotp(totp, step, hashRecoveryCodes(codes)); err != nil {
+		global.Lg.Error("TotpConfirm", zap.Error(err))
+		return nil, "开启两步验证失败"
+	}
+	global.Lg.Info("TotpConfirm", zap.Any("user_id", userID))
+	return codes, ""
+}
+
+func hashRecoveryCodes(codes []string) []string {
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, utils.HashRecoveryCode(code))
+	}
+	return hashes
+}
+
+// 两步验证状态
+func TotpStatus(c *gin.Context) {
+	claims, ok := getClaims(c)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "未登录", "")
+		return
+	}
+	enabled := totpEnabled(claims.ID)
+	data := map[string]interface{}{
+		"enabled":  enabled,
+		"required": false,
+	}
+	if user, ok := dao.GetUserByID(claims.ID); ok {
+		data["required"] = mfaRequired(user)
+	}
+	if enabled {
+		data["recovery_codes_left"] = dao.CountRecoveryCodes(claims.ID)
+	}
+	response.Success(c, 200, "success", data)
+}
+
+// 开始绑定TOTP
+func TotpSetup(c *gin.Context) {
+	claims, ok := getClaims(c)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "未登录", "")
+		return
+	}
+	user, ok := dao.GetUserByID(claims.ID)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "用户不存在", "")
+		return
+	}
+	data, msg := startTotpSetup(user)
+	if data == nil {
+		response.Err(c, http.StatusOK, 400, msg, "")
+		return
+	}
+	response.Success(c, 200, "success", data)
+}
+
+// 确认绑定TOTP，开启两步验证
+func TotpConfirm(c *gin.Context) {
+	codeParams := forms.TotpCodeForm{}
+	if err := c.ShouldBind(&codeParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "未登录", "")
+		return
+	}
+	codes, msg := confirmTotpSetup(claims.ID, codeParams.Code)
+	if codes == nil {
+		response.Err(c, http.StatusOK, 400, msg, "")
+		return
+	}
+	response.Success(c, 200, "success", map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+// 关闭两步验证，需要验证码或恢复码，强制两步验证的账号没有WebAuthn凭证时不能关闭
+func TotpDisable(c *gin.Context) {
+	codeParams := forms.TotpCodeForm{}
+	if err := c.ShouldBind(&codeParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "未登录", "")
+		return
+	}
+	if user, ok := dao.GetUserByID(claims.ID); ok && mfaRequired(user) && !dao.HasWebauthnCredential(claims.ID) {
+		response.Err(c, http.StatusOK, 403, "该账号必须开启两步验证", "")
+		return
+	}
+	if ok, msg := verifySecondFactor(claims.ID, codeParams.Code, true); !ok {
+		response.Err(c, http.StatusOK, 400, msg, "")
+		return
+	}
+	if err := dao.DeleteUserTotp(claims.ID); err != nil {
+		response.Err(c, http.StatusOK, 500, "关闭两步验证失败", err.Error())
+		return
+	}
+	global.Lg.Info("TotpDisable", zap.Any("user_id", claims.ID))
+	response.Success(c, 200, "success", nil)
+}
+
+// 重新生成恢复码，需要验证器上的验证码，之前的恢复码全部作废
+func RegenerateRecoveryCodes(c *gin.Context) {
+	codeParams := forms.TotpCodeForm{}
+	if err := c.ShouldBind(&codeParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "未登录", "")
+		return
+	}
+	if ok, msg := verifySecondFactor(claims.ID, codeParams.Code, false); !ok {
+		response.Err(c, http.StatusOK, 400, msg, "")
+		return
+	}
+	codes := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err := dao.ReplaceRecoveryCodes(claims.ID, hashRecoveryCodes(codes)); err != nil {
+		response.Err(c, http.StatusOK, 500, "恢复码生成失败", err.Error())
+		return
+	}
+	response.Success(c, 200, "success", map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+// 登录第二步：用mfa_token和验证码（或恢复码）完成登录
+func LoginMfa(c *gin.Context) {
+	mfaParams := forms.MfaLoginForm{}
+	if err := c.ShouldBind(&mfaParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	challenge, ok := dao.GetMfaChallenge(mfaParams.MfaToken)
+	if !ok || challenge.Enroll {
+		response.Err(c, http.StatusOK, 401, "mfa_token无效或已过期", "")
+		return
+	}
+	if ok, msg := verifySecondFactor(challenge.UserID, mfaParams.Code, true); !ok {
+		response.Err(c, http.StatusOK, 401, msg, "")
+		return
+	}
+	completeMfaLogin(c, mfaParams.MfaToken, challenge, nil)
+}
+
+// 强制两步验证的账号首次登录时获取TOTP密钥
+func LoginTotpSetup(c *gin.Context) {
+	mfaParams := forms.MfaLoginForm{}
+	if err := c.ShouldBind(&mfaParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	challenge, ok := dao.GetMfaChallenge(mfaParams.MfaToken)
+	if !ok || !challenge.Enroll {
+		response.Err(c, http.StatusOK, 401, "mfa_token无效或已过期", "")
+		return
+	}
+	user, ok := dao.GetActiveUserByID(challenge.UserID)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "用户不存在", "")
+		return
+	}
+	data, msg := startTotpSetup(user)
+	if data == nil {
+		response.Err(c, http.StatusOK, 400, msg, "")
+		return
+	}
+	response.Success(c, 200, "success", data)
+}
+
+// 强制两步验证的账号确认绑定TOTP并完成登录，同时返回恢复码
+func LoginTotpConfirm(c *gin.Context) {
+	mfaParams := forms.MfaLoginForm{}
+	if err := c.ShouldBind(&mfaParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	challenge, ok := dao.GetMfaChallenge(mfaParams.MfaToken)
+	if !ok || !challenge.Enroll {
+		response.Err(c, http.StatusOK, 401, "mfa_token无效或已过期", "")
+		return
+	}
+	codes, msg := confirmTotpSetup(challenge.UserID, mfaParams.Code)
+	if codes == nil {
+		response.Err(c, http.StatusOK, 400, msg, "")
+		return
+	}
+	completeMfaLogin(c, mfaParams.MfaToken, challenge, map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+// 作废mfa_token并签发token，mfa_token只能使用一次
+func completeMfaLogin(c *gin.Context, mfaToken string, challenge *model.MfaChallenge, extra map[string]interface{}) {
+	if !dao.ConsumeMfaChallenge(mfaToken) {
+		response.Err(c, http.StatusOK, 401, "mfa_token无效或已过期", "")
+		return
+	}
+	user, ok := dao.GetActiveUserByID(challenge.UserID)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "用户不存在", "")
+		return
+	}
+	userInfoMap, err := loginTokens(c, user, challenge.OrgID)
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "token生成失败,重新再试", err.Error())
+		return
+	}
+	for key, value := range extra {
+		userInfoMap[key] = value
+	}
+	global.Lg.Info("LoginMfa", zap.Any("user_id", user.ID))
+	response.Success(c, 200, "success", userInfoMap)
+}
@@ -0,0 +1,610 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sso-go/dao"
+	"sso-go/forms"
+	"sso-go/global"
+	"sso-go/middlewares"
+	"sso-go/model"
+	"sso-go/response"
+	"sso-go/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 授权码有效期
+const authCodeExpire = time.Minute
+
+// OAuth2授权接口，登录用户为指定客户端签发授权码
+// 用户通过Authorization头中的token或登录会话cookie识别，已登录的浏览器跳转过来时直接回跳授权码
+func Authorize(c *gin.Context) {
+	authorizeParams := forms.AuthorizeForm{}
+	if err := c.ShouldBind(&authorizeParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+
+	// 校验客户端和回调地址，校验不通过时不能回跳，直接返回错误
+	client, ok := dao.GetClientByClientID(authorizeParams.ClientID)
+	if !ok {
+		response.Err(c, http.StatusOK, 400, "invalid_client", "客户端不存在")
+		return
+	}
+	if !isAllowedRedirect(client, authorizeParams.RedirectUri) {
+		response.Err(c, http.StatusOK, 400, "invalid_redirect_uri", "回调地址未注册")
+		return
+	}
+	if !client.AllowScopes(authorizeParams.Scope) {
+		response.Err(c, http.StatusOK, 400, "invalid_scope", "申请的授权范围不被允许")
+		return
+	}
+	// 公开客户端无法保存密钥，必须使用PKCE防止授权码被截获后冒用
+	if authorizeParams.CodeChallenge == "" && client.IsPublic() {
+		response.Err(c, http.StatusOK, 400, "invalid_request", "公开客户端必须使用PKCE")
+		return
+	}
+	if authorizeParams.CodeChallenge != "" && !utils.IsPKCEValue(authorizeParams.CodeChallenge) {
+		response.Err(c, http.StatusOK, 400, "invalid_request", "code_challenge格式错误")
+		return
+	}
+
+	// 回调地址校验通过后，登录、确认授权相关的错误在prompt=none时都回跳给客户端
+	silent := authorizeParams.Prompt == "none"
+	claims, ok := authorizeClaims(c)
+	if ok && authorizeParams.MaxAge != nil && time.Now().Unix()-claims.AuthTime > *authorizeParams.MaxAge {
+		ok = false
+	}
+	if !ok {
+		if silent {
+			redirectWithError(c, authorizeParams.RedirectUri, "login_required", authorizeParams.State)
+			return
+		}
+		loginRequired(c)
+		return
+	}
+
+	orgID, ok := authCodeOrg(client, claims)
+	if !ok {
+		if silent {
+			redirectWithError(c, authorizeParams.RedirectUri, "access_denied", authorizeParams.State)
+			return
+		}
+		response.Err(c, http.StatusOK, 403, "access_denied", dao.ErrNotOrgMember.Error())
+		return
+	}
+	// 第三方客户端需要用户确认授权，拒绝时带着access_denied回跳
+	if authorizeParams.Consent == "deny" {
+		global.Lg.Info("AuthorizeDenied", zap.Any("client_id", client.ClientID), zap.Any("user_id", claims.ID))
+		redirectWithError(c, authorizeParams.RedirectUri, "access_denied", authorizeParams.State)
+		return
+	}
+	if missing, ok := pendingConsent(client, claims.ID, authorizeParams.Scope); ok {
+		if silent {
+			redirectWithError(c, authorizeParams.RedirectUri, "consent_required", authorizeParams.State)
+			return
+		}
+		if !consentApproved(c, &authorizeParams, claims, client) {
+			consentRequired(c, client, claims, authorizeParams.Scope, missing)
+			return
+		}
+		if err := dao.SaveConsent(claims.ID, client.ClientID, authorizeParams.Scope); err != nil {
+			response.Err(c, http.StatusOK, 500, "授权记录保存失败", err.Error())
+			return
+		}
+	}
+
+	code := utils.GenerateCode()
+	authCode := model.AuthCode{
+		UserID:      claims.ID,
+		ClientID:    client.ClientID,
+		OrgID:       orgID,
+		RedirectUri: authorizeParams.RedirectUri,
+		Scope:       authorizeParams.Scope,
+		IssuedAt:    time.Now().Unix(),
+		Nonce:       authorizeParams.Nonce,
+		AuthTime:    claims.AuthTime,
+		CodeChallenge: model.CodeChallenge{
+			Challenge: authorizeParams.CodeChallenge,
+			Method:    authorizeParams.CodeChallengeMethod,
+		},
+	}
+	if err := dao.SaveAuthCode(code, &authCode, authCodeExpire); err != nil {
+		response.Err(c, http.StatusOK, 500, "授权码生成失败", err.Error())
+		return
+	}
+	global.Lg.Info("Authorize", zap.Any("client_id", client.ClientID), zap.Any("user_id", claims.ID))
+
+	redirectWithCode(c, authorizeParams.RedirectUri, code, authorizeParams.State)
+}
+
+// OAuth2令牌接口
+func Token(c *gin.Context) {
+	tokenParams := forms.TokenForm{}
+	if err := c.ShouldBind(&tokenParams); err != nil {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_request", "grant_type不得为空")
+		return
+	}
+	switch tokenParams.GrantType {
+	case "authorization_code":
+		exchangeAuthCode(c, &tokenParams)
+	case "refresh_token":
+		exchangeRefreshToken(c, &tokenParams)
+	case "client_credentials":
+		exchangeClientCredentials(c, &tokenParams)
+	case deviceCodeGrantType:
+		exchangeDeviceCode(c, &tokenParams)
+	default:
+		response.OAuthErr(c, http.StatusBadRequest, "unsupported_grant_type", "不支持的授权类型")
+	}
+}
+
+// 授权码换取token
+func exchangeAuthCode(c *gin.Context, tokenParams *forms.TokenForm) {
+	client, ok := authenticateClient(c)
+	if !ok {
+		response.OAuthErr(c, http.StatusUnauthorized, "invalid_client", "客户端认证失败")
+		return
+	}
+	if tokenParams.Code == "" {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_request", "code不得为空")
+		return
+	}
+	authCode, issued, err := redeemAuthCode(tokenParams.Code)
+	if err != nil {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	}
+	// 授权码只能由申请它的客户端、以相同的回调地址兑换
+	if authCode.ClientID != client.ClientID || authCode.RedirectUri != tokenParams.RedirectUri {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_grant", "授权码与客户端不匹配")
+		return
+	}
+	if authCode.Challenge != "" && !utils.VerifyPKCE(tokenParams.CodeVerifier, authCode.Challenge, authCode.Method) {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_grant", "code_verifier校验失败")
+		return
+	}
+	user, ok := dao.GetActiveUserByID(authCode.UserID)
+	if !ok {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_grant", "用户不存在")
+		return
+	}
+	tokens, err := issueAuthCodeTokens(tokenParams.Code, issued, user, authCode)
+	if err == dao.ErrAuthCodeReused {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	}
+	if err != nil {
+		response.OAuthErr(c, http.StatusInternalServerError, "server_error", "token生成失败")
+		return
+	}
+	tokenInfo := tokenResponse(tokens, authCode.Scope)
+	if err := addIDToken(tokenInfo, user, client.ClientID, authCode.Scope, authCode.Nonce, authCode.AuthTime); err != nil {
+		response.OAuthErr(c, http.StatusInternalServerError, "server_error", "id_token生成失败")
+		return
+	}
+
+	response.OAuth(c, tokenInfo)
+}
+
+// 申请了openid时按OIDC规范同时返回id_token
+func addIDToken(tokenInfo map[string]interface{}, user *model.User, clientID string, scope string, nonce string, authTime int64) error {
+	if !utils.HasScope(scope, "openid") {
+		return nil
+	}
+	roles, _ := tokenAuthorization(user.ID, clientID, scope)
+	idToken, err := utils.SignIDToken(user, roles, clientID, scope, nonce, authTime)
+	if err != nil {
+		return err
+	}
+	tokenInfo["id_token"] = idToken
+	return nil
+}
+
+// 客户端凭证换取服务token（RFC 6749 4.4），供后台任务、服务之间调用使用
+// 只有机密客户端可以使用，没有传scope时授予客户端允许的全部scope
+func exchangeClientCredentials(c *gin.Context, tokenParams *forms.TokenForm) {
+	client, ok := authenticateClient(c)
+	if !ok || client.IsPublic() {
+		response.OAuthErr(c, http.StatusUnauthorized, "invalid_client", "客户端认证失败")
+		return
+	}
+	scope := strings.Join(strings.Fields(tokenParams.Scope), " ")
+	if scope == "" {
+		scope = client.Scopes
+	}
+	if !client.AllowScopes(scope) {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_scope", "申请的scope超出客户端允许的范围")
+		return
+	}
+	accessToken, expiresAt, err := issueClientToken(client, scope)
+	if err != nil {
+		response.OAuthErr(c, http.StatusInternalServerError, "server_error", "token生成失败")
+		return
+	}
+	global.Lg.Info("ClientCredentials", zap.Any("client_id", client.ClientID), zap.Any("scope", scope))
+	response.OAuth(c, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   expiresAt - time.Now().Unix(),
+		"scope":        scope,
+	})
+}
+
+// 授权码已使用标记的保留时间，期间重复兑换会吊销之前签发的token
+func authCodeUsedTTL() time.Duration {
+	return time.Duration(utils.AccessTokenExpireSeconds()) * time.Second
+}
+
+// 兑换授权码，授权码被重复兑换时吊销之前用它签发的token
+// 将要签发的jti和refresh_token family在兑换前生成，和已使用标记一起原子写入
+func redeemAuthCode(code string) (*model.AuthCode, *model.AuthCodeTokens, error) {
+	issued := &model.AuthCodeTokens{
+		TokenID: utils.GenerateHexCode(16),
+		// 多留一分钟，保证黑名单覆盖到token实际的过期时间
+		ExpiresAt: time.Now().Unix() + utils.AccessTokenExpireSeconds() + 60,
+		FamilyID:  utils.GenerateHexCode(16),
+	}
+	authCode, reused, err := dao.RedeemAuthCode(code, issued, authCodeUsedTTL())
+	if err == dao.ErrAuthCodeReused {
+		revokeAuthCodeTokens(reused)
+	}
+	return authCode, issued, err
+}
+
+// 用兑换授权码时生成的jti和family签发token
+// 签发期间授权码又被重复兑换时，重复兑换的请求可能早于refresh_token写入，这里再吊销一次
+func issueAuthCodeTokens(code string, issued *model.AuthCodeTokens, user *model.User, authCode *model.AuthCode) (*tokenPair, error) {
+	tokens, err := issueTokenPairWithID(issued.TokenID, user, authCode.ClientID, authCode.Scope, authCode.OrgID, authCode.AuthTime, issued.FamilyID, "")
+	if err != nil {
+		return nil, err
+	}
+	if dao.AuthCodeReused(code) {
+		revokeAuthCodeTokens(issued)
+		return nil, dao.ErrAuthCodeReused
+	}
+	return tokens, nil
+}
+
+func revokeAuthCodeTokens(issued *model.AuthCodeTokens) {
+	middlewares.RevokeTokenID(issued.TokenID, issued.ExpiresAt)
+	if issued.FamilyID != "" {
+		dao.RevokeRefreshFamily(issued.FamilyID)
+	}
+	global.Lg.Warn("AuthCodeReused", zap.Any("jti", issued.TokenID), zap.Any("family_id", issued.FamilyID))
+}
+
+// refresh_token换取新的token，旧的refresh_token随即失效
+func exchangeRefreshToken(c *gin.Context, tokenParams *forms.TokenForm) {
+	if tokenParams.RefreshToken == "" {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_request", "refresh_token不得为空")
+		return
+	}
+	// 签发给客户端的refresh_token只能由该客户端使用，SSO自身登录签发的不需要客户端认证
+	var client *model.Client
+	if hasClientCredentials(c) {
+		var ok bool
+		if client, ok = authenticateClient(c); !ok {
+			response.OAuthErr(c, http.StatusUnauthorized, "invalid_client", "客户端认证失败")
+			return
+		}
+	}
+	record, err := dao.UseRefreshToken(tokenParams.RefreshToken)
+	if err != nil {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	}
+	if record.ClientID != "" && (client == nil || client.ClientID != record.ClientID) {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_grant", "refresh_token与客户端不匹配")
+		return
+	}
+	// 已退出登录的会话不能再刷新
+	if record.SessionID != "" && !dao.SsoSessionExists(record.SessionID) {
+		dao.RevokeRefreshFamily(record.FamilyID)
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_grant", "登录会话已结束")
+		return
+	}
+	// 可以申请缩小授权范围，但不能超出原来的范围
+	scope := record.Scope
+	if tokenParams.Scope != "" {
+		for _, s := range strings.Fields(tokenParams.Scope) {
+			if !utils.HasScope(record.Scope, s) {
+				response.OAuthErr(c, http.StatusBadRequest, "invalid_scope", "申请的授权范围超出原授权")
+				return
+			}
+		}
+		scope = tokenParams.Scope
+	}
+
+	user, ok := dao.GetActiveUserByID(record.UserID)
+	if !ok {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_grant", "用户不存在")
+		return
+	}
+	tokens, err := issueTokenPair(user, record.ClientID, scope, record.OrgID, record.AuthTime, record.FamilyID, record.SessionID)
+	if errors.Is(err, dao.ErrNotOrgMember) {
+		// 已被移出组织，这次登录不能再继续使用
+		dao.RevokeRefreshFamily(record.FamilyID)
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	}
+	if err != nil {
+		response.OAuthErr(c, http.StatusInternalServerError, "server_error", "token生成失败")
+		return
+	}
+	response.OAuth(c, tokenResponse(tokens, scope))
+}
+
+// 吊销token（RFC 7009），支持access_token和refresh_token
+// 无效或已过期的token同样返回200，避免客户端据此探测token
+func Revoke(c *gin.Context) {
+	client, ok := authenticateClient(c)
+	if !ok {
+		response.OAuthErr(c, http.StatusUnauthorized, "invalid_client", "客户端认证失败")
+		return
+	}
+	token := c.PostForm("token")
+	if token == "" {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_request", "token不得为空")
+		return
+	}
+	// token_type_hint只是优化提示，refresh_token查redis代价很小，直接按顺序尝试
+	if !revokeRefreshToken(token, client.ClientID) {
+		revokeAccessToken(token, client.ClientID)
+	}
+	c.Status(http.StatusOK)
+}
+
+// 作废签发给指定客户端的refresh_token所在的family，不是有效的refresh_token时返回false
+func revokeRefreshToken(token string, clientID string) bool {
+	record, ok := dao.GetRefreshToken(token)
+	if !ok || record.ClientID != clientID {
+		return false
+	}
+	dao.RevokeRefreshFamily(record.FamilyID)
+	return true
+}
+
+// 吊销签发给指定客户端的access_token，不是有效的access_token时返回false
+func revokeAccessToken(token string, clientID string) bool {
+	j := middlewares.NewJWT()
+	claims, err := j.ParseToken(token)
+	if err != nil || claims.ClientID != clientID {
+		return false
+	}
+	middlewares.RevokeToken(claims)
+	global.Lg.Info("RevokeToken", zap.Any("client_id", clientID), zap.Any("jti", claims.Id))
+	return true
+}
+
+// token内省（RFC 7662），供资源服务器校验token
+// 只返回标准字段，无效、过期、已吊销的token统一返回active=false
+func Introspect(c *gin.Context) {
+	client, ok := authenticateClient(c)
+	if !ok || client.IsPublic() {
+		response.OAuthErr(c, http.StatusUnauthorized, "invalid_client", "客户端认证失败")
+		return
+	}
+	token := c.PostForm("token")
+	if token == "" {
+		response.OAuthErr(c, http.StatusBadRequest, "invalid_request", "token不得为空")
+		return
+	}
+
+	j := middlewares.NewJWT()
+	if claims, err := j.ParseToken(token); err == nil {
+		result := map[string]interface{}{
+			"active":     true,
+			"token_type": "Bearer",
+			"sub":        strconv.Itoa(int(claims.ID)),
+			"username":   claims.NickName,
+			"exp":        claims.ExpiresAt,
+			"iat":        claims.IssuedAt,
+			"iss":        claims.Issuer,
+			"jti":        claims.Id,
+		}
+		// 服务token的主体是客户端
+		if claims.IsClientToken() {
+			result["sub"] = claims.ClientID
+			result["token_use"] = claims.TokenUse
+			delete(result, "username")
+		}
+		if claims.Scope != "" {
+			result["scope"] = claims.Scope
+		}
+		if claims.ClientID != "" {
+			result["client_id"] = claims.ClientID
+		}
+		if len(claims.Roles) > 0 {
+			result["roles"] = claims.Roles
+		}
+		if len(claims.Permissions) > 0 {
+			result["permissions"] = claims.Permissions
+		}
+		response.OAuth(c, result)
+		return
+	}
+	// 不是access_token时再按refresh_token查询，只有签发给调用方自己、还未轮换的refresh_token才返回详情
+	if record, ok := dao.GetActiveRefreshToken(token); ok && record.ClientID == client.ClientID {
+		response.OAuth(c, map[string]interface{}{
+			"active":     true,
+			"token_type": "refresh_token",
+			"sub":        strconv.Itoa(int(record.UserID)),
+			"scope":      record.Scope,
+			"client_id":  record.ClientID,
+		})
+		return
+	}
+	response.OAuth(c, map[string]interface{}{"active": false})
+}
+
+// token接口的标准返回
+func tokenResponse(tokens *tokenPair, scope string) map[string]interface{} {
+	return map[string]interface{}{
+		"access_token":  tokens.AccessToken,
+		"token_type":    "Bearer",
+		"expires_in":    tokens.ExpiresAt - time.Now().Unix(),
+		"refresh_token": tokens.RefreshToken,
+		"scope":         scope,
+	}
+}
+
+// 请求中是否携带了客户端凭证
+func hasClientCredentials(c *gin.Context) bool {
+	_, _, ok := c.Request.BasicAuth()
+	return ok || c.PostForm("client_id") != "" || c.PostForm("client_assertion") != ""
+}
+
+// 客户端认证，支持Basic头和表单两种方式传递client_id、client_secret，公开客户端只传client_id；
+// 配置了公钥的客户端可以改用client_assertion传私钥签名的JWT
+func authenticateClient(c *gin.Context) (*model.Client, bool) {
+	if assertionType := c.PostForm("client_assertion_type"); assertionType != "" {
+		return authenticateClientAssertion(c, assertionType)
+	}
+	clientID, clientSecret, ok := c.Request.BasicAuth()
+	if ok {
+		// Basic头中的凭证需要先做表单解码
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = c.PostForm("client_id")
+		clientSecret = c.PostForm("client_secret")
+	}
+	if clientID == "" {
+		return nil, false
+	}
+	return dao.VerifyClient(clientID, clientSecret)
+}
+
+// 用客户端断言认证（RFC 7523），aud必须是token接口地址或issuer，同一个断言只能使用一次
+func authenticateClientAssertion(c *gin.Context, assertionType string) (*model.Client, bool) {
+	if assertionType != utils.ClientAssertionType {
+		return nil, false
+	}
+	assertion := c.PostForm("client_assertion")
+	clientID := c.PostForm("client_id")
+	if clientID == "" {
+		clientID = utils.ClientAssertionSubject(assertion)
+	}
+	client, ok := dao.GetClientByClientID(clientID)
+	if !ok || client.PublicKey == "" {
+		return nil, false
+	}
+	issuer := strings.TrimSuffix(global.Settings.Issuer, "/")
+	result, err := utils.VerifyClientAssertion(assertion, client.ClientID, client.PublicKey, []string{issuer + "/oauth/token", issuer})
+	if err != nil {
+		global.Lg.Info("ClientAssertionInvalid", zap.Any("client_id", client.ClientID), zap.Error(err))
+		return nil, false
+	}
+	if !dao.UseClientAssertion(client.ClientID, result.ID, result.ExpiresAt) {
+		global.Lg.Warn("ClientAssertionReused", zap.Any("client_id", client.ClientID), zap.Any("jti", result.ID))
+		return nil, false
+	}
+	return client, true
+}
+
+// 拼接带code和state的回调地址
+func buildRedirectUri(redirectUri string, code string, state string) string {
+	u, err := url.Parse(redirectUri)
+	if err != nil {
+		return redirectUri
+	}
+	query := u.Query()
+	query.Set("code", code)
+	if state != "" {
+		query.Set("state", state)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// 回调地址是否允许：先精确匹配客户端注册的地址，再匹配env.toml中配置的通配规则
+// client为nil时只匹配对所有客户端生效的规则
+func isAllowedRedirect(client *model.Client, redirectUri string) bool {
+	if redirectUri == "" {
+		return false
+	}
+	clientID := ""
+	if client != nil {
+		if client.HasRedirectUri(redirectUri) {
+			return true
+		}
+		clientID = client.ClientID
+	}
+	for _, rule := range global.Settings.RedirectRules {
+		if rule.ClientID != "" && rule.ClientID != clientID {
+			continue
+		}
+		for _, pattern := range rule.Patterns {
+			if utils.MatchRedirectPattern(pattern, redirectUri) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 把授权码交给回调地址，调用前必须校验过回调地址
+// 浏览器直接访问时由服务端302跳转，ajax请求返回拼接好的回调地址
+func redirectWithCode(c *gin.Context, redirectUri string, code string, state string) {
+	target := buildRedirectUri(redirectUri, code, state)
+	if strings.Contains(c.GetHeader("Accept"), "text/html") {
+		c.Redirect(http.StatusFound, target)
+		return
+	}
+	response.Success(c, 200, "success", map[string]interface{}{
+		"code":         code,
+		"state":        state,
+		"redirect_uri": target,
+	})
+}
+
+// 把错误交给回调地址（RFC 6749 4.1.2.1），调用前必须校验过回调地址
+func redirectWithError(c *gin.Context, redirectUri string, errCode string, state string) {
+	target := redirectUri
+	if u, err := url.Parse(redirectUri); err == nil {
+		query := u.Query()
+		query.Set("error", errCode)
+		if state != "" {
+			query.Set("state", state)
+		}
+		u.RawQuery = query.Encode()
+		target = u.String()
+	}
+	if strings.Contains(c.GetHeader("Accept"), "text/html") {
+		c.Redirect(http.StatusFound, target)
+		return
+	}
+	response.Success(c, 200, "success", map[string]interface{}{
+		"error":        errCode,
+		"state":        state,
+		"redirect_uri": target,
+	})
+}
+
+// 授权码对应的组织：客户端属于某个组织时只有该组织的成员可以授权，其余客户端沿用当前登录选择的组织
+func authCodeOrg(client *model.Client, claims *middlewares.CustomClaims) (uint, bool) {
+	if client == nil || client.OrgID == 0 {
+		return claims.OrgID, true
+	}
+	if _, ok := dao.GetOrgMember(client.OrgID, claims.ID); !ok {
+		return 0, false
+	}
+	return client.OrgID, true
+}
+
+// 从上下文中取出JWTAuth中间件解析的claims
+func getClaims(c *gin.Context) (*middlewares.CustomClaims, bool) {
+	claims, exists := c.Get("claims")
+	if !exists {
+		return nil, false
+	}
+	jwtClaims, ok := claims.(*middlewares.CustomClaims)
+	return jwtClaims, ok
+}
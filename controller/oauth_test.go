@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"sso-go/config"
+	"sso-go/global"
+	"sso-go/model"
+	"testing"
+)
+
+func TestIsAllowedRedirect(t *testing.T) {
+	rules := global.Settings.RedirectRules
+	t.Cleanup(func() { global.Settings.RedirectRules = rules })
+	global.Settings.RedirectRules = []config.RedirectRule{
+		{Patterns: []string{"https://*.example.com/"}},
+		{ClientID: "client-1", Patterns: []string{"https://client1.test/cb"}},
+	}
+	client1 := &model.Client{ClientID: "client-1", RedirectUris: "https://app.test/callback https://app.test/other"}
+	client2 := &model.Client{ClientID: "client-2", RedirectUris: "https://app2.test/callback"}
+
+	tests := []struct {
+		name        string
+		client      *model.Client
+		redirectUri string
+		want        bool
+	}{
+		{"注册的地址", client1, "https://app.test/callback", true},
+		{"注册地址精确匹配", client1, "https://app.test/callback/x", false},
+		{"注册地址不带query", client1, "https://app.test/callback?x=1", false},
+		{"其他客户端注册的地址", client2, "https://app.test/callback", false},
+		{"所有客户端生效的规则", client2, "https://a.example.com/cb", true},
+		{"只对指定客户端生效的规则", client1, "https://client1.test/cb/next", true},
+		{"其他客户端的规则", client2, "https://client1.test/cb/next", false},
+		{"规则中的上级目录", client1, "https://client1.test/cb/../evil", false},
+		{"没有客户端只匹配通用规则", nil, "https://a.example.com/cb", true},
+		{"没有客户端不匹配指定客户端的规则", nil, "https://client1.test/cb", false},
+		{"空地址", client1, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isAllowedRedirect(tt.client, tt.redirectUri); got != tt.want {
+				t.Errorf("isAllowedRedirect(%q) = %v, want %v", tt.redirectUri, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"net/http"
+	"sso-go/dao"
+	"sso-go/global"
+	"sso-go/middlewares"
+	"sso-go/response"
+	"sso-go/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDC发现文档
+func Discovery(c *gin.Context) {
+	issuer := strings.TrimSuffix(global.Settings.Issuer, "/")
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"issuer":                                           issuer,
+		"authorization_endpoint":                           issuer + "/oauth/authorize",
+		"token_endpoint":                                   issuer + "/oauth/token",
+		"userinfo_endpoint":                                issuer + "/userinfo",
+		"jwks_uri":                                         issuer + "/.well-known/jwks.json",
+		"revocation_endpoint":                              issuer + "/oauth/revoke",
+		"introspection_endpoint":                           issuer + "/oauth/introspect",
+		"device_authorization_endpoint":                    issuer + "/oauth/device_authorization",
+		"response_types_supported":                         []string{"code"},
+		"grant_types_supported":                            []string{"authorization_code", "refresh_token", "client_credentials", deviceCodeGrantType},
+		"subject_types_supported":                          []string{"public"},
+		"id_token_signing_alg_values_supported":            middlewares.SigningAlgs(),
+		"scopes_supported":                                 []string{"openid", "profile", "email", "roles"},
+		"token_endpoint_auth_methods_supported":            []string{"client_secret_basic", "client_secret_post", "private_key_jwt", "none"},
+		"token_endpoint_auth_signing_alg_values_supported": []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"},
+		"code_challenge_methods_supported":                 []string{"S256", "plain"},
+		"claims_supported":                                 []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "preferred_username", "picture", "email", "email_verified", "roles"},
+	})
+}
+
+// 公钥集合，业务系统用来离线校验token签名
+func JWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"keys": middlewares.JWKS(),
+	})
+}
+
+// OIDC标准的用户信息接口
+func OIDCUserInfo(c *gin.Context) {
+	claims, ok := getClaims(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	// 服务token没有用户
+	user, ok := dao.GetActiveUserByID(claims.ID)
+	if !ok || claims.IsClientToken() {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	userInfo := map[string]interface{}{
+		"sub": strconv.Itoa(int(user.ID)),
+	}
+	// OAuth授权签发的token按scope返回，SSO自身登录签发的token没有scope限制
+	allScopes := claims.ClientID == ""
+	if allScopes || utils.HasScope(claims.Scope, "profile") {
+		userInfo["name"] = user.Name
+		userInfo["preferred_username"] = user.Name
+		userInfo["picture"] = utils.UserHeadUrl(user)
+	}
+	if allScopes || utils.HasScope(claims.Scope, "email") {
+		userInfo["email"] = user.Email
+		userInfo["email_verified"] = user.EmailVerifiedAt != ""
+	}
+	if allScopes || utils.HasScope(claims.Scope, "roles") {
+		roles, permissions := tokenAuthorization(user.ID, claims.ClientID, claims.Scope)
+		userInfo["roles"] = roles
+		userInfo["permissions"] = permissions
+	}
+	response.OAuth(c, userInfo)
+}
@@ -0,0 +1,300 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"sso-go/dao"
+	"sso-go/forms"
+	"sso-go/global"
+	"sso-go/middlewares"
+	"sso-go/model"
+	"sso-go/response"
+	"sso-go/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 组织标识只能包含小写字母、数字和-
+func validOrgSlug(slug string) bool {
+	for _, r := range slug {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return slug != ""
+}
+
+// 当前用户所属的组织
+func MyOrgs(c *gin.Context) {
+	claims, ok := getClaims(c)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "未登录", "")
+		return
+	}
+	response.Success(c, 200, "success", map[string]interface{}{
+		"current_org_id": claims.OrgID,
+		"orgs":           dao.GetUserOrgs(claims.ID),
+	})
+}
+
+// 切换组织，返回带新组织的token，当前token作废
+func SwitchOrg(c *gin.Context) {
+	switchParams := forms.SwitchOrgForm{}
+	if err := c.ShouldBind(&switchParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	user, ok := currentAccountUser(c)
+	if !ok {
+		return
+	}
+	if switchParams.OrgID != 0 {
+		if _, ok := dao.GetOrgMember(switchParams.OrgID, user.ID); !ok {
+			response.Err(c, http.StatusOK, 403, dao.ErrNotOrgMember.Error(), "")
+			return
+		}
+	}
+	// 沿用当前的登录会话，之后通过会话申请的授权码也属于新组织
+	claims, _ := getClaims(c)
+	if err := dao.UpdateSsoSessionOrg(claims.SessionID, switchParams.OrgID); err != nil {
+		response.Err(c, http.StatusOK, 500, "切换组织失败", err.Error())
+		return
+	}
+	userInfoMap, err := sessionTokens(user, switchParams.OrgID, claims.AuthTime, claims.SessionID)
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "token生成失败,重新再试", err.Error())
+		return
+	}
+	middlewares.RevokeToken(claims)
+	response.Success(c, 200, "success", userInfoMap)
+}
+
+// 组织列表
+func ListOrgs(c *gin.Context) {
+	orgs, err := dao.ListOrgs()
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "查询失败", err.Error())
+		return
+	}
+	response.Success(c, 200, "success", orgs)
+}
+
+// 创建组织，创建后通过成员接口添加owner
+func CreateOrg(c *gin.Context) {
+	orgParams := forms.OrgForm{}
+	if err := c.ShouldBind(&orgParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	slug := strings.TrimSpace(orgParams.Slug)
+	if !validOrgSlug(slug) {
+		response.Err(c, http.StatusOK, 400, "组织标识只能包含小写字母、数字和-", nil)
+		return
+	}
+	if _, ok := dao.GetOrgBySlug(slug); ok {
+		response.Err(c, http.StatusOK, 400, "组织标识已存在", nil)
+		return
+	}
+	org := model.Organization{Slug: slug, Name: orgParams.Name}
+	if err := dao.CreateOrg(&org); err != nil {
+		response.Err(c, http.StatusOK, 500, "创建失败", err.Error())
+		return
+	}
+	global.Lg.Info("AdminCreateOrg", zap.Any("admin", c.GetUint("userId")), zap.String("slug", slug))
+	response.Success(c, 200, "success", org)
+}
+
+// 删除组织，组织下还有客户端时不能删除
+func DeleteOrg(c *gin.Context) {
+	idParams := forms.UserIDForm{}
+	if err := c.ShouldBind(&idParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	if _, ok := dao.GetOrgByID(idParams.ID); !ok {
+		response.Err(c, http.StatusOK, 404, "组织不存在", nil)
+		return
+	}
+	if err := dao.DeleteOrg(idParams.ID); err != nil {
+		if errors.Is(err, dao.ErrOrgHasClient) {
+			response.Err(c, http.StatusOK, 400, err.Error(), nil)
+			return
+		}
+		response.Err(c, http.StatusOK, 500, "删除失败", err.Error())
+		return
+	}
+	global.Lg.Info("AdminDeleteOrg", zap.Any("admin", c.GetUint("userId")), zap.Any("org_id", idParams.ID))
+	response.Success(c, 200, "success", nil)
+}
+
+// 校验能否管理组织成员：拥有sso:orgs:manage权限的管理员可以管理所有组织，
+// 组织的owner可以管理本组织所有成员，admin只能管理普通成员；返回值为false时已经写入响应
+func orgManager(c *gin.Context, orgID uint) (*model.OrgMember, bool) {
+	claims, ok := getClaims(c)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "未登录", "")
+		return nil, false
+	}
+	if _, ok := dao.GetOrgByID(orgID); !ok {
+		response.Err(c, http.StatusOK, 404, "组织不存在", nil)
+		return nil, false
+	}
+	if orgsManager(claims, orgID) {
+		return &model.OrgMember{OrgID: orgID, UserID: claims.ID, Role: model.OrgRoleOwner}, true
+	}
+	if member, ok := dao.GetOrgMember(orgID, claims.ID); ok && member.CanManage() {
+		return member, true
+	}
+	response.Err(c, http.StatusOK, 403, "没有权限", "")
+	return nil, false
+}
+
+// 是否拥有sso:orgs:manage权限，组织内签发的token只能管理本组织
+func orgsManager(claims *middlewares.CustomClaims, orgID uint) bool {
+	return middlewares.HasPermission(claims, middlewares.PermissionOrgsManage) && (claims.OrgID == 0 || claims.OrgID == orgID)
+}
+
+// admin不能设置、修改owner和admin
+func canManageOrgRole(manager *model.OrgMember, role string) bool {
+	return manager.Role == model.OrgRoleOwner || role == model.OrgRoleMember
+}
+
+// 修改或移除owner时组织中至少要保留一个owner
+func lastOrgOwner(orgID uint, member *model.OrgMember) bool {
+	return member.Role == model.OrgRoleOwner && dao.CountOrgRole(orgID, model.OrgRoleOwner) <= 1
+}
+
+// 组织成员列表，按用户ID倒序游标分页
+func ListOrgMembers(c *gin.Context) {
+	listParams := forms.OrgMembersForm{}
+	if err := c.ShouldBindQuery(&listParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	if _, ok := orgManager(c, listParams.OrgID); !ok {
+		return
+	}
+	pageSize := listParams.Limit
+	if pageSize == 0 {
+		pageSize = 20
+	}
+	members, err := dao.ListOrgMembers(listParams.OrgID, listParams.Cursor, pageSize+1)
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "查询失败", err.Error())
+		return
+	}
+	var nextCursor uint
+	if len(members) > pageSize {
+		members = members[:pageSize]
+		nextCursor = members[pageSize-1].UserID
+	}
+	ids := make([]uint, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.UserID)
+	}
+	users := dao.GetUsersByIDs(ids)
+	list := make([]map[string]interface{}, 0, len(members))
+	for _, m := range members {
+		user, ok := users[m.UserID]
+		if !ok {
+			continue
+		}
+		item := HandleUserModelToMap(user)
+		item["role"] = m.Role
+		item["joined_at"] = m.CreatedAt
+		list = append(list, item)
+	}
+	response.Success(c, 200, "success", map[string]interface{}{
+		"list":        list,
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor > 0,
+	})
+}
+
+// 添加成员或修改成员的角色
+func SaveOrgMember(c *gin.Context) {
+	memberParams := forms.OrgMemberForm{}
+	if err := c.ShouldBind(&memberParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	manager, ok := orgManager(c, memberParams.OrgID)
+	if !ok {
+		return
+	}
+	if !canManageOrgRole(manager, memberParams.Role) {
+		response.Err(c, http.StatusOK, 403, "没有权限设置该角色", nil)
+		return
+	}
+	// 组织owner、admin只能修改已有成员，把其他账号加入组织需要sso:orgs:manage权限，
+	// 账号不存在和不是成员返回相同的结果，避免借此探测账号是否存在
+	claims, _ := getClaims(c)
+	user, userFound := dao.GetUserByAccount(memberParams.Account)
+	var member *model.OrgMember
+	isMember := false
+	if userFound {
+		member, isMember = dao.GetOrgMember(memberParams.OrgID, user.ID)
+	}
+	if !isMember && !orgsManager(claims, memberParams.OrgID) {
+		response.Err(c, http.StatusOK, 403, "该用户不是组织成员，添加新成员需要组织管理权限", nil)
+		return
+	}
+	if !userFound {
+		response.Err(c, http.StatusOK, 404, "用户不存在", nil)
+		return
+	}
+	if isMember {
+		if !canManageOrgRole(manager, member.Role) {
+			response.Err(c, http.StatusOK, 403, "没有权限修改该成员", nil)
+			return
+		}
+		if memberParams.Role != model.OrgRoleOwner && lastOrgOwner(memberParams.OrgID, member) {
+			response.Err(c, http.StatusOK, 400, "组织至少要保留一个owner", nil)
+			return
+		}
+	}
+	if err := dao.SaveOrgMember(memberParams.OrgID, user.ID, memberParams.Role); err != nil {
+		response.Err(c, http.StatusOK, 500, "保存失败", err.Error())
+		return
+	}
+	// 组织角色写在token中，变更后重新签发
+	refreshUserAuthorization(user.ID)
+	global.Lg.Info("SaveOrgMember", zap.Any("operator", manager.UserID), zap.Any("org_id", memberParams.OrgID), zap.Any("user_id", user.ID), zap.String("role", memberParams.Role))
+	response.Success(c, 200, "success", nil)
+}
+
+// 移除成员，被移除的用户在该组织下的登录全部失效
+func RemoveOrgMember(c *gin.Context) {
+	removeParams := forms.RemoveOrgMemberForm{}
+	if err := c.ShouldBind(&removeParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	manager, ok := orgManager(c, removeParams.OrgID)
+	if !ok {
+		return
+	}
+	member, ok := dao.GetOrgMember(removeParams.OrgID, removeParams.UserID)
+	if !ok {
+		response.Err(c, http.StatusOK, 404, "该用户不是组织成员", nil)
+		return
+	}
+	if !canManageOrgRole(manager, member.Role) {
+		response.Err(c, http.StatusOK, 403, "没有权限移除该成员", nil)
+		return
+	}
+	if lastOrgOwner(removeParams.OrgID, member) {
+		response.Err(c, http.StatusOK, 400, "组织至少要保留一个owner", nil)
+		return
+	}
+	if err := dao.DeleteOrgMember(removeParams.OrgID, removeParams.UserID); err != nil {
+		response.Err(c, http.StatusOK, 500, "移除失败", err.Error())
+		return
+	}
+	// access_token作废后，该组织下的refresh_token刷新时会因为不是成员而失败
+	refreshUserAuthorization(removeParams.UserID)
+	global.Lg.Info("RemoveOrgMember", zap.Any("operator", manager.UserID), zap.Any("org_id", removeParams.OrgID), zap.Any("user_id", removeParams.UserID))
+	response.Success(c, 200, "success", nil)
+}
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sso-go/dao"
+	"sso-go/forms"
+	"sso-go/global"
+	"sso-go/model"
+	"sso-go/response"
+	"sso-go/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 重置密码链接有效期
+const passwordResetExpire = 30 * time.Minute
+
+// 拼接重置密码链接，token放在查询参数中
+func passwordResetLink(token string) string {
+	link := global.Settings.ResetPasswordUrl
+	u, err := url.Parse(link)
+	if err != nil || link == "" {
+		return token
+	}
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// 忘记密码，向注册邮箱发送重置密码链接
+// 无论邮箱是否注册都返回同样的结果，避免被用来探测哪些邮箱已注册
+func ForgotPassword(c *gin.Context) {
+	forgotParams := forms.ForgotPasswordForm{}
+	if err := c.ShouldBind(&forgotParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	email := forgotParams.Email
+	// 和验证码共用发送频率限制，先于账号是否存在检查，两种情况的限制表现一致
+	if err := dao.AcquireEmailCodeQuota(email, c.ClientIP()); err != nil {
+		response.Err(c, http.StatusOK, 429, err.Error(), nil)
+		return
+	}
+	user, ok := dao.GetUserByEmail(email)
+	if !ok || user.Disabled() {
+		response.Success(c, 200, "success", nil)
+		return
+	}
+	token, err := dao.SavePasswordResetToken(user.ID, passwordResetExpire)
+	if err != nil {
+		global.Lg.Error("ForgotPassword", zap.Error(err))
+		response.Success(c, 200, "success", nil)
+		return
+	}
+	// 异步发送邮件，响应时间不受邮件发送影响
+	go func() {
+		if err := utils.SendPasswordResetEmail(user.Email, passwordResetLink(token), passwordResetExpire); err != nil {
+			global.Lg.Error("ForgotPassword", zap.String("email", user.Email), zap.Error(err))
+		}
+	}()
+	response.Success(c, 200, "success", nil)
+}
+
+// 使用邮件中的token重置密码，重置后该用户所有登录失效
+func ResetPassword(c *gin.Context) {
+	resetParams := forms.ResetPasswordForm{}
+	if err := c.ShouldBind(&resetParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	userID, ok := dao.TakePasswordResetToken(resetParams.Token)
+	if !ok {
+		response.Err(c, http.StatusOK, 400, "链接无效或已过期，请重新申请", nil)
+		return
+	}
+	user, ok := dao.GetActiveUserByID(userID)
+	if !ok {
+		response.Err(c, http.StatusOK, 400, "链接无效或已过期，请重新申请", nil)
+		return
+	}
+	if err := dao.UpdateUserPassword(user.ID, utils.HashAndSalt(resetParams.PassWord)); err != nil {
+		response.Err(c, http.StatusOK, 500, "重置密码失败", err.Error())
+		return
+	}
+	revokeUserSessions(user.ID)
+	// 能收到邮件说明是账号本人，解除账号的登录锁定
+	dao.ClearLoginFailures(dao.LoginAccountKey(user.Email))
+	response.Success(c, 200, "success", nil)
+}
+
+// 校验当前密码，和登录共用失败次数限制，防止拿到token后暴力猜测密码
+func verifyCurrentPassword(c *gin.Context, user *model.User, password string) bool {
+	accountKey := dao.LoginAccountKey(user.Email)
+	if wait := dao.LoginBlocked(accountKey, c.ClientIP()); wait > 0 {
+		seconds := int64(math.Ceil(wait.Seconds()))
+		response.Err(c, http.StatusOK, 429, fmt.Sprintf("密码错误次数过多，请%d秒后再试", seconds), map[string]interface{}{
+			"retry_after": seconds,
+		})
+		return false
+	}
+	if !utils.ComparePasswords(user.Password, password) {
+		dao.AddLoginFailure(accountKey, c.ClientIP())
+		response.Err(c, http.StatusOK, 400, "当前密码错误", nil)
+		return false
+	}
+	dao.ClearLoginFailures(accountKey)
+	return true
+}
+
+// 修改密码前取当前登录的用户，第三方客户端的token不能修改账号凭证
+func currentAccountUser(c *gin.Context) (*model.User, bool) {
+	claims, ok := getClaims(c)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "未登录", "")
+		return nil, false
+	}
+	if claims.ClientID != "" {
+		response.Err(c, http.StatusOK, 403, "请登录SSO后操作", "")
+		return nil, false
+	}
+	user, ok := dao.GetUserByID(claims.ID)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "用户不存在", "")
+		return nil, false
+	}
+	return user, true
+}
+
+// 当前token选择的组织，重新签发token时保持不变
+func currentOrgID(c *gin.Context) uint {
+	if claims, ok := getClaims(c); ok {
+		return claims.OrgID
+	}
+	return 0
+}
+
+// 修改密码，需要验证当前密码，修改后其他登录全部失效，返回新的token
+func ChangePassword(c *gin.Context) {
+	changeParams := forms.ChangePasswordForm{}
+	if err := c.ShouldBind(&changeParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	user, ok := currentAccountUser(c)
+	if !ok {
+		return
+	}
+	if !verifyCurrentPassword(c, user, changeParams.OldPassWord) {
+		return
+	}
+	if err := dao.UpdateUserPassword(user.ID, utils.HashAndSalt(changeParams.PassWord)); err != nil {
+		response.Err(c, http.StatusOK, 500, "修改密码失败", err.Error())
+		return
+	}
+	revokeUserSessions(user.ID)
+	notifySecurityChange(user.Email, "修改了登录密码")
+	global.Lg.Info("ChangePassword", zap.Any("user_id", user.ID))
+
+	userInfoMap, err := loginTokens(c, user, currentOrgID(c))
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "token生成失败,请重新登录", err.Error())
+		return
+	}
+	response.Success(c, 200, "success", userInfoMap)
+}
+
+// 异步发送账号安全通知邮件
+func notifySecurityChange(email string, notice string) {
+	go func() {
+		if err := utils.SendSecurityNoticeEmail(email, notice); err != nil {
+			global.Lg.Error("SecurityNotice", zap.String("email", email), zap.Error(err))
+		}
+	}()
+}
@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sso-go/dao"
+	"sso-go/forms"
+	"sso-go/global"
+	"sso-go/model"
+	"sso-go/response"
+	"sso-go/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 上传图片大小上限，默认2MB
+func avatarMaxSize() int64 {
+	if n := global.Settings.Avatar.MaxSize; n > 0 {
+		return n
+	}
+	return 2 << 20
+}
+
+// 生成的缩略图边长，第一个作为head_url，默认256和64
+func avatarSizes() []int {
+	var sizes []int
+	for _, size := range global.Settings.Avatar.Sizes {
+		if size > 0 && size <= 1024 {
+			sizes = append(sizes, size)
+		}
+	}
+	if len(sizes) == 0 {
+		return []int{256, 64}
+	}
+	return sizes
+}
+
+// 头像文件的key，同一次上传的各尺寸缩略图共用前缀
+func avatarKey(prefix string, size int) string {
+	return fmt.Sprintf("%s_%d.png", prefix, size)
+}
+
+// 删除头像文件，只删除存储在本服务中的头像
+func deleteAvatarFiles(headUrl string) {
+	key, ok := global.Storage.Key(headUrl)
+	if !ok || !strings.HasPrefix(key, "avatars/") {
+		return
+	}
+	i := strings.LastIndex(key, "_")
+	if i < 0 {
+		return
+	}
+	for _, size := range avatarSizes() {
+		if err := global.Storage.Delete(avatarKey(key[:i], size)); err != nil {
+			global.Lg.Error("DeleteAvatar", zap.String("key", key), zap.Error(err))
+		}
+	}
+}
+
+// 资料修改成功，返回最新的用户信息和重新签发的access_token
+func profileUpdated(c *gin.Context, user *model.User, extra map[string]interface{}) {
+	claims, _ := getClaims(c)
+	token, expiresAt, err := reissueAccessToken(claims, user)
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "token生成失败,请重新登录", err.Error())
+		return
+	}
+	data := HandleUserModelToMap(user)
+	data["token"] = token
+	data["expires_in"] = expiresAt - time.Now().Unix()
+	for k, v := range extra {
+		data[k] = v
+	}
+	response.Success(c, 200, "success", data)
+}
+
+// 修改用户名
+func UpdateProfile(c *gin.Context) {
+	profileParams := forms.ProfileForm{}
+	if err := c.ShouldBind(&profileParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	user, ok := currentAccountUser(c)
+	if !ok {
+		return
+	}
+	name := strings.TrimSpace(profileParams.Username)
+	// 用户名也用来登录，不能是邮箱格式，否则会和邮箱登录混淆
+	if utils.IsEmail(name) {
+		response.Err(c, http.StatusOK, 400, "用户名不能是邮箱", nil)
+		return
+	}
+	if other, ok := dao.GetUserByAccount(name); ok && other.ID != user.ID {
+		response.Err(c, http.StatusOK, 400, "该昵称已注册", nil)
+		return
+	}
+	if err := dao.UpdateUserProfile(user.ID, map[string]interface{}{"name": name}); err != nil {
+		response.Err(c, http.StatusOK, 500, "修改失败", err.Error())
+		return
+	}
+	user.Name = name
+	profileUpdated(c, user, nil)
+}
+
+// 上传头像，图片重新编码并裁剪成多个尺寸的正方形缩略图
+func UploadAvatar(c *gin.Context) {
+	user, ok := currentAccountUser(c)
+	if !ok {
+		return
+	}
+	maxSize := avatarMaxSize()
+	// 限制整个请求体的大小，超大文件不会被完整读取
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+	fileHeader, err := c.FormFile("avatar")
+	if err != nil {
+		response.Err(c, http.StatusOK, 400, "请选择要上传的图片", nil)
+		return
+	}
+	if fileHeader.Size > maxSize {
+		response.Err(c, http.StatusOK, 400, fmt.Sprintf("图片不能超过%dKB", maxSize>>10), nil)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.Err(c, http.StatusOK, 400, "读取图片失败", err.Error())
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		response.Err(c, http.StatusOK, 400, "读取图片失败", err.Error())
+		return
+	}
+	if int64(len(data)) > maxSize {
+		response.Err(c, http.StatusOK, 400, fmt.Sprintf("图片不能超过%dKB", maxSize>>10), nil)
+		return
+	}
+	img, err := utils.DecodeImage(data)
+	if err != nil {
+		response.Err(c, http.StatusOK, 400, err.Error(), nil)
+		return
+	}
+
+	// 每次上传使用新的文件名，避免浏览器、CDN缓存旧头像
+	prefix := fmt.Sprintf("avatars/%d/%s", user.ID, utils.GenerateHexCode(8))
+	avatars := map[string]string{}
+	var headUrl string
+	for i, size := range avatarSizes() {
+		thumb, err := utils.EncodePNG(utils.SquareThumbnail(img, size))
+		if err != nil {
+			response.Err(c, http.StatusOK, 500, "图片处理失败", err.Error())
+			return
+		}
+		url, err := global.Storage.Put(avatarKey(prefix, size), thumb, "image/png")
+		if err != nil {
+			response.Err(c, http.StatusOK, 500, "头像保存失败", err.Error())
+			return
+		}
+		avatars[fmt.Sprintf("%d", size)] = url
+		if i == 0 {
+			headUrl = url
+		}
+	}
+	if err := dao.UpdateUserProfile(user.ID, map[string]interface{}{"head_url": headUrl}); err != nil {
+		deleteAvatarFiles(headUrl)
+		response.Err(c, http.StatusOK, 500, "修改失败", err.Error())
+		return
+	}
+	deleteAvatarFiles(user.HeadUrl)
+	user.HeadUrl = headUrl
+	global.Lg.Info("UploadAvatar", zap.Any("user_id", user.ID), zap.String("head_url", headUrl))
+	profileUpdated(c, user, map[string]interface{}{"avatars": avatars})
+}
+
+// 删除头像，恢复为默认头像
+func DeleteAvatar(c *gin.Context) {
+	user, ok := currentAccountUser(c)
+	if !ok {
+		return
+	}
+	if err := dao.UpdateUserProfile(user.ID, map[string]interface{}{"head_url": ""}); err != nil {
+		response.Err(c, http.StatusOK, 500, "修改失败", err.Error())
+		return
+	}
+	deleteAvatarFiles(user.HeadUrl)
+	user.HeadUrl = ""
+	profileUpdated(c, user, nil)
+}
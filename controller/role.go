@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"sso-go/dao"
+	"sso-go/forms"
+	"sso-go/global"
+	"sso-go/model"
+	"sso-go/response"
+	"sso-go/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 角色、权限不存在属于参数错误，其他为数据库错误
+func roleErr(c *gin.Context, msg string, err error) {
+	if errors.Is(err, dao.ErrUnknownRole) || errors.Is(err, dao.ErrUnknownPermission) {
+		response.Err(c, http.StatusOK, 400, err.Error(), nil)
+		return
+	}
+	response.Err(c, http.StatusOK, 500, msg, err.Error())
+}
+
+// 角色列表，包含每个角色的权限
+func ListRoles(c *gin.Context) {
+	roles, err := dao.ListRoles()
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "查询失败", err.Error())
+		return
+	}
+	response.Success(c, 200, "success", roles)
+}
+
+// 创建角色
+func CreateRole(c *gin.Context) {
+	roleParams := forms.RoleForm{}
+	if err := c.ShouldBind(&roleParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	name := strings.TrimSpace(roleParams.Name)
+	if _, ok := dao.GetRoleByName(name); ok {
+		response.Err(c, http.StatusOK, 400, "角色已存在", nil)
+		return
+	}
+	role := model.Role{Name: name, Description: roleParams.Description}
+	if err := dao.CreateRole(&role, roleParams.Permissions); err != nil {
+		roleErr(c, "创建失败", err)
+		return
+	}
+	global.Lg.Info("AdminCreateRole", zap.Any("admin", c.GetUint("userId")), zap.String("role", name), zap.Strings("permissions", roleParams.Permissions))
+	created, _ := dao.GetRoleByID(role.ID)
+	response.Success(c, 200, "success", created)
+}
+
+// 修改角色的描述和权限，拥有该角色的用户需要刷新token后生效
+func UpdateRole(c *gin.Context) {
+	roleParams := forms.UpdateRoleForm{}
+	if err := c.ShouldBind(&roleParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	if _, ok := dao.GetRoleByID(roleParams.ID); !ok {
+		response.Err(c, http.StatusOK, 404, "角色不存在", nil)
+		return
+	}
+	if err := dao.UpdateRole(roleParams.ID, roleParams.Description, roleParams.Permissions); err != nil {
+		roleErr(c, "修改失败", err)
+		return
+	}
+	refreshUserAuthorization(dao.GetRoleUserIDs(roleParams.ID)...)
+	global.Lg.Info("AdminUpdateRole", zap.Any("admin", c.GetUint("userId")), zap.Any("role_id", roleParams.ID), zap.Strings("permissions", roleParams.Permissions))
+	role, _ := dao.GetRoleByID(roleParams.ID)
+	response.Success(c, 200, "success", role)
+}
+
+// 删除角色
+func DeleteRole(c *gin.Context) {
+	idParams := forms.UserIDForm{}
+	if err := c.ShouldBind(&idParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	if _, ok := dao.GetRoleByID(idParams.ID); !ok {
+		response.Err(c, http.StatusOK, 404, "角色不存在", nil)
+		return
+	}
+	// 删除前记下拥有该角色的用户
+	userIDs := dao.GetRoleUserIDs(idParams.ID)
+	if err := dao.DeleteRole(idParams.ID); err != nil {
+		response.Err(c, http.StatusOK, 500, "删除失败", err.Error())
+		return
+	}
+	refreshUserAuthorization(userIDs...)
+	global.Lg.Info("AdminDeleteRole", zap.Any("admin", c.GetUint("userId")), zap.Any("role_id", idParams.ID))
+	response.Success(c, 200, "success", nil)
+}
+
+// 权限列表
+func ListPermissions(c *gin.Context) {
+	permissions, err := dao.ListPermissions()
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "查询失败", err.Error())
+		return
+	}
+	response.Success(c, 200, "success", permissions)
+}
+
+// 创建权限
+func CreatePermission(c *gin.Context) {
+	permissionParams := forms.PermissionForm{}
+	if err := c.ShouldBind(&permissionParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	name := strings.TrimSpace(permissionParams.Name)
+	if dao.HasPermission(name) {
+		response.Err(c, http.StatusOK, 400, "权限已存在", nil)
+		return
+	}
+	permission := model.Permission{Name: name, Description: permissionParams.Description}
+	if err := dao.CreatePermission(&permission); err != nil {
+		response.Err(c, http.StatusOK, 500, "创建失败", err.Error())
+		return
+	}
+	global.Lg.Info("AdminCreatePermission", zap.Any("admin", c.GetUint("userId")), zap.String("permission", name))
+	response.Success(c, 200, "success", permission)
+}
+
+// 删除权限，同时从所有角色中移除
+func DeletePermission(c *gin.Context) {
+	idParams := forms.UserIDForm{}
+	if err := c.ShouldBind(&idParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	if _, ok := dao.GetPermissionByID(idParams.ID); !ok {
+		response.Err(c, http.StatusOK, 404, "权限不存在", nil)
+		return
+	}
+	userIDs := dao.GetPermissionUserIDs(idParams.ID)
+	if err := dao.DeletePermission(idParams.ID); err != nil {
+		response.Err(c, http.StatusOK, 500, "删除失败", err.Error())
+		return
+	}
+	refreshUserAuthorization(userIDs...)
+	global.Lg.Info("AdminDeletePermission", zap.Any("admin", c.GetUint("userId")), zap.Any("permission_id", idParams.ID))
+	response.Success(c, 200, "success", nil)
+}
+
+// 用户的角色和合并后的权限
+func GetUserRoles(c *gin.Context) {
+	idParams := forms.UserIDForm{}
+	if err := c.ShouldBindQuery(&idParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	user, ok := adminTargetUser(c, idParams.ID, true)
+	if !ok {
+		return
+	}
+	roles, permissions := dao.GetUserAuthorization(user.ID)
+	response.Success(c, 200, "success", map[string]interface{}{
+		"roles":       roles,
+		"permissions": permissions,
+	})
+}
+
+// 设置用户的角色，传入用户的全部角色，用户刷新token后生效
+func SetUserRoles(c *gin.Context) {
+	rolesParams := forms.UserRolesForm{}
+	if err := c.ShouldBind(&rolesParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	user, ok := adminTargetUser(c, rolesParams.ID, true)
+	if !ok {
+		return
+	}
+	if err := dao.SetUserRoles(user.ID, rolesParams.Roles); err != nil {
+		roleErr(c, "设置失败", err)
+		return
+	}
+	refreshUserAuthorization(user.ID)
+	global.Lg.Info("AdminSetUserRoles", zap.Any("admin", c.GetUint("userId")), zap.Any("user_id", user.ID), zap.Strings("roles", rolesParams.Roles))
+	roles, permissions := dao.GetUserAuthorization(user.ID)
+	response.Success(c, 200, "success", map[string]interface{}{
+		"roles":       roles,
+		"permissions": permissions,
+	})
+}
@@ -0,0 +1,316 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sso-go/dao"
+	"sso-go/forms"
+	"sso-go/global"
+	"sso-go/middlewares"
+	"sso-go/response"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SCIM 2.0（RFC 7643、RFC 7644）使用的schema
+const (
+	scimSchemaUser           = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimSchemaGroup          = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimSchemaListResponse   = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimSchemaPatchOp        = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	scimSchemaServiceConfig  = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	scimSchemaResourceType   = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	scimSchemaSchemaResource = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+)
+
+// 列表接口每页最多返回的条数
+const scimMaxResults = 200
+
+var errScimFilter = errors.New("不支持的filter，只支持用and连接的 属性 eq 值")
+
+// ScimAuth SCIM接口的鉴权中间件，校验命令行创建的SCIM token
+func ScimAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := middlewares.ExtractTokenFromHeader(c.GetHeader("Authorization"))
+		record, ok := dao.GetScimToken(token)
+		if !ok {
+			c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+			response.ScimErr(c, http.StatusUnauthorized, "", "SCIM token无效")
+			return
+		}
+		c.Set("scimTokenId", record.ID)
+		c.Next()
+	}
+}
+
+// SCIM接口的根地址，用于返回资源的location
+func scimBaseUrl() string {
+	return strings.TrimSuffix(global.Settings.Issuer, "/") + "/scim/v2"
+}
+
+// 读取请求体，SCIM客户端使用application/scim+json，不能依赖Content-Type绑定
+func bindScimJSON(c *gin.Context, obj interface{}) bool {
+	if err := json.NewDecoder(c.Request.Body).Decode(obj); err != nil {
+		response.ScimErr(c, http.StatusBadRequest, "invalidSyntax", "请求体不是合法的JSON")
+		return false
+	}
+	return true
+}
+
+// filter中的一个条件，attr已转为小写
+type scimCondition struct {
+	Attr  string
+	Value string
+}
+
+type scimFilterToken struct {
+	Text   string
+	Quoted bool
+}
+
+// 拆分filter，双引号内的内容作为一个整体
+func scimFilterTokens(filter string) ([]scimFilterToken, error) {
+	var tokens []scimFilterToken
+	for i := 0; i < len(filter); {
+		switch {
+		case filter[i] == ' ':
+			i++
+		case filter[i] == '"':
+			j := i + 1
+			for ; j < len(filter) && filter[j] != '"'; j++ {
+				if filter[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(filter) {
+				return nil, errScimFilter
+			}
+			var text string
+			if err := json.Unmarshal([]byte(filter[i:j+1]), &text); err != nil {
+				return nil, errScimFilter
+			}
+			tokens = append(tokens, scimFilterToken{Text: text, Quoted: true})
+			i = j + 1
+		default:
+			j := i
+			for j < len(filter) && filter[j] != ' ' {
+				j++
+			}
+			tokens = append(tokens, scimFilterToken{Text: filter[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// 解析filter，只支持 attr eq value 以及用and连接的多个条件，这也是IdP同步时实际使用的写法
+func parseScimFilter(filter string, schema string) ([]scimCondition, error) {
+	tokens, err := scimFilterTokens(filter)
+	if err != nil {
+		return nil, err
+	}
+	var conditions []scimCondition
+	for i := 0; i < len(tokens); i += 3 {
+		if i > 0 {
+			if tokens[i].Quoted || !strings.EqualFold(tokens[i].Text, "and") {
+				return nil, errScimFilter
+			}
+			i++
+		}
+		if i+3 > len(tokens) || tokens[i].Quoted || tokens[i+1].Quoted || !strings.EqualFold(tokens[i+1].Text, "eq") {
+			return nil, errScimFilter
+		}
+		attr := strings.TrimPrefix(strings.ToLower(tokens[i].Text), strings.ToLower(schema)+":")
+		conditions = append(conditions, scimCondition{Attr: attr, Value: tokens[i+2].Text})
+	}
+	return conditions, nil
+}
+
+// 分页参数，startIndex从1开始
+func scimPage(c *gin.Context) (forms.ScimListForm, int, int, bool) {
+	listParams := forms.ScimListForm{}
+	if err := c.ShouldBindQuery(&listParams); err != nil {
+		response.ScimErr(c, http.StatusBadRequest, "invalidValue", "startIndex和count必须是整数")
+		return listParams, 0, 0, false
+	}
+	offset := 0
+	if listParams.StartIndex > 1 {
+		offset = listParams.StartIndex - 1
+	}
+	count := 100
+	if listParams.Count != nil {
+		count = *listParams.Count
+	}
+	if count < 0 {
+		count = 0
+	}
+	if count > scimMaxResults {
+		count = scimMaxResults
+	}
+	return listParams, offset, count, true
+}
+
+// 列表响应
+func scimList(c *gin.Context, resources []map[string]interface{}, total int64, offset int) {
+	response.Scim(c, http.StatusOK, map[string]interface{}{
+		"schemas":      []string{scimSchemaListResponse},
+		"totalResults": total,
+		"startIndex":   offset + 1,
+		"itemsPerPage": len(resources),
+		"Resources":    resources,
+	})
+}
+
+// 校验PATCH请求的op，返回小写的op
+func scimPatchOp(c *gin.Context, operation forms.ScimPatchOperation) (string, bool) {
+	op := strings.ToLower(operation.Op)
+	if op != "add" && op != "replace" && op != "remove" {
+		response.ScimErr(c, http.StatusBadRequest, "invalidSyntax", "不支持的op："+operation.Op)
+		return "", false
+	}
+	return op, true
+}
+
+// 服务能力说明
+func ScimServiceProviderConfig(c *gin.Context) {
+	response.Scim(c, http.StatusOK, map[string]interface{}{
+		"schemas":          []string{scimSchemaServiceConfig},
+		"documentationUri": "",
+		"patch":            map[string]interface{}{"supported": true},
+		"bulk":             map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":           map[string]interface{}{"supported": true, "maxResults": scimMaxResults},
+		"changePassword":   map[string]interface{}{"supported": true},
+		"sort":             map[string]interface{}{"supported": false},
+		"etag":             map[string]interface{}{"supported": false},
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "使用 ./ssoService scim token create 创建的token",
+			"primary":     true,
+		}},
+		"meta": map[string]interface{}{
+			"resourceType": "ServiceProviderConfig",
+			"location":     scimBaseUrl() + "/ServiceProviderConfig",
+		},
+	})
+}
+
+func scimResourceTypes() []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"schemas":     []string{scimSchemaResourceType},
+			"id":          "User",
+			"name":        "User",
+			"endpoint":    "/Users",
+			"description": "用户账号",
+			"schema":      scimSchemaUser,
+			"meta":        map[string]interface{}{"resourceType": "ResourceType", "location": scimBaseUrl() + "/ResourceTypes/User"},
+		},
+		{
+			"schemas":     []string{scimSchemaResourceType},
+			"id":          "Group",
+			"name":        "Group",
+			"endpoint":    "/Groups",
+			"description": "用户组，对应不含SSO管理权限的角色",
+			"schema":      scimSchemaGroup,
+			"meta":        map[string]interface{}{"resourceType": "ResourceType", "location": scimBaseUrl() + "/ResourceTypes/Group"},
+		},
+	}
+}
+
+// 支持的资源类型
+func ScimResourceTypes(c *gin.Context) {
+	types := scimResourceTypes()
+	if id := c.Param("id"); id != "" {
+		for _, t := range types {
+			if t["id"] == id {
+				response.Scim(c, http.StatusOK, t)
+				return
+			}
+		}
+		response.ScimErr(c, http.StatusNotFound, "", "资源类型不存在")
+		return
+	}
+	scimList(c, types, int64(len(types)), 0)
+}
+
+func scimAttribute(name string, typ string, required bool, mutability string, uniqueness string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"type":        typ,
+		"multiValued": false,
+		"required":    required,
+		"caseExact":   false,
+		"mutability":  mutability,
+		"returned":    "default",
+		"uniqueness":  uniqueness,
+	}
+}
+
+func scimMultiAttribute(name string, mutability string, subAttributes ...map[string]interface{}) map[string]interface{} {
+	attr := scimAttribute(name, "complex", false, mutability, "none")
+	attr["multiValued"] = true
+	attr["subAttributes"] = subAttributes
+	return attr
+}
+
+func scimSchemas() []map[string]interface{} {
+	password := scimAttribute("password", "string", false, "writeOnly", "none")
+	password["returned"] = "never"
+	return []map[string]interface{}{
+		{
+			"schemas":     []string{scimSchemaSchemaResource},
+			"id":          scimSchemaUser,
+			"name":        "User",
+			"description": "用户账号",
+			"attributes": []map[string]interface{}{
+				scimAttribute("userName", "string", true, "readWrite", "server"),
+				scimAttribute("displayName", "string", false, "readOnly", "none"),
+				scimAttribute("active", "boolean", false, "readWrite", "none"),
+				password,
+				scimMultiAttribute("emails", "readWrite",
+					scimAttribute("value", "string", true, "readWrite", "server"),
+					scimAttribute("type", "string", false, "readWrite", "none"),
+					scimAttribute("primary", "boolean", false, "readWrite", "none"),
+				),
+				scimMultiAttribute("groups", "readOnly",
+					scimAttribute("value", "string", false, "readOnly", "none"),
+					scimAttribute("display", "string", false, "readOnly", "none"),
+				),
+			},
+			"meta": map[string]interface{}{"resourceType": "Schema", "location": scimBaseUrl() + "/Schemas/" + scimSchemaUser},
+		},
+		{
+			"schemas":     []string{scimSchemaSchemaResource},
+			"id":          scimSchemaGroup,
+			"name":        "Group",
+			"description": "用户组，对应不含SSO管理权限的角色",
+			"attributes": []map[string]interface{}{
+				scimAttribute("displayName", "string", true, "readWrite", "server"),
+				scimMultiAttribute("members", "readWrite",
+					scimAttribute("value", "string", false, "immutable", "none"),
+					scimAttribute("display", "string", false, "readOnly", "none"),
+				),
+			},
+			"meta": map[string]interface{}{"resourceType": "Schema", "location": scimBaseUrl() + "/Schemas/" + scimSchemaGroup},
+		},
+	}
+}
+
+// 支持的schema定义
+func ScimSchemas(c *gin.Context) {
+	schemas := scimSchemas()
+	if id := c.Param("id"); id != "" {
+		for _, s := range schemas {
+			if s["id"] == id {
+				response.Scim(c, http.StatusOK, s)
+				return
+			}
+		}
+		response.ScimErr(c, http.StatusNotFound, "", "schema不存在")
+		return
+	}
+	scimList(c, schemas, int64(len(schemas)), 0)
+}
@@ -0,0 +1,389 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sso-go/dao"
+	"sso-go/forms"
+	"sso-go/global"
+	"sso-go/model"
+	"sso-go/response"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SCIM组资源，对应不含SSO管理权限的角色，members为拥有该角色的用户
+func scimGroup(role *model.Role, withMembers bool) map[string]interface{} {
+	id := strconv.FormatUint(uint64(role.ID), 10)
+	resource := map[string]interface{}{
+		"schemas":     []string{scimSchemaGroup},
+		"id":          id,
+		"displayName": role.Name,
+		"meta": map[string]interface{}{
+			"resourceType": "Group",
+			"created":      role.CreatedAt,
+			"lastModified": role.UpdatedAt,
+			"location":     scimBaseUrl() + "/Groups/" + id,
+		},
+	}
+	if withMembers {
+		members := make([]map[string]interface{}, 0)
+		userIDs := dao.GetRoleUserIDs(role.ID)
+		users := dao.GetUsersByIDs(userIDs)
+		protected := scimProtectedUsers()
+		for _, userID := range userIDs {
+			user, ok := users[userID]
+			if !ok || protected[userID] {
+				continue
+			}
+			value := strconv.FormatUint(uint64(userID), 10)
+			members = append(members, map[string]interface{}{
+				"value":   value,
+				"display": user.Name,
+				"$ref":    scimBaseUrl() + "/Users/" + value,
+			})
+		}
+		resource["members"] = members
+	}
+	return resource
+}
+
+// 取路径中的组，包含SSO管理权限的角色不能通过SCIM查看和修改，避免IdP同步时授予管理权限
+func scimTargetGroup(c *gin.Context) (*model.Role, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err == nil {
+		if role, ok := dao.GetRoleByID(uint(id)); ok && !dao.RoleHasSsoPermission(role.ID) {
+			return role, true
+		}
+	}
+	response.ScimErr(c, http.StatusNotFound, "", "组不存在")
+	return nil, false
+}
+
+// 是否返回members，IdP同步大量组时通常用excludedAttributes=members减少数据量
+func scimGroupWithMembers(c *gin.Context) bool {
+	for _, attr := range strings.Split(c.Query("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(attr), "members") {
+			return false
+		}
+	}
+	return true
+}
+
+// 校验组名称，不能与其他角色重名
+func validScimGroupName(c *gin.Context, name string, excludeID uint) bool {
+	if n := utf8.RuneCountInString(name); n < 2 || n > 64 {
+		response.ScimErr(c, http.StatusBadRequest, "invalidValue", "displayName长度必须在2到64个字符之间")
+		return false
+	}
+	if other, ok := dao.GetRoleByName(name); ok && other.ID != excludeID {
+		response.ScimErr(c, http.StatusConflict, "uniqueness", "displayName已被使用")
+		return false
+	}
+	return true
+}
+
+// 解析成员的用户ID，用户必须存在，受保护的用户视为不存在
+func scimMemberIDs(c *gin.Context, members []forms.ScimMember) ([]uint, bool) {
+	var ids []uint
+	for _, member := range members {
+		id, err := strconv.ParseUint(member.Value, 10, 64)
+		if err != nil {
+			response.ScimErr(c, http.StatusBadRequest, "invalidValue", "成员不存在："+member.Value)
+			return nil, false
+		}
+		ids = append(ids, uint(id))
+	}
+	users := dao.GetUsersByIDs(ids)
+	protected := scimProtectedUsers()
+	for _, id := range ids {
+		if _, ok := users[id]; !ok || protected[id] {
+			response.ScimErr(c, http.StatusBadRequest, "invalidValue", "成员不存在："+strconv.FormatUint(uint64(id), 10))
+			return nil, false
+		}
+	}
+	return ids, true
+}
+
+// 修改组的名称和成员，members为修改后的完整成员；变动的用户已签发的access_token立即失效
+// 受保护的用户对SCIM不可见，IdP整体替换成员时保留他们原有的组
+func saveScimGroup(c *gin.Context, role *model.Role, name string, members map[uint]bool) bool {
+	current := dao.GetRoleUserIDs(role.ID)
+	protected := scimProtectedUsers()
+	changed := map[uint]bool{}
+	var removed []uint
+	for _, id := range current {
+		if !members[id] && !protected[id] {
+			removed = append(removed, id)
+			changed[id] = true
+		}
+		delete(members, id)
+	}
+	var added []uint
+	for id := range members {
+		added = append(added, id)
+		changed[id] = true
+	}
+	if name != role.Name {
+		if !validScimGroupName(c, name, role.ID) {
+			return false
+		}
+		if err := dao.RenameRole(role.ID, name); err != nil {
+			response.ScimErr(c, http.StatusInternalServerError, "", err.Error())
+			return false
+		}
+		// 角色名称写在token中，所有成员都需要换取新token
+		for _, id := range current {
+			changed[id] = true
+		}
+	}
+	if err := dao.RemoveRoleUsers(role.ID, removed); err != nil {
+		response.ScimErr(c, http.StatusInternalServerError, "", err.Error())
+		return false
+	}
+	if err := dao.AddRoleUsers(role.ID, added); err != nil {
+		response.ScimErr(c, http.StatusInternalServerError, "", err.Error())
+		return false
+	}
+	var userIDs []uint
+	for id := range changed {
+		userIDs = append(userIDs, id)
+	}
+	refreshUserAuthorization(userIDs...)
+	global.Lg.Info("ScimUpdateGroup", zap.Any("scim_token", c.GetUint("scimTokenId")), zap.Any("role_id", role.ID), zap.Any("added", added), zap.Any("removed", removed))
+	return true
+}
+
+// 返回组的最新数据
+func scimGroupResponse(c *gin.Context, status int, roleID uint) {
+	role, ok := dao.GetRoleByID(roleID)
+	if !ok {
+		response.ScimErr(c, http.StatusNotFound, "", "组不存在")
+		return
+	}
+	resource := scimGroup(role, true)
+	if status == http.StatusCreated {
+		c.Header("Location", resource["meta"].(map[string]interface{})["location"].(string))
+	}
+	response.Scim(c, status, resource)
+}
+
+// 组列表，支持按displayName过滤，startIndex、count分页
+func ScimListGroups(c *gin.Context) {
+	listParams, offset, count, ok := scimPage(c)
+	if !ok {
+		return
+	}
+	conditions, err := parseScimFilter(listParams.Filter, scimSchemaGroup)
+	if err != nil {
+		response.ScimErr(c, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	name := ""
+	for _, condition := range conditions {
+		switch condition.Attr {
+		case "displayname":
+			name = condition.Value
+		case "externalid":
+			scimList(c, []map[string]interface{}{}, 0, offset)
+			return
+		default:
+			response.ScimErr(c, http.StatusBadRequest, "invalidFilter", "不支持按"+condition.Attr+"过滤")
+			return
+		}
+	}
+	roles, total, err := dao.ListNonSsoRoles(name, offset, count)
+	if err != nil {
+		response.ScimErr(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	withMembers := scimGroupWithMembers(c)
+	resources := make([]map[string]interface{}, 0, len(roles))
+	if count > 0 {
+		for i := range roles {
+			resources = append(resources, scimGroup(&roles[i], withMembers))
+		}
+	}
+	scimList(c, resources, total, offset)
+}
+
+// 组详情
+func ScimGetGroup(c *gin.Context) {
+	role, ok := scimTargetGroup(c)
+	if !ok {
+		return
+	}
+	response.Scim(c, http.StatusOK, scimGroup(role, scimGroupWithMembers(c)))
+}
+
+// 创建组，即创建一个没有权限的角色，权限由管理员在后台分配
+func ScimCreateGroup(c *gin.Context) {
+	form := forms.ScimGroupForm{}
+	if !bindScimJSON(c, &form) {
+		return
+	}
+	name := strings.TrimSpace(form.DisplayName)
+	if !validScimGroupName(c, name, 0) {
+		return
+	}
+	memberIDs, ok := scimMemberIDs(c, form.Members)
+	if !ok {
+		return
+	}
+	role := model.Role{Name: name}
+	if err := dao.CreateRole(&role, nil); err != nil {
+		response.ScimErr(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	if err := dao.AddRoleUsers(role.ID, memberIDs); err != nil {
+		response.ScimErr(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	refreshUserAuthorization(memberIDs...)
+	global.Lg.Info("ScimCreateGroup", zap.Any("scim_token", c.GetUint("scimTokenId")), zap.Any("role_id", role.ID))
+	scimGroupResponse(c, http.StatusCreated, role.ID)
+}
+
+// 整体替换组的名称和成员
+func ScimReplaceGroup(c *gin.Context) {
+	role, ok := scimTargetGroup(c)
+	if !ok {
+		return
+	}
+	form := forms.ScimGroupForm{}
+	if !bindScimJSON(c, &form) {
+		return
+	}
+	memberIDs, ok := scimMemberIDs(c, form.Members)
+	if !ok {
+		return
+	}
+	members := map[uint]bool{}
+	for _, id := range memberIDs {
+		members[id] = true
+	}
+	if !saveScimGroup(c, role, strings.TrimSpace(form.DisplayName), members) {
+		return
+	}
+	scimGroupResponse(c, http.StatusOK, role.ID)
+}
+
+// 按操作修改成员集合，add、remove、replace分别对应加入、移除、替换
+func applyScimMembers(c *gin.Context, members map[uint]bool, op string, value json.RawMessage) bool {
+	var list []forms.ScimMember
+	if len(value) > 0 && json.Unmarshal(value, &list) != nil {
+		response.ScimErr(c, http.StatusBadRequest, "invalidValue", "members的值不正确")
+		return false
+	}
+	ids, ok := scimMemberIDs(c, list)
+	if !ok {
+		return false
+	}
+	if op == "replace" || op == "remove" && len(list) == 0 {
+		for id := range members {
+			delete(members, id)
+		}
+	}
+	for _, id := range ids {
+		if op == "remove" {
+			delete(members, id)
+		} else {
+			members[id] = true
+		}
+	}
+	return true
+}
+
+// 按PATCH操作修改组，IdP通常用 add/remove members 增量同步成员
+func ScimPatchGroup(c *gin.Context) {
+	role, ok := scimTargetGroup(c)
+	if !ok {
+		return
+	}
+	patch := forms.ScimPatchForm{}
+	if !bindScimJSON(c, &patch) {
+		return
+	}
+	name := role.Name
+	members := map[uint]bool{}
+	for _, id := range dao.GetRoleUserIDs(role.ID) {
+		members[id] = true
+	}
+	for _, operation := range patch.Operations {
+		op, ok := scimPatchOp(c, operation)
+		if !ok {
+			return
+		}
+		path := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(operation.Path)), strings.ToLower(scimSchemaGroup)+":")
+		switch {
+		case path == "":
+			var values map[string]json.RawMessage
+			if op == "remove" || json.Unmarshal(operation.Value, &values) != nil {
+				response.ScimErr(c, http.StatusBadRequest, "invalidValue", "没有path时value必须是对象")
+				return
+			}
+			for attr, value := range values {
+				switch strings.ToLower(attr) {
+				case "displayname":
+					if json.Unmarshal(value, &name) != nil {
+						response.ScimErr(c, http.StatusBadRequest, "invalidValue", "displayName的值不正确")
+						return
+					}
+				case "members":
+					if !applyScimMembers(c, members, op, value) {
+						return
+					}
+				}
+			}
+		case path == "displayname":
+			if op == "remove" {
+				response.ScimErr(c, http.StatusBadRequest, "mutability", "不能删除displayName")
+				return
+			}
+			if json.Unmarshal(operation.Value, &name) != nil {
+				response.ScimErr(c, http.StatusBadRequest, "invalidValue", "displayName的值不正确")
+				return
+			}
+		case path == "members":
+			if !applyScimMembers(c, members, op, operation.Value) {
+				return
+			}
+		case strings.HasPrefix(path, "members[") && strings.HasSuffix(path, "]") && op == "remove":
+			// 移除单个成员：members[value eq "2"]
+			conditions, err := parseScimFilter(path[len("members["):len(path)-1], "")
+			if err != nil || len(conditions) != 1 || conditions[0].Attr != "value" {
+				response.ScimErr(c, http.StatusBadRequest, "invalidPath", "不支持的path："+operation.Path)
+				return
+			}
+			id, _ := strconv.ParseUint(conditions[0].Value, 10, 64)
+			delete(members, uint(id))
+		default:
+			response.ScimErr(c, http.StatusBadRequest, "invalidPath", "不支持的path："+operation.Path)
+			return
+		}
+	}
+	if !saveScimGroup(c, role, strings.TrimSpace(name), members) {
+		return
+	}
+	scimGroupResponse(c, http.StatusOK, role.ID)
+}
+
+// 删除组，即删除对应的角色，成员已签发的access_token立即失效
+func ScimDeleteGroup(c *gin.Context) {
+	role, ok := scimTargetGroup(c)
+	if !ok {
+		return
+	}
+	userIDs := dao.GetRoleUserIDs(role.ID)
+	if err := dao.DeleteRole(role.ID); err != nil {
+		response.ScimErr(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	refreshUserAuthorization(userIDs...)
+	global.Lg.Info("ScimDeleteGroup", zap.Any("scim_token", c.GetUint("scimTokenId")), zap.Any("role_id", role.ID), zap.String("name", role.Name))
+	response.Scim(c, http.StatusNoContent, nil)
+}
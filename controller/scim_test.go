@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sso-go/forms"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseScimFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		want    []scimCondition
+		wantErr bool
+	}{
+		{"空", "", nil, false},
+		{"单个条件", `userName eq "zhangsan"`, []scimCondition{{"username", "zhangsan"}}, false},
+		{"属性和运算符不区分大小写", `USERNAME EQ "zhangsan"`, []scimCondition{{"username", "zhangsan"}}, false},
+		{"带schema前缀", `urn:ietf:params:scim:schemas:core:2.0:User:userName eq "a"`, []scimCondition{{"username", "a"}}, false},
+		{"and连接", `userName eq "a" and emails.value eq "a@example.com"`,
+			[]scimCondition{{"username", "a"}, {"emails.value", "a@example.com"}}, false},
+		{"值中的空格和转义", `displayName eq "Zhang \"San\" and eq"`, []scimCondition{{"displayname", `Zhang "San" and eq`}}, false},
+		{"值不加引号", `active eq true`, []scimCondition{{"active", "true"}}, false},
+		{"不支持的运算符", `userName co "a"`, nil, true},
+		{"不支持or", `userName eq "a" or userName eq "b"`, nil, true},
+		{"条件不完整", `userName eq`, nil, true},
+		{"and后没有条件", `userName eq "a" and`, nil, true},
+		{"引号不闭合", `userName eq "a`, nil, true},
+		{"属性加引号", `"userName" eq "a"`, nil, true},
+		{"运算符加引号", `userName "eq" "a"`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseScimFilter(tt.filter, scimSchemaUser)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseScimFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseScimFilter() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScimPrimaryEmail(t *testing.T) {
+	tests := []struct {
+		name   string
+		emails []forms.ScimEmail
+		want   string
+	}{
+		{"没有邮箱", nil, ""},
+		{"取primary", []forms.ScimEmail{{Value: "a@example.com"}, {Value: " b@example.com ", Primary: true}}, "b@example.com"},
+		{"没有primary取第一个", []forms.ScimEmail{{Value: "a@example.com"}, {Value: "b@example.com"}}, "a@example.com"},
+	}
+	for _, tt := range tests {
+		if got := scimPrimaryEmail(tt.emails); got != tt.want {
+			t.Errorf("%s：scimPrimaryEmail() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyScimUserAttr(t *testing.T) {
+	active := func(v bool) *bool { return &v }
+	tests := []struct {
+		name  string
+		attr  string
+		value string
+		want  forms.ScimUserForm
+		ok    bool
+	}{
+		{"禁用账号", "active", `false`, forms.ScimUserForm{Active: active(false)}, true},
+		{"字符串False", "active", `"False"`, forms.ScimUserForm{Active: active(false)}, true},
+		{"字符串True", "active", `"True"`, forms.ScimUserForm{Active: active(true)}, true},
+		{"active格式错误", "active", `"no"`, forms.ScimUserForm{}, false},
+		{"active为数字", "active", `0`, forms.ScimUserForm{}, false},
+		{"用户名", "username", `"lisi"`, forms.ScimUserForm{UserName: "lisi"}, true},
+		{"密码", "password", `"secret123"`, forms.ScimUserForm{Password: "secret123"}, true},
+		{"邮箱列表", "emails", `[{"value":"a@example.com","primary":true}]`,
+			forms.ScimUserForm{Emails: []forms.ScimEmail{{Value: "a@example.com", Primary: true}}}, true},
+		{"带过滤的邮箱路径", `emails[type eq "work"].value`, `"b@example.com"`,
+			forms.ScimUserForm{Emails: []forms.ScimEmail{{Value: "b@example.com", Primary: true}}}, true},
+		{"邮箱格式错误", "emails.value", `1`, forms.ScimUserForm{}, false},
+		{"不支持的属性忽略", "name.givenname", `"San"`, forms.ScimUserForm{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := forms.ScimUserForm{}
+			ok := applyScimUserAttr(&form, tt.attr, json.RawMessage(tt.value))
+			if ok != tt.ok {
+				t.Fatalf("applyScimUserAttr() = %v, want %v", ok, tt.ok)
+			}
+			if ok && !reflect.DeepEqual(form, tt.want) {
+				t.Errorf("form = %+v, want %+v", form, tt.want)
+			}
+		})
+	}
+}
+
+func TestScimPatchOp(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for op, want := range map[string]string{"add": "add", "Replace": "replace", "REMOVE": "remove", "move": ""} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		got, ok := scimPatchOp(c, forms.ScimPatchOperation{Op: op})
+		if got != want || ok != (want != "") {
+			t.Errorf("scimPatchOp(%q) = %q, %v", op, got, ok)
+		}
+		if !ok && w.Code != http.StatusBadRequest {
+			t.Errorf("scimPatchOp(%q) status = %d, want 400", op, w.Code)
+		}
+	}
+}
@@ -0,0 +1,413 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sso-go/dao"
+	"sso-go/forms"
+	"sso-go/global"
+	"sso-go/middlewares"
+	"sso-go/model"
+	"sso-go/response"
+	"sso-go/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+// SCIM用户资源，userName对应用户名，emails对应邮箱，active对应是否禁用，groups对应不含SSO管理权限的角色
+func scimUser(user *model.User, ssoRoles map[uint]bool) map[string]interface{} {
+	id := strconv.FormatUint(uint64(user.ID), 10)
+	groups := make([]map[string]interface{}, 0)
+	for _, role := range dao.GetUserRoles(user.ID) {
+		if ssoRoles[role.ID] {
+			continue
+		}
+		roleID := strconv.FormatUint(uint64(role.ID), 10)
+		groups = append(groups, map[string]interface{}{
+			"value":   roleID,
+			"display": role.Name,
+			"$ref":    scimBaseUrl() + "/Groups/" + roleID,
+		})
+	}
+	return map[string]interface{}{
+		"schemas":     []string{scimSchemaUser},
+		"id":          id,
+		"userName":    user.Name,
+		"displayName": user.Name,
+		"active":      !user.Disabled(),
+		"emails": []map[string]interface{}{
+			{"value": user.Email, "type": "work", "primary": true},
+		},
+		"groups": groups,
+		"meta": map[string]interface{}{
+			"resourceType": "User",
+			"created":      user.CreatedAt,
+			"lastModified": user.UpdatedAt,
+			"location":     scimBaseUrl() + "/Users/" + id,
+		},
+	}
+}
+
+// SCIM不能查看和修改的用户：env.toml中配置的管理员和拥有SSO管理权限的用户，与组的规则一致，避免IdP的token泄露后被用来接管管理员账号
+func scimProtectedUsers() map[uint]bool {
+	protected := map[uint]bool{}
+	for _, id := range middlewares.AdminUserIDs() {
+		protected[id] = true
+	}
+	for _, id := range dao.GetSsoRoleUserIDs() {
+		protected[id] = true
+	}
+	return protected
+}
+
+// 取路径中的用户，受保护的用户视为不存在
+func scimTargetUser(c *gin.Context) (*model.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err == nil && !scimProtectedUsers()[uint(id)] {
+		if user, ok := dao.GetUserByID(uint(id)); ok {
+			return user, true
+		}
+	}
+	response.ScimErr(c, http.StatusNotFound, "", "用户不存在")
+	return nil, false
+}
+
+// SCIM请求中的主邮箱，没有标记primary时取第一个
+func scimPrimaryEmail(emails []forms.ScimEmail) string {
+	for _, email := range emails {
+		if email.Primary {
+			return strings.TrimSpace(email.Value)
+		}
+	}
+	if len(emails) > 0 {
+		return strings.TrimSpace(emails[0].Value)
+	}
+	return ""
+}
+
+// 按本地账号的规则校验SCIM用户，用户名、邮箱不能被其他账号使用
+func validScimUser(c *gin.Context, form *forms.ScimUserForm, excludeID uint) (string, string, bool) {
+	fields := forms.ScimUserFields{
+		Username: strings.TrimSpace(form.UserName),
+		Email:    scimPrimaryEmail(form.Emails),
+		PassWord: form.Password,
+	}
+	if err := binding.Validator.ValidateStruct(&fields); err != nil {
+		detail := err.Error()
+		if errs, ok := err.(validator.ValidationErrors); ok {
+			var msgs []string
+			for _, msg := range errs.Translate(global.Trans) {
+				msgs = append(msgs, msg)
+			}
+			sort.Strings(msgs)
+			detail = strings.Join(msgs, "；")
+		}
+		response.ScimErr(c, http.StatusBadRequest, "invalidValue", detail)
+		return "", "", false
+	}
+	// 用户名也用来登录，不能是邮箱格式
+	if utils.IsEmail(fields.Username) {
+		response.ScimErr(c, http.StatusBadRequest, "invalidValue", "userName不能是邮箱")
+		return "", "", false
+	}
+	if other, ok := dao.GetUserByAccount(fields.Username); ok && other.ID != excludeID {
+		response.ScimErr(c, http.StatusConflict, "uniqueness", "userName已被使用")
+		return "", "", false
+	}
+	if other, ok := dao.GetUserByEmail(fields.Email); ok && other.ID != excludeID {
+		response.ScimErr(c, http.StatusConflict, "uniqueness", "邮箱已被使用")
+		return "", "", false
+	}
+	// 管理员按邮箱识别，不能通过SCIM创建或改成管理员邮箱
+	if middlewares.IsAdminEmail(fields.Email) {
+		response.ScimErr(c, http.StatusForbidden, "", "不能使用管理员邮箱")
+		return "", "", false
+	}
+	return fields.Username, fields.Email, true
+}
+
+// 按SCIM请求更新用户，active为false时禁用账号并立即作废所有登录
+func saveScimUser(c *gin.Context, user *model.User, form *forms.ScimUserForm) bool {
+	name, email, ok := validScimUser(c, form, user.ID)
+	if !ok {
+		return false
+	}
+	fields := map[string]interface{}{}
+	if name != user.Name {
+		fields["name"] = name
+	}
+	if email != user.Email {
+		fields["email"] = email
+		fields["email_verified_at"] = utils.GetNowFormatTime()
+	}
+	if form.Password != "" {
+		fields["password"] = utils.HashAndSalt(form.Password)
+	}
+	if len(fields) > 0 {
+		if err := dao.UpdateUserProfile(user.ID, fields); err != nil {
+			response.ScimErr(c, http.StatusInternalServerError, "", err.Error())
+			return false
+		}
+		_, emailChanged := fields["email"]
+		_, passwordChanged := fields["password"]
+		if emailChanged || passwordChanged {
+			revokeUserSessions(user.ID)
+		}
+	}
+	if form.Active != nil && *form.Active == user.Disabled() {
+		var err error
+		if *form.Active {
+			err = enableUser(user)
+		} else {
+			err = disableUser(user)
+		}
+		if err != nil {
+			response.ScimErr(c, http.StatusInternalServerError, "", err.Error())
+			return false
+		}
+	}
+	global.Lg.Info("ScimUpdateUser", zap.Any("scim_token", c.GetUint("scimTokenId")), zap.Any("user_id", user.ID), zap.Any("active", form.Active))
+	return true
+}
+
+// 返回用户的最新数据
+func scimUserResponse(c *gin.Context, status int, userID uint) {
+	user, ok := dao.GetUserByID(userID)
+	if !ok {
+		response.ScimErr(c, http.StatusNotFound, "", "用户不存在")
+		return
+	}
+	resource := scimUser(user, dao.GetSsoRoleIDs())
+	if status == http.StatusCreated {
+		c.Header("Location", resource["meta"].(map[string]interface{})["location"].(string))
+	}
+	response.Scim(c, status, resource)
+}
+
+// 用户列表，支持按userName、emails、active过滤，startIndex、count分页
+func ScimListUsers(c *gin.Context) {
+	listParams, offset, count, ok := scimPage(c)
+	if !ok {
+		return
+	}
+	conditions, err := parseScimFilter(listParams.Filter, scimSchemaUser)
+	if err != nil {
+		response.ScimErr(c, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	query := dao.UserQuery{Offset: offset, Asc: true, Limit: count}
+	for id := range scimProtectedUsers() {
+		query.ExcludeIDs = append(query.ExcludeIDs, id)
+	}
+	for _, condition := range conditions {
+		switch condition.Attr {
+		case "username":
+			query.Name = condition.Value
+		case "emails", "emails.value":
+			query.Email = condition.Value
+		case "active":
+			disabled := !strings.EqualFold(condition.Value, "true")
+			query.Disabled = &disabled
+		case "externalid":
+			// 不保存externalId，IdP按externalId查找时返回空列表，随后会按userName创建或匹配
+			scimList(c, []map[string]interface{}{}, 0, offset)
+			return
+		default:
+			response.ScimErr(c, http.StatusBadRequest, "invalidFilter", "不支持按"+condition.Attr+"过滤")
+			return
+		}
+	}
+	total, err := dao.CountUsers(query)
+	if err != nil {
+		response.ScimErr(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	resources := make([]map[string]interface{}, 0)
+	if count > 0 && int64(offset) < total {
+		users, err := dao.ListUsers(query)
+		if err != nil {
+			response.ScimErr(c, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+		ssoRoles := dao.GetSsoRoleIDs()
+		for i := range users {
+			resources = append(resources, scimUser(&users[i], ssoRoles))
+		}
+	}
+	scimList(c, resources, total, offset)
+}
+
+// 用户详情
+func ScimGetUser(c *gin.Context) {
+	user, ok := scimTargetUser(c)
+	if !ok {
+		return
+	}
+	response.Scim(c, http.StatusOK, scimUser(user, dao.GetSsoRoleIDs()))
+}
+
+// 创建用户，没有传密码时生成随机密码，用户通过忘记密码设置自己的密码
+func ScimCreateUser(c *gin.Context) {
+	form := forms.ScimUserForm{}
+	if !bindScimJSON(c, &form) {
+		return
+	}
+	name, email, ok := validScimUser(c, &form, 0)
+	if !ok {
+		return
+	}
+	password := form.Password
+	if password == "" {
+		password = utils.GenerateCode()
+	}
+	user := model.User{
+		Name:            name,
+		Email:           email,
+		EmailVerifiedAt: utils.GetNowFormatTime(),
+		Password:        utils.HashAndSalt(password),
+	}
+	if err := dao.CreateUser(&user); err != nil {
+		response.ScimErr(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	if form.Active != nil && !*form.Active {
+		if err := disableUser(&user); err != nil {
+			response.ScimErr(c, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+	}
+	global.Lg.Info("ScimCreateUser", zap.Any("scim_token", c.GetUint("scimTokenId")), zap.Any("user_id", user.ID))
+	scimUserResponse(c, http.StatusCreated, user.ID)
+}
+
+// 整体替换用户，没有传active时保持原状态
+func ScimReplaceUser(c *gin.Context) {
+	user, ok := scimTargetUser(c)
+	if !ok {
+		return
+	}
+	form := forms.ScimUserForm{}
+	if !bindScimJSON(c, &form) {
+		return
+	}
+	if !saveScimUser(c, user, &form) {
+		return
+	}
+	scimUserResponse(c, http.StatusOK, user.ID)
+}
+
+// 修改用户的一个属性，不支持的属性（如name、title）忽略
+func applyScimUserAttr(form *forms.ScimUserForm, attr string, value json.RawMessage) bool {
+	switch {
+	case attr == "username":
+		return json.Unmarshal(value, &form.UserName) == nil
+	case attr == "password":
+		return json.Unmarshal(value, &form.Password) == nil
+	case attr == "active":
+		// 部分IdP传字符串"True"、"False"
+		var active interface{}
+		if json.Unmarshal(value, &active) != nil {
+			return false
+		}
+		switch v := active.(type) {
+		case bool:
+			form.Active = &v
+		case string:
+			b, err := strconv.ParseBool(strings.ToLower(v))
+			if err != nil {
+				return false
+			}
+			form.Active = &b
+		default:
+			return false
+		}
+	case attr == "emails":
+		var emails []forms.ScimEmail
+		if json.Unmarshal(value, &emails) != nil {
+			return false
+		}
+		form.Emails = emails
+	case attr == "emails.value" || strings.HasPrefix(attr, "emails[") && strings.HasSuffix(attr, "].value"):
+		var email string
+		if json.Unmarshal(value, &email) != nil {
+			return false
+		}
+		form.Emails = []forms.ScimEmail{{Value: email, Primary: true}}
+	}
+	return true
+}
+
+// 按PATCH操作修改用户，禁用账号通常由IdP以 replace active=false 的方式发起
+func ScimPatchUser(c *gin.Context) {
+	user, ok := scimTargetUser(c)
+	if !ok {
+		return
+	}
+	patch := forms.ScimPatchForm{}
+	if !bindScimJSON(c, &patch) {
+		return
+	}
+	active := !user.Disabled()
+	form := forms.ScimUserForm{
+		UserName: user.Name,
+		Emails:   []forms.ScimEmail{{Value: user.Email, Primary: true}},
+		Active:   &active,
+	}
+	for _, operation := range patch.Operations {
+		op, ok := scimPatchOp(c, operation)
+		if !ok {
+			return
+		}
+		path := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(operation.Path)), strings.ToLower(scimSchemaUser)+":")
+		if op == "remove" {
+			if path == "username" || strings.HasPrefix(path, "emails") || path == "active" {
+				response.ScimErr(c, http.StatusBadRequest, "mutability", "不能删除"+operation.Path)
+				return
+			}
+			continue
+		}
+		if path == "" {
+			var values map[string]json.RawMessage
+			if json.Unmarshal(operation.Value, &values) != nil {
+				response.ScimErr(c, http.StatusBadRequest, "invalidValue", "没有path时value必须是对象")
+				return
+			}
+			for attr, value := range values {
+				attr = strings.TrimPrefix(strings.ToLower(attr), strings.ToLower(scimSchemaUser)+":")
+				if !applyScimUserAttr(&form, attr, value) {
+					response.ScimErr(c, http.StatusBadRequest, "invalidValue", attr+"的值不正确")
+					return
+				}
+			}
+			continue
+		}
+		if !applyScimUserAttr(&form, path, operation.Value) {
+			response.ScimErr(c, http.StatusBadRequest, "invalidValue", operation.Path+"的值不正确")
+			return
+		}
+	}
+	if !saveScimUser(c, user, &form) {
+		return
+	}
+	scimUserResponse(c, http.StatusOK, user.ID)
+}
+
+// 删除用户，同时作废所有登录
+func ScimDeleteUser(c *gin.Context) {
+	user, ok := scimTargetUser(c)
+	if !ok {
+		return
+	}
+	if err := deleteUser(user); err != nil {
+		response.ScimErr(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	global.Lg.Info("ScimDeleteUser", zap.Any("scim_token", c.GetUint("scimTokenId")), zap.Any("user_id", user.ID), zap.String("email", user.Email))
+	response.Scim(c, http.StatusNoContent, nil)
+}
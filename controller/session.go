@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"net/http"
+	"net/url"
+	"sso-go/dao"
+	"sso-go/global"
+	"sso-go/middlewares"
+	"sso-go/model"
+	"sso-go/response"
+	"sso-go/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 登录会话cookie名称
+func sessionCookieName() string {
+	if name := global.Settings.Session.CookieName; name != "" {
+		return name
+	}
+	return "sso_session"
+}
+
+func sessionSameSite() http.SameSite {
+	switch strings.ToLower(global.Settings.Session.SameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// 写入登录会话cookie，前端脚本不能读取
+func setSessionCookie(c *gin.Context, value string) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookieName(),
+		Value:    value,
+		Path:     "/",
+		Domain:   global.Settings.Session.Domain,
+		MaxAge:   int(utils.SessionExpireSeconds()),
+		Secure:   !global.Settings.Session.Insecure,
+		HttpOnly: true,
+		SameSite: sessionSameSite(),
+	})
+}
+
+// 清除登录会话cookie
+func clearSessionCookie(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookieName(),
+		Value:    "",
+		Path:     "/",
+		Domain:   global.Settings.Session.Domain,
+		MaxAge:   -1,
+		Secure:   !global.Settings.Session.Insecure,
+		HttpOnly: true,
+		SameSite: sessionSameSite(),
+	})
+}
+
+// 请求cookie中的登录会话，账号被禁用后会话一并失效
+func cookieSession(c *gin.Context) (*model.SsoSession, bool) {
+	value, err := c.Cookie(sessionCookieName())
+	if err != nil || value == "" {
+		return nil, false
+	}
+	session, ok := dao.GetSsoSession(value)
+	if !ok || middlewares.IsUserDisabled(session.UserID) {
+		return nil, false
+	}
+	return session, true
+}
+
+// 是否是SSO自身登录签发的token：不是签发给客户端的，并且对应的登录会话还有效
+// OAuth授权签发给客户端的token只代表用户对该客户端的授权，不能用来代替用户在SSO上操作
+func ssoLoginClaims(claims *middlewares.CustomClaims) bool {
+	return claims.ClientID == "" && claims.SessionID != "" && dao.SsoSessionExists(claims.SessionID)
+}
+
+// 授权接口的登录用户：优先使用Authorization头中的token，没有时使用登录会话cookie
+// 只接受SSO自身登录签发的token，签发给客户端的token或登录会话已结束（退出登录）时视为未登录
+func authorizeClaims(c *gin.Context) (*middlewares.CustomClaims, bool) {
+	if token := middlewares.ExtractTokenFromHeader(c.GetHeader("Authorization")); token != "" {
+		j := middlewares.NewJWT()
+		claims, err := j.ParseToken(token)
+		if err != nil || !ssoLoginClaims(claims) {
+			return nil, false
+		}
+		return claims, true
+	}
+	session, ok := cookieSession(c)
+	if !ok {
+		return nil, false
+	}
+	return &middlewares.CustomClaims{
+		ID:        session.UserID,
+		OrgID:     session.OrgID,
+		AuthTime:  session.AuthTime,
+		SessionID: session.ID,
+	}, true
+}
+
+// 浏览器直接访问时跳转到前端页面，并带上当前授权请求的地址return_to，没有跳转时返回false
+func redirectToFrontend(c *gin.Context, page string) bool {
+	if page == "" || c.Request.Method != http.MethodGet || !strings.Contains(c.GetHeader("Accept"), "text/html") {
+		return false
+	}
+	u, err := url.Parse(page)
+	if err != nil {
+		return false
+	}
+	query := u.Query()
+	query.Set("return_to", strings.TrimSuffix(global.Settings.Issuer, "/")+c.Request.URL.RequestURI())
+	u.RawQuery = query.Encode()
+	c.Redirect(http.StatusFound, u.String())
+	return true
+}
+
+// 需要登录：浏览器直接访问时跳转到前端登录页面，登录后返回当前授权请求；ajax请求返回401
+func loginRequired(c *gin.Context) {
+	if redirectToFrontend(c, global.Settings.LoginUrl) {
+		return
+	}
+	response.Err(c, http.StatusOK, 401, "login_required", "请登录")
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sso-go/config"
+	"sso-go/dao"
+	"sso-go/middlewares"
+	"sso-go/model"
+	"sso-go/utils"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthorizeClaims(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupRedis(t)
+	if err := middlewares.LoadKeyring(config.JWTConfig{SigningKey: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	session := &model.SsoSession{UserID: 1, OrgID: 3, AuthTime: 100}
+	cookie, err := dao.CreateSsoSession(session, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ended := &model.SsoSession{UserID: 1}
+	if _, err := dao.CreateSsoSession(ended, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	dao.DeleteSsoSession(ended.ID)
+	disabled := &model.SsoSession{UserID: 2}
+	disabledCookie, err := dao.CreateSsoSession(disabled, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	middlewares.SetUserDisabled(2, time.Hour)
+
+	token := func(claims middlewares.CustomClaims) string {
+		signed, _, err := utils.SignToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + signed
+	}
+
+	tests := []struct {
+		name   string
+		auth   string
+		cookie string
+		want   bool
+	}{
+		{"SSO登录token", token(middlewares.CustomClaims{ID: 1, SessionID: session.ID}), "", true},
+		{"签发给客户端的token", token(middlewares.CustomClaims{ID: 1, SessionID: session.ID, ClientID: "client-1"}), "", false},
+		{"没有会话的token", token(middlewares.CustomClaims{ID: 1}), "", false},
+		{"会话已结束", token(middlewares.CustomClaims{ID: 1, SessionID: ended.ID}), "", false},
+		{"token无效", "Bearer invalid", cookie, false},
+		{"登录会话cookie", "", cookie, true},
+		{"cookie密钥错误", "", session.ID + ".wrong", false},
+		{"账号已禁用", "", disabledCookie, false},
+		{"未登录", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/oauth/authorize", nil)
+			if tt.auth != "" {
+				c.Request.Header.Set("Authorization", tt.auth)
+			}
+			if tt.cookie != "" {
+				c.Request.AddCookie(&http.Cookie{Name: sessionCookieName(), Value: tt.cookie})
+			}
+			claims, ok := authorizeClaims(c)
+			if ok != tt.want {
+				t.Fatalf("authorizeClaims() = %+v, %v, want %v", claims, ok, tt.want)
+			}
+			if ok && (claims.ID != 1 || claims.SessionID != session.ID) {
+				t.Fatalf("authorizeClaims() = %+v", claims)
+			}
+		})
+	}
+}
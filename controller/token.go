@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"sso-go/dao"
+	"sso-go/middlewares"
+	"sso-go/model"
+	"sso-go/utils"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// 一次签发的access_token和refresh_token
+type tokenPair struct {
+	AccessToken  string
+	TokenID      string // access_token的jti
+	ExpiresAt    int64
+	RefreshToken string
+	FamilyID     string
+}
+
+// 作废用户的所有登录，已签发的access_token、refresh_token和登录会话全部失效
+func revokeUserSessions(userID uint) {
+	middlewares.RevokeUserTokens(userID, time.Duration(utils.AccessTokenExpireSeconds())*time.Second)
+	dao.RevokeUserRefreshTokens(userID)
+	dao.DeleteUserSsoSessions(userID)
+}
+
+// 作废用户签发给某个客户端的所有token
+func revokeUserClientSessions(userID uint, clientID string) {
+	middlewares.RevokeUserClientTokens(userID, clientID, time.Duration(utils.AccessTokenExpireSeconds())*time.Second)
+	dao.RevokeUserClientRefreshTokens(userID, clientID)
+}
+
+// token中写入的角色和权限：SSO自身签发的token总是包含，OAuth授权签发的token只有申请了roles才包含，且不包含SSO管理接口的权限
+func tokenAuthorization(userID uint, clientID string, scope string) ([]string, []string) {
+	if clientID != "" && !utils.HasScope(scope, "roles") {
+		return nil, nil
+	}
+	roles, permissions := dao.GetUserAuthorization(userID)
+	if clientID == "" {
+		return roles, permissions
+	}
+	var clientPermissions []string
+	for _, p := range permissions {
+		if !strings.HasPrefix(p, "sso:") {
+			clientPermissions = append(clientPermissions, p)
+		}
+	}
+	return roles, clientPermissions
+}
+
+// token中写入的组织角色，用户已不在该组织中时不能签发
+func tokenOrgRole(orgID uint, userID uint) (string, error) {
+	if orgID == 0 {
+		return "", nil
+	}
+	member, ok := dao.GetOrgMember(orgID, userID)
+	if !ok {
+		return "", dao.ErrNotOrgMember
+	}
+	return member.Role, nil
+}
+
+// 角色或权限变更后作废用户已签发的access_token，客户端用refresh_token换取包含新权限的token
+func refreshUserAuthorization(userIDs ...uint) {
+	ttl := time.Duration(utils.AccessTokenExpireSeconds()) * time.Second
+	for _, userID := range userIDs {
+		middlewares.RevokeUserTokens(userID, ttl)
+	}
+}
+
+// 资料修改后按当前token的客户端、scope重新签发access_token，refresh_token不变
+func reissueAccessToken(claims *middlewares.CustomClaims, user *model.User) (string, int64, error) {
+	orgRole, err := tokenOrgRole(claims.OrgID, user.ID)
+	if err != nil {
+		return "", 0, err
+	}
+	roles, permissions := tokenAuthorization(user.ID, claims.ClientID, claims.Scope)
+	return utils.SignToken(middlewares.CustomClaims{
+		ID:          user.ID,
+		NickName:    user.Name,
+		Email:       user.Email,
+		HeadUrl:     utils.UserHeadUrl(user),
+		ClientID:    claims.ClientID,
+		Scope:       claims.Scope,
+		AuthTime:    claims.AuthTime,
+		OrgID:       claims.OrgID,
+		OrgRole:     orgRole,
+		SessionID:   claims.SessionID,
+		Roles:       roles,
+		Permissions: permissions,
+		StandardClaims: jwt.StandardClaims{
+			Id: utils.GenerateHexCode(16),
+		},
+	})
+}
+
+// 为用户签发access_token和refresh_token，familyID为空时开启新的refresh_token family
+// sessionID为SSO自身登录对应的登录会话，OAuth授权签发时为空
+func issueTokenPair(user *model.User, clientID string, scope string, orgID uint, authTime int64, familyID string, sessionID string) (*tokenPair, error) {
+	return issueTokenPairWithID(utils.GenerateHexCode(16), user, clientID, scope, orgID, authTime, familyID, sessionID)
+}
+
+// 用指定的jti签发access_token和refresh_token，授权码兑换时jti需要在签发前确定
+func issueTokenPairWithID(tokenID string, user *model.User, clientID string, scope string, orgID uint, authTime int64, familyID string, sessionID string) (*tokenPair, error) {
+	orgRole, err := tokenOrgRole(orgID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	roles, permissions := tokenAuthorization(user.ID, clientID, scope)
+	accessToken, expiresAt, err := utils.SignToken(middlewares.CustomClaims{
+		ID:          user.ID,
+		NickName:    user.Name,
+		Email:       user.Email,
+		HeadUrl:     utils.UserHeadUrl(user),
+		ClientID:    clientID,
+		Scope:       scope,
+		AuthTime:    authTime,
+		OrgID:       orgID,
+		OrgRole:     orgRole,
+		SessionID:   sessionID,
+		Roles:       roles,
+		Permissions: permissions,
+		StandardClaims: jwt.StandardClaims{
+			Id: tokenID,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	record := model.RefreshToken{
+		UserID:    user.ID,
+		ClientID:  clientID,
+		Scope:     scope,
+		FamilyID:  familyID,
+		AuthTime:  authTime,
+		OrgID:     orgID,
+		SessionID: sessionID,
+	}
+	refreshToken, err := dao.IssueRefreshToken(&record)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenPair{
+		AccessToken:  accessToken,
+		TokenID:      tokenID,
+		ExpiresAt:    expiresAt,
+		RefreshToken: refreshToken,
+		FamilyID:     record.FamilyID,
+	}, nil
+}
+
+// 为客户端签发服务token（client_credentials），sub为client_id，不包含用户信息，也不签发refresh_token
+func issueClientToken(client *model.Client, scope string) (string, int64, error) {
+	return utils.SignToken(middlewares.CustomClaims{
+		ClientID: client.ClientID,
+		Scope:    scope,
+		OrgID:    client.OrgID,
+		TokenUse: middlewares.TokenUseClient,
+		StandardClaims: jwt.StandardClaims{
+			Subject: client.ClientID,
+			Id:      utils.GenerateHexCode(16),
+		},
+	})
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"fmt"
+	"math"
 	"net/http"
 	"sso-go/dao"
 	"sso-go/forms"
@@ -27,15 +28,14 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// 验证邮箱验证码
-	emailCodeKey := fmt.Sprintf("EmailCode:%s", registerParams.Email)
-	if registerParams.Code != global.Redis.Get(emailCodeKey).Val() {
-		response.Err(c, http.StatusOK, 400, "邮箱验证码错误", nil)
+	// 验证邮箱验证码，输错次数过多验证码作废
+	if err := dao.VerifyEmailCode(dao.EmailCodeRegister, registerParams.Email, registerParams.Code); err != nil {
+		response.Err(c, http.StatusOK, 400, err.Error(), nil)
 		return
 	}
 
 	// 验证邮箱或昵称是否注册
-	hasEmail := dao.HasUser(registerParams.Email)
+	hasEmail := dao.HasEmail(registerParams.Email)
 	hasName := dao.HasUser(registerParams.Username)
 	if hasEmail {
 		response.Err(c, http.StatusOK, 400, "该邮箱已注册", nil)
@@ -61,6 +61,8 @@ func Register(c *gin.Context) {
 		response.Err(c, 200, 500, "创建失败", result.Error.Error())
 		return
 	}
+	// 注册成功后验证码立即作废
+	dao.ConsumeEmailCode(dao.EmailCodeRegister, registerParams.Email)
 
 	data := map[string]interface{}{
 		"user_id": user.ID,
@@ -81,47 +83,140 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// 连续登录失败的账号或IP需要等待一段时间才能再次尝试
+	accountKey := dao.LoginAccountKey(loginParams.Username)
+	if wait := dao.LoginBlocked(accountKey, c.ClientIP()); wait > 0 {
+		seconds := int64(math.Ceil(wait.Seconds()))
+		response.Err(c, http.StatusOK, 429, fmt.Sprintf("登录失败次数过多，请%d秒后再试", seconds), map[string]interface{}{
+			"retry_after": seconds,
+		})
+		return
+	}
+
+	// 指定了组织时只允许该组织的成员登录，token中带上组织
+	var orgID uint
+	if loginParams.Org != "" {
+		org, ok := dao.GetOrgBySlug(loginParams.Org)
+		if !ok {
+			response.Err(c, http.StatusOK, 400, "组织不存在", "")
+			return
+		}
+		orgID = org.ID
+	}
+
 	// 查询是否有该用户
-	user, ok, msg := dao.GetUserInfoByPw(loginParams.Username, loginParams.PassWord)
+	user, ok, msg := dao.GetUserInfoByPw(loginParams.Username, loginParams.PassWord, orgID)
 	if !ok {
+		dao.AddLoginFailure(accountKey, c.ClientIP())
 		response.Err(c, http.StatusOK, 401, msg, "")
 		return
 	}
+	dao.ClearLoginFailures(accountKey)
 
-	// 处理下默认头像
-	if user.HeadUrl == "" {
-		user.HeadUrl = "http://resource.djp.org.cn/images/head_default.png"
+	// 开启了两步验证的账号只返回mfa_token，调用 /login/mfa 或 /login/webauthn 完成登录
+	if challenge, ok := mfaChallengeFor(user); ok {
+		challenge.OrgID = orgID
+		mfaToken, err := dao.SaveMfaChallenge(challenge, mfaChallengeExpire)
+		if err != nil {
+			response.Err(c, http.StatusOK, 500, "登录失败,重新再试", err.Error())
+			return
+		}
+		response.Success(c, 200, "success", map[string]interface{}{
+			"mfa_required": true,
+			"mfa_enroll":   challenge.Enroll,
+			"mfa_methods":  challenge.Methods,
+			"mfa_token":    mfaToken,
+			"expires_in":   int64(mfaChallengeExpire / time.Second),
+		})
+		return
 	}
 
-	// 登录成功创建token
-	token := utils.CreateToken(c, user.ID, user.Name, user.Email, user.HeadUrl)
+	userInfoMap, err := loginTokens(c, user, orgID)
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "token生成失败,重新再试", err.Error())
+		return
+	}
+	response.Success(c, 200, "success", userInfoMap)
+}
+
+// 登录成功创建登录会话和token，access_token过期后用refresh_token换取新的token，orgID为登录时选择的组织
+// 会话写入cookie，之后其他业务系统跳转到授权接口时不需要重新输入密码
+func loginTokens(c *gin.Context, user *model.User, orgID uint) (map[string]interface{}, error) {
+	session := model.SsoSession{
+		UserID:   user.ID,
+		OrgID:    orgID,
+		AuthTime: time.Now().Unix(),
+	}
+	cookieValue, err := dao.CreateSsoSession(&session, time.Duration(utils.SessionExpireSeconds())*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	userInfoMap, err := sessionTokens(user, orgID, session.AuthTime, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	setSessionCookie(c, cookieValue)
+	return userInfoMap, nil
+}
+
+// 为登录会话签发token，sessionID为空表示没有对应的会话
+func sessionTokens(user *model.User, orgID uint, authTime int64, sessionID string) (map[string]interface{}, error) {
+	tokens, err := issueTokenPair(user, "", "", orgID, authTime, "", sessionID)
+	if err != nil {
+		return nil, err
+	}
 	userInfoMap := HandleUserModelToMap(user)
-	userInfoMap["token"] = token
+	userInfoMap["token"] = tokens.AccessToken
+	userInfoMap["refresh_token"] = tokens.RefreshToken
+	userInfoMap["expires_in"] = tokens.ExpiresAt - time.Now().Unix()
+	return userInfoMap, nil
+}
 
-	response.Success(c, 200, "success", userInfoMap)
+// 退出登录，吊销当前的access_token并结束登录会话，同时传了refresh_token则一并作废
+func Logout(c *gin.Context) {
+	claims, ok := getClaims(c)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "未登录", "")
+		return
+	}
+	middlewares.RevokeToken(claims)
+	// 会话结束后其他业务系统不能再通过它申请授权码
+	dao.DeleteSsoSession(claims.SessionID)
+	if session, ok := cookieSession(c); ok && session.UserID == claims.ID {
+		dao.DeleteSsoSession(session.ID)
+	}
+	clearSessionCookie(c)
+	refreshToken := c.PostForm("refresh_token")
+	if refreshToken == "" {
+		refreshToken = c.Query("refresh_token")
+	}
+	if record, ok := dao.GetRefreshToken(refreshToken); ok && record.UserID == claims.ID {
+		dao.RevokeRefreshFamily(record.FamilyID)
+	}
+	global.Lg.Info("Logout", zap.Any("user_id", claims.ID))
+	response.Success(c, 200, "success", nil)
 }
 
-// 用户信息
+// 用户信息，对外标准接口请使用OIDC的 /userinfo
 func UserInfo(c *gin.Context) {
-	c.Get("claims")
-	claims, exists := c.Get("claims")
-	if !exists {
-		// 如果不存在，说明中间件没有设置claims
+	// JWTAuth中间件写入的是*CustomClaims，取不到说明中间件没有设置claims
+	jwtClaims, ok := getClaims(c)
+	if !ok {
 		response.Err(c, http.StatusOK, 401, "未登录", "")
 		return
 	}
 
-	// 进行类型断言，确保 claims 的类型是 jwt.Claims
-	jwtClaims, ok := claims.(middlewares.CustomClaims)
+	// 从数据库读取，修改资料后立即生效
+	user, ok := dao.GetUserByID(jwtClaims.ID)
 	if !ok {
-		response.Err(c, 401, 401, "未登录", "")
+		response.Err(c, http.StatusOK, 401, "用户不存在", "")
 		return
 	}
-
 	userInfo := map[string]interface{}{
-		"userId":   jwtClaims.ID,
-		"username": jwtClaims.NickName,
-		"email":    jwtClaims.Email,
+		"userId":   user.ID,
+		"username": user.Name,
+		"email":    user.Email,
+		"head_url": utils.UserHeadUrl(user),
 	}
 	response.Success(c, 200, "success", map[string]interface{}{
 		"userInfo": userInfo,
@@ -139,15 +234,23 @@ func SendValidateCode(c *gin.Context) {
 	}
 	email := emailParams.Email
 	emails := []string{email}
+	// 限制发送间隔和每日发送次数，防止被用来轰炸邮箱
+	if err := dao.AcquireEmailCodeQuota(email, c.ClientIP()); err != nil {
+		response.Err(c, http.StatusOK, 429, err.Error(), nil)
+		return
+	}
 	// 发送邮件
 	vCode, err := utils.SendEmailValidate(emails)
 	if err != nil {
+		dao.ReleaseEmailCodeCooldown(email)
 		response.Err(c, http.StatusOK, 500, "验证码发送失败", err.Error())
 		return
 	}
 	// 验证码存入redis，有效期5分钟
-	emailCodeKey := fmt.Sprintf("EmailCode:%s", email)
-	global.Redis.Set(emailCodeKey, vCode, time.Minute*5)
+	if err := dao.SaveEmailCode(dao.EmailCodeRegister, email, vCode); err != nil {
+		response.Err(c, http.StatusOK, 500, "验证码发送失败", err.Error())
+		return
+	}
 
 	response.Success(c, 200, "success", nil)
 	return
@@ -157,7 +260,7 @@ func HandleUserModelToMap(user *model.User) map[string]interface{} {
 	userItemMap := map[string]interface{}{
 		"id":       user.ID,
 		"username": user.Name,
-		"head_url": user.HeadUrl,
+		"head_url": utils.UserHeadUrl(user),
 		"email":    user.Email,
 	}
 	return userItemMap
@@ -165,44 +268,125 @@ func HandleUserModelToMap(user *model.User) map[string]interface{} {
 
 // 获取临时授权码
 func CreateCode(c *gin.Context) {
-	authorization := c.GetHeader("Authorization")
-	token := middlewares.ExtractTokenFromHeader(authorization)
-	global.Lg.Info("CreateCode", zap.Any("token:", token))
+	createCodeParams := forms.CreateCodeForm{}
+	if err := c.ShouldBind(&createCodeParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	if createCodeParams.CodeChallenge != "" && !utils.IsPKCEValue(createCodeParams.CodeChallenge) {
+		response.Err(c, http.StatusOK, 400, "code_challenge格式错误", nil)
+		return
+	}
+	// 只能用SSO自身登录的token申请code，签发给其他客户端的token不能用来给任意客户端申请code
+	claims, ok := getClaims(c)
+	if !ok || !ssoLoginClaims(claims) {
+		response.Err(c, http.StatusOK, 401, "未登录", "")
+		return
+	}
+	// 回调地址必须在白名单内，防止code被带到任意站点
+	client, ok := dao.GetClientByClientID(createCodeParams.ClientID)
+	if !ok {
+		response.Err(c, http.StatusOK, 400, "客户端不存在", "")
+		return
+	}
+	if !client.AllowScopes(createCodeParams.Scope) {
+		response.Err(c, http.StatusOK, 400, "申请的授权范围不被允许", "")
+		return
+	}
+	if createCodeParams.CodeChallenge == "" && client.IsPublic() {
+		response.Err(c, http.StatusOK, 400, "公开客户端必须使用PKCE", "")
+		return
+	}
+	if !isAllowedRedirect(client, createCodeParams.RedirectUri) {
+		response.Err(c, http.StatusOK, 400, "回调地址不在白名单内", "")
+		return
+	}
+	orgID, ok := authCodeOrg(client, claims)
+	if !ok {
+		response.Err(c, http.StatusOK, 403, dao.ErrNotOrgMember.Error(), "")
+		return
+	}
+	// 第三方客户端需要先通过/oauth/authorize让用户确认授权
+	if missing, ok := pendingConsent(client, claims.ID, createCodeParams.Scope); ok {
+		consentRequired(c, client, claims, createCodeParams.Scope, missing)
+		return
+	}
 	code := utils.GenerateCode()
 
-	// code存入redis，有效期1分钟
-	global.Redis.Set(code, token, time.Minute)
-	response.Success(c, 200, "success", code)
+	// code对应的授权信息存入redis，有效期1分钟，换取token时重新签发
+	authCode := model.AuthCode{
+		UserID:      claims.ID,
+		ClientID:    createCodeParams.ClientID,
+		OrgID:       orgID,
+		RedirectUri: createCodeParams.RedirectUri,
+		Scope:       createCodeParams.Scope,
+		IssuedAt:    time.Now().Unix(),
+		AuthTime:    claims.AuthTime,
+		CodeChallenge: model.CodeChallenge{
+			Challenge: createCodeParams.CodeChallenge,
+			Method:    createCodeParams.CodeChallengeMethod,
+		},
+	}
+	if err := dao.SaveAuthCode(code, &authCode, authCodeExpire); err != nil {
+		response.Err(c, http.StatusOK, 500, "授权码生成失败", err.Error())
+		return
+	}
+	global.Lg.Info("CreateCode", zap.Any("user_id", claims.ID), zap.Any("client_id", createCodeParams.ClientID))
+	redirectWithCode(c, createCodeParams.RedirectUri, code, createCodeParams.State)
 }
 
 // 根据code来换取token
 func GetTokenByCode(c *gin.Context) {
-	code := c.Query("code")
-	if code == "" {
+	getTokenParams := forms.GetTokenByCodeForm{}
+	if err := c.ShouldBind(&getTokenParams); err != nil {
 		response.Err(c, http.StatusOK, 401, "code不得为空", "")
 		return
 	}
-	token := global.Redis.Get(code).Val()
-
-	global.Lg.Info("GetTokenByCode", zap.Any("token_from_redis:", token))
-
-	// 校验token
-	j := middlewares.NewJWT()
-	claims, err := j.ParseToken(token)
+	// 先认证客户端再兑换，凭证错误的请求不会消耗code
+	client, ok := authenticateClient(c)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "客户端认证失败", "")
+		return
+	}
+	// code只能使用一次，重复使用会吊销之前换取的token
+	authCode, issued, err := redeemAuthCode(getTokenParams.Code)
 	if err != nil {
 		response.Err(c, http.StatusOK, 401, "fail", err.Error())
 		return
 	}
+	// code只能由申请它的客户端以相同的回调地址换取，没有绑定客户端的code一律拒绝
+	if authCode.ClientID == "" || client.ClientID != authCode.ClientID || getTokenParams.RedirectUri != authCode.RedirectUri {
+		response.Err(c, http.StatusOK, 401, "code与客户端不匹配", "")
+		return
+	}
+	// 申请code时带了code_challenge，则必须提供匹配的code_verifier
+	if authCode.Challenge != "" && !utils.VerifyPKCE(getTokenParams.CodeVerifier, authCode.Challenge, authCode.Method) {
+		response.Err(c, http.StatusOK, 401, "code_verifier校验失败", "")
+		return
+	}
 
-	// 验证后清除redis
-	// global.Redis.Del(code)
+	user, ok := dao.GetActiveUserByID(authCode.UserID)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "fail", "用户不存在")
+		return
+	}
+	tokens, err := issueAuthCodeTokens(getTokenParams.Code, issued, user, authCode)
+	if err == dao.ErrAuthCodeReused {
+		response.Err(c, http.StatusOK, 401, "fail", err.Error())
+		return
+	}
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "token生成失败,重新再试", err.Error())
+		return
+	}
 
 	userInfo := map[string]interface{}{
-		"userId":        claims.ID,
-		"username":      claims.NickName,
-		"head_url":      claims.HeadUrl,
-		"token":         token,
-		"expirein_time": claims.StandardClaims.ExpiresAt,
+		"userId":        user.ID,
+		"username":      user.Name,
+		"head_url":      utils.UserHeadUrl(user),
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expirein_time": tokens.ExpiresAt,
 	}
 
 	response.Success(c, 200, "success", userInfo)
@@ -0,0 +1,350 @@
+package controller
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sso-go/dao"
+	"sso-go/forms"
+	"sso-go/global"
+	"sso-go/model"
+	"sso-go/response"
+	"sso-go/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"go.uber.org/zap"
+)
+
+// WebAuthn挑战有效期
+const webauthnSessionExpire = 5 * time.Minute
+
+// 没有配置[webauthn]时所有WebAuthn接口不可用
+func webauthnEnabled(c *gin.Context) bool {
+	if global.WebAuthn == nil {
+		response.Err(c, http.StatusOK, 400, "未开启WebAuthn", "")
+		return false
+	}
+	return true
+}
+
+func loadWebauthnUser(userID uint) (*model.WebauthnUser, bool) {
+	user, ok := dao.GetActiveUserByID(userID)
+	if !ok {
+		return nil, false
+	}
+	return &model.WebauthnUser{User: user, Credentials: dao.GetWebauthnCredentials(userID)}, true
+}
+
+// 认证通过后更新签名计数器，计数器回退说明认证器可能被克隆，拒绝本次认证
+func recordWebauthnAssertion(user *model.WebauthnUser, credential *webauthn.Credential) bool {
+	if credential.Authenticator.CloneWarning {
+		global.Lg.Warn("WebauthnCloneWarning", zap.Any("user_id", user.User.ID))
+		return false
+	}
+	credentialID := base64.RawURLEncoding.EncodeToString(credential.ID)
+	for i := range user.Credentials {
+		if user.Credentials[i].CredentialID == credentialID {
+			err := dao.UpdateWebauthnCredentialUsage(&user.Credentials[i], credential.Authenticator.SignCount, credential.Flags.BackupState)
+			return err == nil
+		}
+	}
+	return false
+}
+
+// 创建注册挑战，要求认证器验证用户，已注册的认证器不能重复注册
+func beginWebauthnRegistration(user *model.WebauthnUser) (*protocol.CredentialCreation, *webauthn.SessionData, error) {
+	exclusions := []protocol.CredentialDescriptor{}
+	for _, credential := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+	return global.WebAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{UserVerification: protocol.VerificationRequired}),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+}
+
+// 开始注册WebAuthn凭证，返回navigator.credentials.create()的参数
+func WebauthnRegisterBegin(c *gin.Context) {
+	if !webauthnEnabled(c) {
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "未登录", "")
+		return
+	}
+	user, ok := loadWebauthnUser(claims.ID)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "用户不存在", "")
+		return
+	}
+	creation, session, err := beginWebauthnRegistration(user)
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "创建挑战失败", err.Error())
+		return
+	}
+	sessionToken, err := dao.SaveWebauthnSession(&model.WebauthnSession{
+		UserID:  claims.ID,
+		Purpose: "register",
+		Data:    *session,
+	}, webauthnSessionExpire)
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "创建挑战失败", err.Error())
+		return
+	}
+	response.Success(c, 200, "success", map[string]interface{}{
+		"session_token": sessionToken,
+		"options":       creation,
+	})
+}
+
+// 完成注册，请求体为navigator.credentials.create()的结果，session_token和name通过query传递
+func WebauthnRegisterFinish(c *gin.Context) {
+	if !webauthnEnabled(c) {
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "未登录", "")
+		return
+	}
+	session, ok := dao.TakeWebauthnSession(c.Query("session_token"))
+	if !ok || session.Purpose != "register" || session.UserID != claims.ID {
+		response.Err(c, http.StatusOK, 400, "session_token无效或已过期", "")
+		return
+	}
+	user, ok := loadWebauthnUser(claims.ID)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "用户不存在", "")
+		return
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(c.Request.Body)
+	if err != nil {
+		response.Err(c, http.StatusOK, 400, "凭证格式错误", err.Error())
+		return
+	}
+	credential, err := global.WebAuthn.CreateCredential(user, session.Data, parsed)
+	if err != nil {
+		response.Err(c, http.StatusOK, 400, "凭证校验失败", err.Error())
+		return
+	}
+
+	name := c.Query("name")
+	if name == "" {
+		name = "通行密钥"
+	}
+	record := newWebauthnCredential(claims.ID, name, credential)
+	if err := dao.CreateWebauthnCredential(&record); err != nil {
+		response.Err(c, http.StatusOK, 500, "保存凭证失败", err.Error())
+		return
+	}
+	global.Lg.Info("WebauthnRegister", zap.Any("user_id", claims.ID), zap.Any("credential", record.ID))
+	response.Success(c, 200, "success", record)
+}
+
+// 注册成功的凭证转换成数据库记录
+func newWebauthnCredential(userID uint, name string, credential *webauthn.Credential) model.WebauthnCredential {
+	transports := []string{}
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+	return model.WebauthnCredential{
+		UserID:          userID,
+		Name:            name,
+		CredentialID:    base64.RawURLEncoding.EncodeToString(credential.ID),
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      strings.Join(transports, " "),
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+}
+
+// 已注册的WebAuthn凭证列表
+func WebauthnCredentials(c *gin.Context) {
+	claims, ok := getClaims(c)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "未登录", "")
+		return
+	}
+	response.Success(c, 200, "success", dao.GetWebauthnCredentials(claims.ID))
+}
+
+// 删除WebAuthn凭证，必须开启两步验证的账号不能删除最后一个第二因素
+func WebauthnDeleteCredential(c *gin.Context) {
+	deleteParams := forms.WebauthnCredentialForm{}
+	if err := c.ShouldBind(&deleteParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "未登录", "")
+		return
+	}
+	if user, ok := dao.GetUserByID(claims.ID); ok && mfaRequired(user) && !totpEnabled(claims.ID) && len(dao.GetWebauthnCredentials(claims.ID)) <= 1 {
+		response.Err(c, http.StatusOK, 403, "该账号必须开启两步验证", "")
+		return
+	}
+	if !dao.DeleteWebauthnCredential(claims.ID, deleteParams.ID) {
+		response.Err(c, http.StatusOK, 400, "凭证不存在", "")
+		return
+	}
+	global.Lg.Info("WebauthnDelete", zap.Any("user_id", claims.ID), zap.Any("credential", deleteParams.ID))
+	response.Success(c, 200, "success", nil)
+}
+
+// 开始无密码登录，返回navigator.credentials.get()的参数，由认证器选择账号
+func WebauthnLoginBegin(c *gin.Context) {
+	if !webauthnEnabled(c) {
+		return
+	}
+	assertion, session, err := global.WebAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "创建挑战失败", err.Error())
+		return
+	}
+	sessionToken, err := dao.SaveWebauthnSession(&model.WebauthnSession{
+		Purpose: "login",
+		Data:    *session,
+	}, webauthnSessionExpire)
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "创建挑战失败", err.Error())
+		return
+	}
+	response.Success(c, 200, "success", map[string]interface{}{
+		"session_token": sessionToken,
+		"options":       assertion,
+	})
+}
+
+// 完成无密码登录，请求体为navigator.credentials.get()的结果
+// 认证器已经验证过用户（指纹、PIN等），本身就是多因素认证，不再要求两步验证
+func WebauthnLoginFinish(c *gin.Context) {
+	if !webauthnEnabled(c) {
+		return
+	}
+	session, ok := dao.TakeWebauthnSession(c.Query("session_token"))
+	if !ok || session.Purpose != "login" {
+		response.Err(c, http.StatusOK, 401, "session_token无效或已过期", "")
+		return
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBody(c.Request.Body)
+	if err != nil {
+		response.Err(c, http.StatusOK, 401, "凭证格式错误", err.Error())
+		return
+	}
+	var user *model.WebauthnUser
+	credential, err := global.WebAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := strconv.ParseUint(string(userHandle), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		found, ok := loadWebauthnUser(uint(userID))
+		if !ok {
+			return nil, errors.New("用户不存在")
+		}
+		user = found
+		return user, nil
+	}, session.Data, parsed)
+	if err != nil || !recordWebauthnAssertion(user, credential) {
+		response.Err(c, http.StatusOK, 401, "认证失败", "")
+		return
+	}
+
+	userInfoMap, err := loginTokens(c, user.User, 0)
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "token生成失败,重新再试", err.Error())
+		return
+	}
+	global.Lg.Info("WebauthnLogin", zap.Any("user_id", user.User.ID))
+	response.Success(c, 200, "success", userInfoMap)
+}
+
+// 密码登录后用WebAuthn完成两步验证：开始认证
+func WebauthnMfaBegin(c *gin.Context) {
+	if !webauthnEnabled(c) {
+		return
+	}
+	mfaParams := forms.MfaLoginForm{}
+	if err := c.ShouldBind(&mfaParams); err != nil {
+		utils.HandleValidatorError(c, err)
+		return
+	}
+	challenge, ok := dao.GetMfaChallenge(mfaParams.MfaToken)
+	if !ok || challenge.Enroll {
+		response.Err(c, http.StatusOK, 401, "mfa_token无效或已过期", "")
+		return
+	}
+	user, ok := loadWebauthnUser(challenge.UserID)
+	if !ok || len(user.Credentials) == 0 {
+		response.Err(c, http.StatusOK, 400, "未注册WebAuthn凭证", "")
+		return
+	}
+	assertion, session, err := global.WebAuthn.BeginLogin(user)
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "创建挑战失败", err.Error())
+		return
+	}
+	sessionToken, err := dao.SaveWebauthnSession(&model.WebauthnSession{
+		UserID:   challenge.UserID,
+		Purpose:  "mfa",
+		MfaToken: mfaParams.MfaToken,
+		Data:     *session,
+	}, webauthnSessionExpire)
+	if err != nil {
+		response.Err(c, http.StatusOK, 500, "创建挑战失败", err.Error())
+		return
+	}
+	response.Success(c, 200, "success", map[string]interface{}{
+		"session_token": sessionToken,
+		"options":       assertion,
+	})
+}
+
+// 密码登录后用WebAuthn完成两步验证：校验认证结果并签发token
+func WebauthnMfaFinish(c *gin.Context) {
+	if !webauthnEnabled(c) {
+		return
+	}
+	session, ok := dao.TakeWebauthnSession(c.Query("session_token"))
+	if !ok || session.Purpose != "mfa" {
+		response.Err(c, http.StatusOK, 401, "session_token无效或已过期", "")
+		return
+	}
+	challenge, ok := dao.GetMfaChallenge(session.MfaToken)
+	if !ok || challenge.UserID != session.UserID {
+		response.Err(c, http.StatusOK, 401, "mfa_token无效或已过期", "")
+		return
+	}
+	if dao.MfaLocked(challenge.UserID) {
+		response.Err(c, http.StatusOK, 401, "验证失败次数过多，请稍后再试", "")
+		return
+	}
+	user, ok := loadWebauthnUser(challenge.UserID)
+	if !ok {
+		response.Err(c, http.StatusOK, 401, "用户不存在", "")
+		return
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBody(c.Request.Body)
+	if err != nil {
+		response.Err(c, http.StatusOK, 401, "凭证格式错误", err.Error())
+		return
+	}
+	credential, err := global.WebAuthn.ValidateLogin(user, session.Data, parsed)
+	if err != nil || !recordWebauthnAssertion(user, credential) {
+		dao.AddMfaFailure(challenge.UserID)
+		response.Err(c, http.StatusOK, 401, "认证失败", "")
+		return
+	}
+	dao.ClearMfaFailures(challenge.UserID)
+	completeMfaLogin(c, session.MfaToken, challenge, nil)
+}
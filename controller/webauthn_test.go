@@ -0,0 +1,294 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"sso-go/global"
+	"sso-go/model"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"go.uber.org/zap"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+const (
+	testRPID   = "sso.example.com"
+	testOrigin = "https://sso.example.com"
+)
+
+// 软件实现的认证器，只支持none证明和ES256
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	signCount    uint32
+	rpID         string // 认证器计算rpIdHash时使用的RP ID
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{key: key, credentialID: credentialID, rpID: testRPID}
+}
+
+func (a *softAuthenticator) authData(flags protocol.AuthenticatorFlags, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, byte(flags))
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attested...)
+}
+
+func clientDataJSON(t *testing.T, ceremony protocol.CeremonyType, challenge string) []byte {
+	data, err := json.Marshal(map[string]string{
+		"type":      string(ceremony),
+		"challenge": challenge,
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// 模拟navigator.credentials.create()
+func (a *softAuthenticator) create(t *testing.T, creation *protocol.CredentialCreation) *protocol.ParsedCredentialCreationData {
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1, // P-256
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, publicKey...)
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authData(protocol.FlagUserPresent|protocol.FlagUserVerified|protocol.FlagAttestedCredentialData, attested),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := base64.RawURLEncoding.EncodeToString(a.credentialID)
+	body, err := json.Marshal(map[string]interface{}{
+		"id":    id,
+		"rawId": id,
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientDataJSON(t, protocol.CreateCeremony, creation.Response.Challenge.String())),
+			"attestationObject": base64.RawURLEncoding.EncodeToString(attestation),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+// 模拟navigator.credentials.get()，每次签名计数器加一
+func (a *softAuthenticator) get(t *testing.T, assertion *protocol.CredentialAssertion, userHandle []byte) *protocol.ParsedCredentialAssertionData {
+	a.signCount++
+	authData := a.authData(protocol.FlagUserPresent|protocol.FlagUserVerified, nil)
+	clientData := clientDataJSON(t, protocol.AssertCeremony, assertion.Response.Challenge.String())
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := base64.RawURLEncoding.EncodeToString(a.credentialID)
+	body, err := json.Marshal(map[string]interface{}{
+		"id":    id,
+		"rawId": id,
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+			"signature":         base64.RawURLEncoding.EncodeToString(signature),
+			"userHandle":        base64.RawURLEncoding.EncodeToString(userHandle),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func setupWebAuthn(t *testing.T) {
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          testRPID,
+		RPDisplayName: "SSO",
+		RPOrigins:     []string{testOrigin},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: 5 * time.Minute, TimeoutUVD: 5 * time.Minute},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: 5 * time.Minute, TimeoutUVD: 5 * time.Minute},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 更新签名计数器时不连接数据库
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:3306)/sso", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldWebAuthn, oldDB, oldLg := global.WebAuthn, global.DB, global.Lg
+	global.WebAuthn, global.DB, global.Lg = w, db, zap.NewNop()
+	t.Cleanup(func() { global.WebAuthn, global.DB, global.Lg = oldWebAuthn, oldDB, oldLg })
+}
+
+// 用软件认证器注册，返回保存到数据库的凭证
+func registerSoftAuthenticator(t *testing.T, user *model.WebauthnUser, authenticator *softAuthenticator) (model.WebauthnCredential, error) {
+	creation, session, err := beginWebauthnRegistration(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := global.WebAuthn.CreateCredential(user, *session, authenticator.create(t, creation))
+	if err != nil {
+		return model.WebauthnCredential{}, err
+	}
+	return newWebauthnCredential(user.User.ID, "测试", credential), nil
+}
+
+// 用软件认证器完成两步验证
+func assertSoftAuthenticator(t *testing.T, user *model.WebauthnUser, authenticator *softAuthenticator) (*webauthn.Credential, error) {
+	assertion, session, err := global.WebAuthn.BeginLogin(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return global.WebAuthn.ValidateLogin(user, *session, authenticator.get(t, assertion, user.WebAuthnID()))
+}
+
+func TestWebauthnRegistrationAndAssertion(t *testing.T) {
+	setupWebAuthn(t)
+	user := &model.WebauthnUser{User: &model.User{ID: 1, Name: "test", Email: "test@example.com"}}
+	authenticator := newSoftAuthenticator(t)
+
+	record, err := registerSoftAuthenticator(t, user, authenticator)
+	if err != nil {
+		t.Fatalf("注册失败：%v", err)
+	}
+	if record.UserID != 1 || record.CredentialID != base64.RawURLEncoding.EncodeToString(authenticator.credentialID) || record.AttestationType != "none" {
+		t.Fatalf("凭证记录 = %+v", record)
+	}
+	record.ID = 1 // 模拟保存到数据库后的主键
+	user.Credentials = []model.WebauthnCredential{record}
+
+	// 已注册的认证器出现在排除列表中
+	creation, _, err := beginWebauthnRegistration(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creation.Response.CredentialExcludeList) != 1 || !bytes.Equal(creation.Response.CredentialExcludeList[0].CredentialID, authenticator.credentialID) {
+		t.Fatalf("排除列表 = %+v", creation.Response.CredentialExcludeList)
+	}
+
+	for i := uint32(1); i <= 2; i++ {
+		credential, err := assertSoftAuthenticator(t, user, authenticator)
+		if err != nil {
+			t.Fatalf("第%d次认证失败：%v", i, err)
+		}
+		if !recordWebauthnAssertion(user, credential) {
+			t.Fatalf("第%d次认证记录失败", i)
+		}
+		if credential.Authenticator.SignCount != i {
+			t.Fatalf("签名计数器 = %d, want %d", credential.Authenticator.SignCount, i)
+		}
+		user.Credentials[0].SignCount = credential.Authenticator.SignCount
+	}
+}
+
+func TestWebauthnSignCountRegression(t *testing.T) {
+	setupWebAuthn(t)
+	user := &model.WebauthnUser{User: &model.User{ID: 1, Name: "test", Email: "test@example.com"}}
+	authenticator := newSoftAuthenticator(t)
+	record, err := registerSoftAuthenticator(t, user, authenticator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 数据库中的计数器比认证器大，说明认证器可能被克隆
+	record.SignCount = 10
+	authenticator.signCount = 4
+	user.Credentials = []model.WebauthnCredential{record}
+
+	credential, err := assertSoftAuthenticator(t, user, authenticator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !credential.Authenticator.CloneWarning {
+		t.Fatal("计数器回退时应有克隆警告")
+	}
+	if recordWebauthnAssertion(user, credential) {
+		t.Fatal("计数器回退时应拒绝认证")
+	}
+
+	// 计数器不变同样拒绝
+	authenticator.signCount = 9
+	credential, err = assertSoftAuthenticator(t, user, authenticator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recordWebauthnAssertion(user, credential) {
+		t.Fatal("计数器不变时应拒绝认证")
+	}
+}
+
+func TestWebauthnWrongRPID(t *testing.T) {
+	setupWebAuthn(t)
+	user := &model.WebauthnUser{User: &model.User{ID: 1, Name: "test", Email: "test@example.com"}}
+
+	// 为其他网站生成的凭证不能注册
+	phishing := newSoftAuthenticator(t)
+	phishing.rpID = "sso.example.com.evil.test"
+	if _, err := registerSoftAuthenticator(t, user, phishing); err == nil {
+		t.Fatal("RP ID不匹配时注册应失败")
+	}
+
+	// 注册后认证器用其他RP ID签名，认证失败
+	authenticator := newSoftAuthenticator(t)
+	record, err := registerSoftAuthenticator(t, user, authenticator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Credentials = []model.WebauthnCredential{record}
+	authenticator.rpID = "example.com"
+	if _, err := assertSoftAuthenticator(t, user, authenticator); err == nil {
+		t.Fatal("RP ID不匹配时认证应失败")
+	}
+}
@@ -0,0 +1,68 @@
+package dao
+
+import (
+	"fmt"
+	"sso-go/global"
+	"sso-go/model"
+	"sso-go/utils"
+	"time"
+)
+
+// 根据client_id获取客户端
+func GetClientByClientID(clientID string) (*model.Client, bool) {
+	var client model.Client
+	rows := global.DB.Limit(1).Where("client_id = ?", clientID).Find(&client)
+	if rows.RowsAffected < 1 {
+		return nil, false
+	}
+	return &client, true
+}
+
+// 校验客户端身份
+func VerifyClient(clientID string, clientSecret string) (*model.Client, bool) {
+	client, ok := GetClientByClientID(clientID)
+	if !ok {
+		return nil, false
+	}
+	// 公开客户端没有密钥，授权码的安全由PKCE保证
+	if client.IsPublic() {
+		return client, clientSecret == ""
+	}
+	if !utils.ComparePasswords(client.ClientSecret, clientSecret) {
+		return nil, false
+	}
+	return client, true
+}
+
+// 注册客户端，返回明文密钥（只在创建时可见），公开客户端和配置了公钥的客户端不生成密钥
+func CreateClient(name string, redirectUris string, scopes string, public bool, orgID uint, publicKey string, firstParty bool) (*model.Client, string, error) {
+	client := model.Client{
+		ClientID:     utils.GenerateHexCode(16),
+		Name:         name,
+		RedirectUris: redirectUris,
+		Scopes:       scopes,
+		OrgID:        orgID,
+		PublicKey:    publicKey,
+		FirstParty:   firstParty,
+	}
+	secret := ""
+	if !public && publicKey == "" {
+		secret = utils.GenerateCode()
+		client.ClientSecret = utils.HashAndSalt(secret)
+	}
+	result := global.DB.Create(&client)
+	if result.Error != nil {
+		return nil, "", result.Error
+	}
+	return &client, secret, nil
+}
+
+// 记录已使用的客户端断言，同一个断言在过期前只能使用一次
+func UseClientAssertion(clientID string, jti string, expiresAt int64) bool {
+	ttl := time.Until(time.Unix(expiresAt, 0))
+	if ttl <= 0 {
+		return false
+	}
+	ok, err := global.Redis.SetNX(fmt.Sprintf("ClientAssertion:%s:%s", clientID, jti), 1, ttl).Result()
+	return err == nil && ok
+}
@@ -0,0 +1,29 @@
+package dao
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUseClientAssertion(t *testing.T) {
+	mr := setupRedis(t)
+	expiresAt := time.Now().Add(5 * time.Minute).Unix()
+	if !UseClientAssertion("client-1", "jti-1", expiresAt) {
+		t.Fatal("第一次使用断言应当成功")
+	}
+	if UseClientAssertion("client-1", "jti-1", expiresAt) {
+		t.Fatal("同一个断言不能重复使用")
+	}
+	// jti按客户端区分
+	if !UseClientAssertion("client-2", "jti-1", expiresAt) {
+		t.Fatal("其他客户端的相同jti应当可以使用")
+	}
+	if UseClientAssertion("client-1", "jti-2", time.Now().Add(-time.Second).Unix()) {
+		t.Fatal("已过期的断言不能使用")
+	}
+	// 断言过期后记录随之删除
+	mr.FastForward(6 * time.Minute)
+	if mr.Exists("ClientAssertion:client-1:jti-1") {
+		t.Fatal("断言过期后不需要继续保留记录")
+	}
+}
@@ -0,0 +1,89 @@
+package dao
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sso-go/global"
+	"sso-go/model"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+var (
+	ErrAuthCodeInvalid = errors.New("授权码无效或已过期")
+	ErrAuthCodeReused  = errors.New("授权码已被使用")
+)
+
+// 兑换授权码：读取并删除授权码，同时写入已使用标记（记录即将签发的token），整个过程原子执行，同一个code只有一个请求能兑换成功
+// 授权码不存在时取出并删除已使用标记，并发的重复兑换只有一个能拿到之前签发的token
+// 返回{1, 授权码}表示兑换成功，{2, 已使用标记}表示重复兑换，{0}表示授权码无效
+var redeemAuthCodeScript = redis.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v then
+	redis.call('DEL', KEYS[1])
+	redis.call('SET', KEYS[2], ARGV[2], 'EX', ARGV[1])
+	return {1, v}
+end
+local used = redis.call('GET', KEYS[2])
+if used then
+	redis.call('DEL', KEYS[2])
+	return {2, used}
+end
+return {0}
+`)
+
+func authCodeKey(code string) string {
+	return fmt.Sprintf("OAuthCode:%s", code)
+}
+
+func authCodeUsedKey(code string) string {
+	return fmt.Sprintf("OAuthCodeUsed:%s", code)
+}
+
+// 保存授权码
+func SaveAuthCode(code string, authCode *model.AuthCode, ttl time.Duration) error {
+	data, err := json.Marshal(authCode)
+	if err != nil {
+		return err
+	}
+	return global.Redis.Set(authCodeKey(code), data, ttl).Err()
+}
+
+// 兑换授权码，授权码只能使用一次
+// issued为兑换成功后将要签发的token，和已使用标记一起写入，
+// 已兑换过的授权码再次出现说明授权码被截获，返回之前用它签发的token以便吊销
+func RedeemAuthCode(code string, issued *model.AuthCodeTokens, usedTTL time.Duration) (*model.AuthCode, *model.AuthCodeTokens, error) {
+	marker, err := json.Marshal(issued)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys := []string{authCodeKey(code), authCodeUsedKey(code)}
+	result, err := redeemAuthCodeScript.Run(global.Redis, keys, int64(usedTTL/time.Second), marker).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	values, _ := result.([]interface{})
+	if len(values) != 2 {
+		return nil, nil, ErrAuthCodeInvalid
+	}
+	data, _ := values[1].(string)
+	if status, _ := values[0].(int64); status == 2 {
+		reused := model.AuthCodeTokens{}
+		if err := json.Unmarshal([]byte(data), &reused); err != nil {
+			return nil, nil, ErrAuthCodeInvalid
+		}
+		return nil, &reused, ErrAuthCodeReused
+	}
+	authCode := model.AuthCode{}
+	if err := json.Unmarshal([]byte(data), &authCode); err != nil {
+		return nil, nil, ErrAuthCodeInvalid
+	}
+	return &authCode, nil, nil
+}
+
+// 授权码兑换后是否又被重复兑换过，重复兑换会清除已使用标记
+func AuthCodeReused(code string) bool {
+	return global.Redis.Exists(authCodeUsedKey(code)).Val() == 0
+}
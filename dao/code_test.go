@@ -0,0 +1,148 @@
+package dao
+
+import (
+	"sso-go/global"
+	"sso-go/model"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+func setupRedis(t *testing.T) *miniredis.Miniredis {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	global.Redis = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		global.Redis.Close()
+		mr.Close()
+	})
+	return mr
+}
+
+func TestRedeemAuthCode(t *testing.T) {
+	setupRedis(t)
+	authCode := &model.AuthCode{UserID: 1, ClientID: "client-1", RedirectUri: "https://app.example.com/cb"}
+	if err := SaveAuthCode("code-1", authCode, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	issued := &model.AuthCodeTokens{TokenID: "jti-1", ExpiresAt: 100, FamilyID: "family-1"}
+	got, reused, err := RedeemAuthCode("code-1", issued, time.Hour)
+	if err != nil {
+		t.Fatalf("第一次兑换失败：%v", err)
+	}
+	if reused != nil || got.UserID != 1 || got.ClientID != "client-1" {
+		t.Fatalf("第一次兑换 = %+v, %+v", got, reused)
+	}
+	if AuthCodeReused("code-1") {
+		t.Fatal("没有重复兑换时不应判定为重复兑换")
+	}
+
+	// 重复兑换返回第一次兑换时记录的jti和family，用来吊销
+	second := &model.AuthCodeTokens{TokenID: "jti-2", ExpiresAt: 200, FamilyID: "family-2"}
+	got, reused, err = RedeemAuthCode("code-1", second, time.Hour)
+	if err != ErrAuthCodeReused {
+		t.Fatalf("重复兑换 err = %v, want %v", err, ErrAuthCodeReused)
+	}
+	if got != nil || reused == nil || *reused != *issued {
+		t.Fatalf("重复兑换 = %+v, %+v, want %+v", got, reused, issued)
+	}
+	if !AuthCodeReused("code-1") {
+		t.Fatal("重复兑换后应判定为重复兑换")
+	}
+
+	// 已使用标记在吊销后清除，之后只是无效的code
+	if _, _, err = RedeemAuthCode("code-1", second, time.Hour); err != ErrAuthCodeInvalid {
+		t.Fatalf("第三次兑换 err = %v, want %v", err, ErrAuthCodeInvalid)
+	}
+	if _, _, err = RedeemAuthCode("unknown", second, time.Hour); err != ErrAuthCodeInvalid {
+		t.Fatalf("不存在的code err = %v, want %v", err, ErrAuthCodeInvalid)
+	}
+}
+
+func TestRedeemAuthCodeConcurrent(t *testing.T) {
+	setupRedis(t)
+	if err := SaveAuthCode("code-1", &model.AuthCode{UserID: 1}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	redeemed, reused := 0, 0
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := RedeemAuthCode("code-1", &model.AuthCodeTokens{TokenID: "jti"}, time.Hour)
+			mu.Lock()
+			defer mu.Unlock()
+			switch err {
+			case nil:
+				redeemed++
+			case ErrAuthCodeReused, ErrAuthCodeInvalid:
+				reused++
+			default:
+				t.Errorf("RedeemAuthCode() err = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if redeemed != 1 || reused != n-1 {
+		t.Fatalf("兑换成功%d次、失败%d次，同一个code只能兑换一次", redeemed, reused)
+	}
+}
+
+func TestRedeemAuthCodeExpired(t *testing.T) {
+	mr := setupRedis(t)
+	if err := SaveAuthCode("code-1", &model.AuthCode{UserID: 1}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(2 * time.Minute)
+	if _, _, err := RedeemAuthCode("code-1", &model.AuthCodeTokens{TokenID: "jti"}, time.Hour); err != ErrAuthCodeInvalid {
+		t.Fatalf("过期的code err = %v, want %v", err, ErrAuthCodeInvalid)
+	}
+}
+
+func TestRedeemAuthCodeConcurrentReplay(t *testing.T) {
+	setupRedis(t)
+	if err := SaveAuthCode("code-1", &model.AuthCode{UserID: 1}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	issued := &model.AuthCodeTokens{TokenID: "jti-1", FamilyID: "family-1"}
+	if _, _, err := RedeemAuthCode("code-1", issued, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// 并发的重复兑换只有一个拿到之前签发的token，其余只是无效的code
+	const n = 10
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reused, invalid := 0, 0
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, tokens, err := RedeemAuthCode("code-1", &model.AuthCodeTokens{TokenID: "jti-2"}, time.Hour)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == ErrAuthCodeReused && tokens != nil && *tokens == *issued:
+				reused++
+			case err == ErrAuthCodeInvalid:
+				invalid++
+			default:
+				t.Errorf("RedeemAuthCode() = %+v, %v", tokens, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if reused != 1 || invalid != n-1 {
+		t.Fatalf("重复兑换%d次、无效%d次，之前签发的token只能取出一次", reused, invalid)
+	}
+}
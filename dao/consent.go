@@ -0,0 +1,103 @@
+package dao
+
+import (
+	"encoding/json"
+	"fmt"
+	"sso-go/global"
+	"sso-go/model"
+	"sso-go/utils"
+	"strings"
+	"time"
+)
+
+func consentTicketKey(ticket string) string {
+	return fmt.Sprintf("ConsentTicket:%s", ticket)
+}
+
+// 签发授权确认凭证
+func SaveConsentTicket(record *model.ConsentTicket, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	ticket := utils.GenerateCode()
+	if err := global.Redis.Set(consentTicketKey(ticket), data, ttl).Err(); err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// 使用授权确认凭证，取出的同时删除，每个凭证只能使用一次
+func UseConsentTicket(ticket string) (*model.ConsentTicket, bool) {
+	if ticket == "" {
+		return nil, false
+	}
+	pipe := global.Redis.TxPipeline()
+	get := pipe.Get(consentTicketKey(ticket))
+	pipe.Del(consentTicketKey(ticket))
+	if _, err := pipe.Exec(); err != nil {
+		return nil, false
+	}
+	record := model.ConsentTicket{}
+	if err := json.Unmarshal([]byte(get.Val()), &record); err != nil {
+		return nil, false
+	}
+	return &record, true
+}
+
+// 获取用户对客户端的授权同意记录
+func GetConsent(userID uint, clientID string) (*model.Consent, bool) {
+	var consent model.Consent
+	rows := global.DB.Limit(1).Where("user_id = ? AND client_id = ?", userID, clientID).Find(&consent)
+	if rows.RowsAffected < 1 {
+		return nil, false
+	}
+	return &consent, true
+}
+
+// 记录用户同意的scope，与之前同意过的合并
+func SaveConsent(userID uint, clientID string, scope string) error {
+	consent, ok := GetConsent(userID, clientID)
+	if !ok {
+		return global.DB.Create(&model.Consent{
+			UserID:   userID,
+			ClientID: clientID,
+			Scopes:   strings.Join(strings.Fields(scope), " "),
+		}).Error
+	}
+	scopes := strings.Fields(consent.Scopes)
+	scopes = append(scopes, consent.Missing(scope)...)
+	// 没有新增scope时也更新时间，记录最近一次同意
+	return global.DB.Model(consent).Update("scopes", strings.Join(scopes, " ")).Error
+}
+
+// 用户已授权的应用
+func ListConsents(userID uint) []map[string]interface{} {
+	type row struct {
+		model.Consent
+		Name string
+	}
+	var rows []row
+	global.DB.Model(&model.Consent{}).
+		Select("consents.*, clients.name").
+		Joins("JOIN clients ON clients.client_id = consents.client_id").
+		Where("consents.user_id = ?", userID).
+		Order("consents.updated_at DESC").Scan(&rows)
+	consents := make([]map[string]interface{}, 0, len(rows))
+	for _, r := range rows {
+		consents = append(consents, map[string]interface{}{
+			"client_id":   r.ClientID,
+			"client_name": r.Name,
+			"scopes":      strings.Fields(r.Scopes),
+			"created_at":  r.CreatedAt,
+			"updated_at":  r.UpdatedAt,
+		})
+	}
+	return consents
+}
+
+// 撤销用户对客户端的授权，没有记录时返回false
+func DeleteConsent(userID uint, clientID string) bool {
+	rows := global.DB.Where("user_id = ? AND client_id = ?", userID, clientID).Delete(&model.Consent{})
+	return rows.Error == nil && rows.RowsAffected > 0
+}
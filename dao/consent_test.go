@@ -0,0 +1,64 @@
+package dao
+
+import (
+	"sso-go/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConsentTicket(t *testing.T) {
+	mr := setupRedis(t)
+	record := &model.ConsentTicket{UserID: 1, SessionID: "sid-1", ClientID: "client-1", Scope: "openid profile"}
+	ticket, err := SaveConsentTicket(record, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := UseConsentTicket(ticket)
+	if !ok || *got != *record {
+		t.Fatalf("UseConsentTicket() = %+v, %v", got, ok)
+	}
+	// 凭证只能使用一次
+	if _, ok := UseConsentTicket(ticket); ok {
+		t.Fatal("凭证被使用了两次")
+	}
+	if _, ok := UseConsentTicket(""); ok {
+		t.Fatal("空凭证不应通过")
+	}
+
+	ticket, err = SaveConsentTicket(record, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(2 * time.Minute)
+	if _, ok := UseConsentTicket(ticket); ok {
+		t.Fatal("过期的凭证不应通过")
+	}
+}
+
+func TestConsentTicketConcurrent(t *testing.T) {
+	setupRedis(t)
+	ticket, err := SaveConsentTicket(&model.ConsentTicket{UserID: 1, ClientID: "client-1"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 10
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	used := 0
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := UseConsentTicket(ticket); ok {
+				mu.Lock()
+				used++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if used != 1 {
+		t.Fatalf("凭证被使用了%d次，只能使用一次", used)
+	}
+}
@@ -0,0 +1,190 @@
+package dao
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sso-go/global"
+	"sso-go/model"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+var (
+	ErrDeviceCodeInvalid  = errors.New("device_code无效")
+	ErrDeviceCodeExpired  = errors.New("device_code已过期，请重新发起授权")
+	ErrDeviceCodePending  = errors.New("等待用户确认授权")
+	ErrDeviceCodeSlowDown = errors.New("轮询过于频繁，请增加轮询间隔")
+	ErrDeviceCodeDenied   = errors.New("用户拒绝了授权")
+	ErrUserCodeInvalid    = errors.New("验证码无效或已过期")
+	ErrUserCodeTaken      = errors.New("user_code已被占用")
+)
+
+// 轮询device_code：依次检查过期、轮询间隔和授权状态，同意或拒绝后立即删除，整个过程原子执行
+// 返回1和授权记录表示已同意，0表示等待确认，-1表示不存在，-2表示轮询过快，-3表示已过期，-4表示已拒绝
+var pollDeviceCodeScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'client_id') ~= ARGV[1] then
+	return {-1}
+end
+local now = tonumber(ARGV[2])
+local userCodeKey = 'DeviceUserCode:' .. redis.call('HGET', KEYS[1], 'user_code')
+if now > tonumber(redis.call('HGET', KEYS[1], 'expires_at')) then
+	redis.call('DEL', KEYS[1], userCodeKey)
+	return {-3}
+end
+local last = tonumber(redis.call('HGET', KEYS[1], 'last_poll') or '0')
+redis.call('HSET', KEYS[1], 'last_poll', now)
+if now - last < tonumber(redis.call('HGET', KEYS[1], 'interval')) then
+	redis.call('HINCRBY', KEYS[1], 'interval', 5)
+	return {-2}
+end
+local status = redis.call('HGET', KEYS[1], 'status')
+if status == 'approved' then
+	local fields = redis.call('HGETALL', KEYS[1])
+	redis.call('DEL', KEYS[1], userCodeKey)
+	return {1, fields}
+end
+if status == 'denied' then
+	redis.call('DEL', KEYS[1], userCodeKey)
+	return {-4}
+end
+return {0}
+`)
+
+// 用户确认或拒绝设备授权，只有等待确认的授权可以处理，处理后user_code立即失效
+var decideDeviceCodeScript = redis.NewScript(`
+local hash = redis.call('GET', KEYS[1])
+if not hash then
+	return 0
+end
+local key = 'DeviceCode:' .. hash
+if redis.call('HGET', key, 'status') ~= 'pending' then
+	return 0
+end
+redis.call('HMSET', key, 'status', ARGV[1], 'user_id', ARGV[2], 'org_id', ARGV[3], 'auth_time', ARGV[4])
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
+// redis中只保存device_code的哈希
+func deviceCodeHash(deviceCode string) string {
+	sum := sha256.Sum256([]byte(deviceCode))
+	return hex.EncodeToString(sum[:])
+}
+
+func deviceCodeKey(hash string) string {
+	return fmt.Sprintf("DeviceCode:%s", hash)
+}
+
+func deviceUserCodeKey(userCode string) string {
+	return fmt.Sprintf("DeviceUserCode:%s", userCode)
+}
+
+func deviceCodeFromHash(fields map[string]string) *model.DeviceCode {
+	userID, _ := strconv.ParseUint(fields["user_id"], 10, 64)
+	orgID, _ := strconv.ParseUint(fields["org_id"], 10, 64)
+	authTime, _ := strconv.ParseInt(fields["auth_time"], 10, 64)
+	interval, _ := strconv.ParseInt(fields["interval"], 10, 64)
+	expiresAt, _ := strconv.ParseInt(fields["expires_at"], 10, 64)
+	return &model.DeviceCode{
+		ClientID:  fields["client_id"],
+		Scope:     fields["scope"],
+		UserCode:  fields["user_code"],
+		Status:    fields["status"],
+		UserID:    uint(userID),
+		OrgID:     uint(orgID),
+		AuthTime:  authTime,
+		Interval:  interval,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// 保存设备授权，ttl比有效期长一些，过期后的轮询可以返回expired_token；user_code已被占用时返回ErrUserCodeTaken
+func SaveDeviceCode(deviceCode string, record *model.DeviceCode, ttl time.Duration) error {
+	hash := deviceCodeHash(deviceCode)
+	ok, err := global.Redis.SetNX(deviceUserCodeKey(record.UserCode), hash, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrUserCodeTaken
+	}
+	pipe := global.Redis.TxPipeline()
+	pipe.HMSet(deviceCodeKey(hash), map[string]interface{}{
+		"client_id":  record.ClientID,
+		"scope":      record.Scope,
+		"user_code":  record.UserCode,
+		"status":     record.Status,
+		"interval":   record.Interval,
+		"expires_at": record.ExpiresAt,
+	})
+	pipe.Expire(deviceCodeKey(hash), ttl)
+	_, err = pipe.Exec()
+	return err
+}
+
+// 根据user_code获取等待确认的设备授权
+func GetDeviceCodeByUserCode(userCode string) (*model.DeviceCode, bool) {
+	hash, err := global.Redis.Get(deviceUserCodeKey(userCode)).Result()
+	if err != nil {
+		return nil, false
+	}
+	fields, err := global.Redis.HGetAll(deviceCodeKey(hash)).Result()
+	if err != nil || len(fields) == 0 {
+		return nil, false
+	}
+	record := deviceCodeFromHash(fields)
+	if record.Status != model.DeviceCodePending || time.Now().Unix() > record.ExpiresAt {
+		return nil, false
+	}
+	return record, true
+}
+
+// 用户确认（approved）或拒绝（denied）设备授权
+func DecideDeviceCode(userCode string, status string, userID uint, orgID uint, authTime int64) error {
+	result, err := decideDeviceCodeScript.Run(global.Redis, []string{deviceUserCodeKey(userCode)}, status, userID, orgID, authTime).Int64()
+	if err != nil {
+		return err
+	}
+	if result != 1 {
+		return ErrUserCodeInvalid
+	}
+	return nil
+}
+
+// 设备轮询换取token，只有申请授权的客户端可以轮询；用户同意后返回授权记录，记录随即删除，只能换取一次
+func PollDeviceCode(deviceCode string, clientID string) (*model.DeviceCode, error) {
+	result, err := pollDeviceCodeScript.Run(global.Redis, []string{deviceCodeKey(deviceCodeHash(deviceCode))}, clientID, time.Now().Unix()).Result()
+	if err != nil {
+		return nil, err
+	}
+	values, _ := result.([]interface{})
+	if len(values) == 0 {
+		return nil, ErrDeviceCodeInvalid
+	}
+	status, _ := values[0].(int64)
+	switch status {
+	case 1:
+		list, _ := values[1].([]interface{})
+		fields := map[string]string{}
+		for i := 0; i+1 < len(list); i += 2 {
+			key, _ := list[i].(string)
+			value, _ := list[i+1].(string)
+			fields[key] = value
+		}
+		return deviceCodeFromHash(fields), nil
+	case 0:
+		return nil, ErrDeviceCodePending
+	case -2:
+		return nil, ErrDeviceCodeSlowDown
+	case -3:
+		return nil, ErrDeviceCodeExpired
+	case -4:
+		return nil, ErrDeviceCodeDenied
+	default:
+		return nil, ErrDeviceCodeInvalid
+	}
+}
@@ -0,0 +1,133 @@
+package dao
+
+import (
+	"sso-go/model"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func saveTestDeviceCode(t *testing.T, deviceCode string, userCode string, expiresAt time.Time) {
+	record := &model.DeviceCode{
+		ClientID:  "client-1",
+		Scope:     "openid",
+		UserCode:  userCode,
+		Status:    model.DeviceCodePending,
+		Interval:  5,
+		ExpiresAt: expiresAt.Unix(),
+	}
+	if err := SaveDeviceCode(deviceCode, record, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceCodeApproved(t *testing.T) {
+	mr := setupRedis(t)
+	saveTestDeviceCode(t, "device-1", "ABCD-EFGH", time.Now().Add(10*time.Minute))
+	// redis中只保存device_code的哈希
+	if mr.Exists(deviceCodeKey("device-1")) {
+		t.Fatal("redis中保存了device_code原文")
+	}
+
+	record, ok := GetDeviceCodeByUserCode("ABCD-EFGH")
+	if !ok || record.ClientID != "client-1" || record.Scope != "openid" {
+		t.Fatalf("GetDeviceCodeByUserCode() = %+v, %v", record, ok)
+	}
+	if _, err := PollDeviceCode("device-1", "client-1"); err != ErrDeviceCodePending {
+		t.Fatalf("确认前轮询 err = %v, want %v", err, ErrDeviceCodePending)
+	}
+
+	authTime := time.Now().Unix()
+	if err := DecideDeviceCode("ABCD-EFGH", model.DeviceCodeApproved, 1, 2, authTime); err != nil {
+		t.Fatal(err)
+	}
+	// 处理后user_code立即失效，不能再次确认或拒绝
+	if _, ok := GetDeviceCodeByUserCode("ABCD-EFGH"); ok {
+		t.Fatal("确认后user_code仍然可用")
+	}
+	if err := DecideDeviceCode("ABCD-EFGH", model.DeviceCodeDenied, 3, 0, authTime); err != ErrUserCodeInvalid {
+		t.Fatalf("再次处理 err = %v, want %v", err, ErrUserCodeInvalid)
+	}
+
+	// 只有申请授权的客户端可以轮询
+	if _, err := PollDeviceCode("device-1", "client-2"); err != ErrDeviceCodeInvalid {
+		t.Fatalf("其他客户端轮询 err = %v, want %v", err, ErrDeviceCodeInvalid)
+	}
+	// 跳过轮询间隔
+	mr.HSet(deviceCodeKey(deviceCodeHash("device-1")), "last_poll", "0")
+	record, err := PollDeviceCode("device-1", "client-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != model.DeviceCodeApproved || record.UserID != 1 || record.OrgID != 2 || record.AuthTime != authTime || record.ClientID != "client-1" {
+		t.Fatalf("PollDeviceCode() = %+v", record)
+	}
+	// 授权记录只能换取一次token
+	if _, err := PollDeviceCode("device-1", "client-1"); err != ErrDeviceCodeInvalid {
+		t.Fatalf("再次轮询 err = %v, want %v", err, ErrDeviceCodeInvalid)
+	}
+}
+
+func TestDeviceCodeDenied(t *testing.T) {
+	mr := setupRedis(t)
+	saveTestDeviceCode(t, "device-1", "ABCD-EFGH", time.Now().Add(10*time.Minute))
+	if err := DecideDeviceCode("ABCD-EFGH", model.DeviceCodeDenied, 1, 0, time.Now().Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PollDeviceCode("device-1", "client-1"); err != ErrDeviceCodeDenied {
+		t.Fatalf("拒绝后轮询 err = %v, want %v", err, ErrDeviceCodeDenied)
+	}
+	if mr.Exists(deviceCodeKey(deviceCodeHash("device-1"))) {
+		t.Fatal("拒绝后轮询没有删除授权记录")
+	}
+	if err := DecideDeviceCode("unknown", model.DeviceCodeApproved, 1, 0, 0); err != ErrUserCodeInvalid {
+		t.Fatalf("不存在的user_code err = %v, want %v", err, ErrUserCodeInvalid)
+	}
+}
+
+func TestDeviceCodeSlowDown(t *testing.T) {
+	mr := setupRedis(t)
+	saveTestDeviceCode(t, "device-1", "ABCD-EFGH", time.Now().Add(10*time.Minute))
+	key := deviceCodeKey(deviceCodeHash("device-1"))
+	if _, err := PollDeviceCode("device-1", "client-1"); err != ErrDeviceCodePending {
+		t.Fatalf("第一次轮询 err = %v", err)
+	}
+	// 间隔内再次轮询，间隔增加5秒
+	if _, err := PollDeviceCode("device-1", "client-1"); err != ErrDeviceCodeSlowDown {
+		t.Fatalf("轮询过快 err = %v, want %v", err, ErrDeviceCodeSlowDown)
+	}
+	if interval := mr.HGet(key, "interval"); interval != "10" {
+		t.Fatalf("interval = %s, want 10", interval)
+	}
+	// 间隔按上一次轮询的时间计算，过快的轮询也会刷新时间
+	mr.HSet(key, "last_poll", strconv.FormatInt(time.Now().Unix()-9, 10))
+	if _, err := PollDeviceCode("device-1", "client-1"); err != ErrDeviceCodeSlowDown {
+		t.Fatalf("未达到新的间隔 err = %v, want %v", err, ErrDeviceCodeSlowDown)
+	}
+}
+
+func TestDeviceCodeExpired(t *testing.T) {
+	mr := setupRedis(t)
+	saveTestDeviceCode(t, "device-1", "ABCD-EFGH", time.Now().Add(-time.Second))
+	if _, ok := GetDeviceCodeByUserCode("ABCD-EFGH"); ok {
+		t.Fatal("过期的user_code不应可用")
+	}
+	if _, err := PollDeviceCode("device-1", "client-1"); err != ErrDeviceCodeExpired {
+		t.Fatalf("过期后轮询 err = %v, want %v", err, ErrDeviceCodeExpired)
+	}
+	if mr.Exists(deviceUserCodeKey("ABCD-EFGH")) {
+		t.Fatal("过期后没有删除user_code")
+	}
+	if _, err := PollDeviceCode("device-1", "client-1"); err != ErrDeviceCodeInvalid {
+		t.Fatalf("删除后轮询 err = %v, want %v", err, ErrDeviceCodeInvalid)
+	}
+}
+
+func TestDeviceUserCodeTaken(t *testing.T) {
+	setupRedis(t)
+	saveTestDeviceCode(t, "device-1", "ABCD-EFGH", time.Now().Add(10*time.Minute))
+	err := SaveDeviceCode("device-2", &model.DeviceCode{ClientID: "client-1", UserCode: "ABCD-EFGH", Status: model.DeviceCodePending}, time.Hour)
+	if err != ErrUserCodeTaken {
+		t.Fatalf("重复的user_code err = %v, want %v", err, ErrUserCodeTaken)
+	}
+}
@@ -0,0 +1,159 @@
+package dao
+
+import (
+	"errors"
+	"fmt"
+	"sso-go/global"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// 邮箱验证码有效期
+const EmailCodeExpire = 5 * time.Minute
+
+// 验证码的使用场景，不同场景的验证码互不通用
+const (
+	EmailCodeRegister    = "register"
+	EmailCodeChangeEmail = "change_email"
+)
+
+var (
+	ErrEmailCodeCooldown = errors.New("验证码发送过于频繁，请稍后再试")
+	ErrEmailCodeQuota    = errors.New("今日验证码发送次数已达上限")
+	ErrEmailCodeInvalid  = errors.New("邮箱验证码错误")
+	ErrEmailCodeExpired  = errors.New("验证码无效或已过期，请重新获取")
+	ErrEmailCodeBurned   = errors.New("验证码错误次数过多，请重新获取")
+)
+
+// 校验验证码：错误时累计次数，达到上限后删除验证码，整个过程原子执行
+// 返回1表示正确，0表示错误，-1表示验证码不存在，-2表示错误次数达到上限、验证码已作废
+var checkEmailCodeScript = redis.NewScript(`
+local code = redis.call('HGET', KEYS[1], 'code')
+if not code then
+	return -1
+end
+if code == ARGV[1] then
+	return 1
+end
+local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+if attempts >= tonumber(ARGV[2]) then
+	redis.call('DEL', KEYS[1])
+	return -2
+end
+return 0
+`)
+
+func emailCodeKey(scene string, email string) string {
+	return fmt.Sprintf("EmailCode:%s:%s", scene, strings.ToLower(email))
+}
+
+func emailCooldownKey(email string) string {
+	return fmt.Sprintf("EmailCodeCooldown:%s", strings.ToLower(email))
+}
+
+func emailQuotaKey(scope string, id string) string {
+	return fmt.Sprintf("EmailCodeQuota:%s:%s:%s", scope, strings.ToLower(id), time.Now().Format("20060102"))
+}
+
+// 同一邮箱两次发送的最小间隔，默认60秒
+func emailCodeCooldown() time.Duration {
+	if n := global.Settings.Security.EmailCodeCooldown; n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return time.Minute
+}
+
+// 同一邮箱每天最多发送次数，默认10次
+func emailDailyLimit() int64 {
+	if n := global.Settings.Security.EmailDailyLimit; n > 0 {
+		return n
+	}
+	return 10
+}
+
+// 同一IP每天最多发送次数，默认50次
+func ipEmailDailyLimit() int64 {
+	if n := global.Settings.Security.IPEmailDailyLimit; n > 0 {
+		return n
+	}
+	return 50
+}
+
+// 验证码最多可以输错几次，默认5次
+func emailCodeAttempts() int64 {
+	if n := global.Settings.Security.EmailCodeAttempts; n > 0 {
+		return n
+	}
+	return 5
+}
+
+// 发送前检查发送间隔和每日次数，通过后占用本次发送额度
+func AcquireEmailCodeQuota(email string, ip string) error {
+	ok, err := global.Redis.SetNX(emailCooldownKey(email), 1, emailCodeCooldown()).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrEmailCodeCooldown
+	}
+	for _, quota := range []struct {
+		key   string
+		limit int64
+	}{
+		{emailQuotaKey("email", email), emailDailyLimit()},
+		{emailQuotaKey("ip", ip), ipEmailDailyLimit()},
+	} {
+		count, err := global.Redis.Incr(quota.key).Result()
+		if err != nil {
+			return err
+		}
+		if count == 1 {
+			global.Redis.Expire(quota.key, 24*time.Hour)
+		}
+		if count > quota.limit {
+			return ErrEmailCodeQuota
+		}
+	}
+	return nil
+}
+
+// 邮件发送失败时释放发送间隔，允许立即重试
+func ReleaseEmailCodeCooldown(email string) {
+	global.Redis.Del(emailCooldownKey(email))
+}
+
+// 保存验证码，重新发送会覆盖之前的验证码并重置错误次数
+func SaveEmailCode(scene string, email string, code string) error {
+	key := emailCodeKey(scene, email)
+	pipe := global.Redis.TxPipeline()
+	pipe.Del(key)
+	pipe.HSet(key, "code", code)
+	pipe.Expire(key, EmailCodeExpire)
+	_, err := pipe.Exec()
+	return err
+}
+
+// 校验验证码，输错次数达到上限后验证码作废，需要重新获取
+func VerifyEmailCode(scene string, email string, code string) error {
+	result, err := checkEmailCodeScript.Run(global.Redis, []string{emailCodeKey(scene, email)}, code, emailCodeAttempts()).Int64()
+	if err != nil {
+		return err
+	}
+	switch result {
+	case 1:
+		return nil
+	case 0:
+		return ErrEmailCodeInvalid
+	case -1:
+		return ErrEmailCodeExpired
+	default:
+		return ErrEmailCodeBurned
+	}
+}
+
+// 验证码使用后立即删除，不能重复使用
+func ConsumeEmailCode(scene string, email string) {
+	global.Redis.Del(emailCodeKey(scene, email))
+}
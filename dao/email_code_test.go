@@ -0,0 +1,105 @@
+package dao
+
+import (
+	"sso-go/global"
+	"testing"
+	"time"
+)
+
+func TestAcquireEmailCodeQuota(t *testing.T) {
+	mr := setupRedis(t)
+	security := global.Settings.Security
+	t.Cleanup(func() { global.Settings.Security = security })
+	global.Settings.Security.EmailCodeCooldown = 60
+	global.Settings.Security.EmailDailyLimit = 2
+	global.Settings.Security.IPEmailDailyLimit = 3
+
+	if err := AcquireEmailCodeQuota("a@example.com", "1.1.1.1"); err != nil {
+		t.Fatal(err)
+	}
+	// 发送间隔内不能重复发送，邮箱不区分大小写
+	if err := AcquireEmailCodeQuota("A@Example.com", "1.1.1.1"); err != ErrEmailCodeCooldown {
+		t.Fatalf("发送间隔内 err = %v, want %v", err, ErrEmailCodeCooldown)
+	}
+	mr.FastForward(time.Minute)
+	if err := AcquireEmailCodeQuota("a@example.com", "1.1.1.1"); err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(time.Minute)
+	if err := AcquireEmailCodeQuota("a@example.com", "1.1.1.1"); err != ErrEmailCodeQuota {
+		t.Fatalf("超过邮箱每日次数 err = %v, want %v", err, ErrEmailCodeQuota)
+	}
+
+	// 同一IP给不同邮箱发送也受每日次数限制
+	if err := AcquireEmailCodeQuota("b@example.com", "2.2.2.2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AcquireEmailCodeQuota("c@example.com", "2.2.2.2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AcquireEmailCodeQuota("d@example.com", "2.2.2.2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AcquireEmailCodeQuota("e@example.com", "2.2.2.2"); err != ErrEmailCodeQuota {
+		t.Fatalf("超过IP每日次数 err = %v, want %v", err, ErrEmailCodeQuota)
+	}
+	if err := AcquireEmailCodeQuota("e@example.com", "3.3.3.3"); err != ErrEmailCodeCooldown {
+		t.Fatalf("其他IP err = %v, want %v", err, ErrEmailCodeCooldown)
+	}
+
+	// 发送失败释放发送间隔后可以立即重试
+	ReleaseEmailCodeCooldown("f@example.com")
+	if err := AcquireEmailCodeQuota("f@example.com", "3.3.3.3"); err != nil {
+		t.Fatal(err)
+	}
+	ReleaseEmailCodeCooldown("f@example.com")
+	if err := AcquireEmailCodeQuota("f@example.com", "3.3.3.3"); err != nil {
+		t.Fatalf("释放发送间隔后 err = %v", err)
+	}
+}
+
+func TestVerifyEmailCode(t *testing.T) {
+	setupRedis(t)
+	security := global.Settings.Security
+	t.Cleanup(func() { global.Settings.Security = security })
+	global.Settings.Security.EmailCodeAttempts = 3
+
+	if err := VerifyEmailCode(EmailCodeRegister, "a@example.com", "123456"); err != ErrEmailCodeExpired {
+		t.Fatalf("没有验证码 err = %v, want %v", err, ErrEmailCodeExpired)
+	}
+	if err := SaveEmailCode(EmailCodeRegister, "a@example.com", "123456"); err != nil {
+		t.Fatal(err)
+	}
+	// 不同场景的验证码互不通用
+	if err := VerifyEmailCode(EmailCodeChangeEmail, "a@example.com", "123456"); err != ErrEmailCodeExpired {
+		t.Fatalf("其他场景 err = %v, want %v", err, ErrEmailCodeExpired)
+	}
+	if err := VerifyEmailCode(EmailCodeRegister, "a@example.com", "000000"); err != ErrEmailCodeInvalid {
+		t.Fatalf("第一次输错 err = %v, want %v", err, ErrEmailCodeInvalid)
+	}
+	if err := VerifyEmailCode(EmailCodeRegister, "A@example.com", "123456"); err != nil {
+		t.Fatalf("正确的验证码 err = %v", err)
+	}
+	if err := VerifyEmailCode(EmailCodeRegister, "a@example.com", "000000"); err != ErrEmailCodeInvalid {
+		t.Fatalf("第二次输错 err = %v, want %v", err, ErrEmailCodeInvalid)
+	}
+	// 输错次数达到上限后验证码作废，正确的验证码也不能再用
+	if err := VerifyEmailCode(EmailCodeRegister, "a@example.com", "000000"); err != ErrEmailCodeBurned {
+		t.Fatalf("第三次输错 err = %v, want %v", err, ErrEmailCodeBurned)
+	}
+	if err := VerifyEmailCode(EmailCodeRegister, "a@example.com", "123456"); err != ErrEmailCodeExpired {
+		t.Fatalf("作废后 err = %v, want %v", err, ErrEmailCodeExpired)
+	}
+
+	// 重新发送会重置错误次数，使用后删除
+	if err := SaveEmailCode(EmailCodeRegister, "a@example.com", "654321"); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyEmailCode(EmailCodeRegister, "a@example.com", "654321"); err != nil {
+		t.Fatal(err)
+	}
+	ConsumeEmailCode(EmailCodeRegister, "a@example.com")
+	if err := VerifyEmailCode(EmailCodeRegister, "a@example.com", "654321"); err != ErrEmailCodeExpired {
+		t.Fatalf("使用后 err = %v, want %v", err, ErrEmailCodeExpired)
+	}
+}
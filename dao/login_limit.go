@@ -0,0 +1,130 @@
+package dao
+
+import (
+	"fmt"
+	"sso-go/global"
+	"strings"
+	"time"
+)
+
+func loginFailKey(scope string, id string) string {
+	return fmt.Sprintf("LoginFail:%s:%s", scope, id)
+}
+
+func loginBlockKey(scope string, id string) string {
+	return fmt.Sprintf("LoginBlock:%s:%s", scope, id)
+}
+
+// 账号连续登录失败多少次后锁定，默认5次
+func maxAccountFailures() int64 {
+	if n := global.Settings.Security.MaxAccountFailures; n > 0 {
+		return n
+	}
+	return 5
+}
+
+// 同一IP登录失败多少次后锁定，默认20次
+func maxIPFailures() int64 {
+	if n := global.Settings.Security.MaxIPFailures; n > 0 {
+		return n
+	}
+	return 20
+}
+
+// 首次锁定时长，默认5分钟
+func lockoutDuration() time.Duration {
+	if n := global.Settings.Security.LockoutSeconds; n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return 5 * time.Minute
+}
+
+// 锁定时长上限，默认1天
+func maxLockoutDuration() time.Duration {
+	if n := global.Settings.Security.MaxLockoutSeconds; n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return 24 * time.Hour
+}
+
+// 失败次数的统计窗口，窗口内没有新的失败则清零，默认15分钟
+func failureWindow() time.Duration {
+	if n := global.Settings.Security.FailureWindow; n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return 15 * time.Minute
+}
+
+// 登录失败计数使用的账号标识：账号存在时按用户ID统计，用户名和邮箱登录共用一个计数；
+// 账号不存在时按输入统计，锁定表现和存在的账号一致，不会暴露账号是否注册
+func LoginAccountKey(nameOrEmail string) string {
+	if user, ok := GetUserByAccount(nameOrEmail); ok {
+		return fmt.Sprintf("user:%d", user.ID)
+	}
+	return "account:" + strings.ToLower(nameOrEmail)
+}
+
+// 登录是否被限制，返回还需要等待的时间
+func LoginBlocked(accountKey string, ip string) time.Duration {
+	wait := global.Redis.TTL(loginBlockKey("account", accountKey)).Val()
+	if ipWait := global.Redis.TTL(loginBlockKey("ip", ip)).Val(); ipWait > wait {
+		wait = ipWait
+	}
+	return wait
+}
+
+// 记录一次登录失败，账号和IP分别计数
+func AddLoginFailure(accountKey string, ip string) {
+	addLoginFailure("account", accountKey, maxAccountFailures())
+	addLoginFailure("ip", ip, maxIPFailures())
+}
+
+func addLoginFailure(scope string, id string, threshold int64) {
+	failKey := loginFailKey(scope, id)
+	count, err := global.Redis.Incr(failKey).Result()
+	if err != nil {
+		return
+	}
+	delay := loginBackoff(count, threshold)
+	// 锁定期间失败次数不能过期，否则解锁后又从头开始退避
+	window := failureWindow()
+	if delay > window {
+		window = delay
+	}
+	global.Redis.Expire(failKey, window)
+	if delay > 0 {
+		global.Redis.Set(loginBlockKey(scope, id), count, delay)
+	}
+}
+
+// 指数退避：第2次失败起分别等待1、2、4…秒，达到阈值后锁定，之后每失败一次锁定时长翻倍，不超过上限
+func loginBackoff(count int64, threshold int64) time.Duration {
+	var delay time.Duration
+	switch {
+	case count >= threshold:
+		delay = lockoutDuration() << uint(minInt64(count-threshold, 20))
+	case count >= 2:
+		delay = time.Second << uint(minInt64(count-2, 20))
+	}
+	if delay > maxLockoutDuration() || delay < 0 {
+		delay = maxLockoutDuration()
+	}
+	return delay
+}
+
+func minInt64(a int64, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// 登录成功后清空账号的失败次数；IP的失败次数不清空，防止用一个正常账号反复给IP解锁
+func ClearLoginFailures(accountKey string) {
+	global.Redis.Del(loginFailKey("account", accountKey), loginBlockKey("account", accountKey))
+}
+
+// 管理员解除IP的登录限制
+func UnlockLoginIP(ip string) {
+	global.Redis.Del(loginFailKey("ip", ip), loginBlockKey("ip", ip))
+}
@@ -0,0 +1,114 @@
+package dao
+
+import (
+	"sso-go/global"
+	"testing"
+	"time"
+)
+
+// 使用默认的登录限制参数，测试结束后恢复
+func defaultSecuritySettings(t *testing.T) {
+	security := global.Settings.Security
+	t.Cleanup(func() { global.Settings.Security = security })
+	global.Settings.Security.MaxAccountFailures = 0
+	global.Settings.Security.MaxIPFailures = 0
+	global.Settings.Security.LockoutSeconds = 0
+	global.Settings.Security.MaxLockoutSeconds = 0
+	global.Settings.Security.FailureWindow = 0
+}
+
+func TestLoginBackoff(t *testing.T) {
+	defaultSecuritySettings(t)
+	tests := []struct {
+		count int64
+		want  time.Duration
+	}{
+		{1, 0},
+		{2, time.Second},
+		{3, 2 * time.Second},
+		{4, 4 * time.Second},
+		{5, 5 * time.Minute},
+		{6, 10 * time.Minute},
+		{13, 21*time.Hour + 20*time.Minute},
+		{14, 24 * time.Hour}, // 5分钟×512超过上限
+		{100, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := loginBackoff(tt.count, 5); got != tt.want {
+			t.Errorf("loginBackoff(%d) = %v, want %v", tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestLoginFailures(t *testing.T) {
+	mr := setupRedis(t)
+	defaultSecuritySettings(t)
+	const account, ip = "user:1", "203.0.113.1"
+
+	AddLoginFailure(account, ip)
+	if wait := LoginBlocked(account, ip); wait > 0 {
+		t.Fatalf("第一次失败不应限制登录，wait = %v", wait)
+	}
+	AddLoginFailure(account, ip)
+	if wait := LoginBlocked(account, ip); wait != time.Second {
+		t.Fatalf("第二次失败后 wait = %v, want 1s", wait)
+	}
+	for i := 3; i <= 5; i++ {
+		AddLoginFailure(account, ip)
+	}
+	if wait := LoginBlocked(account, ip); wait != 5*time.Minute {
+		t.Fatalf("达到阈值后 wait = %v, want 5m", wait)
+	}
+	// 其他账号不受影响，但同一IP的限制对所有账号生效
+	if wait := LoginBlocked("user:2", "203.0.113.2"); wait > 0 {
+		t.Fatalf("其他账号和IP wait = %v", wait)
+	}
+
+	// 锁定到期后失败次数还在，再失败一次锁定时长翻倍
+	mr.FastForward(5*time.Minute + time.Second)
+	if wait := LoginBlocked(account, ip); wait > 0 {
+		t.Fatalf("锁定到期后 wait = %v", wait)
+	}
+	AddLoginFailure(account, ip)
+	if wait := LoginBlocked(account, ip); wait != 10*time.Minute {
+		t.Fatalf("锁定后再次失败 wait = %v, want 10m", wait)
+	}
+
+	// 登录成功只清空账号的计数，IP的计数保留
+	ClearLoginFailures(account)
+	if wait := LoginBlocked(account, "203.0.113.2"); wait > 0 {
+		t.Fatalf("清空后账号 wait = %v", wait)
+	}
+	if !mr.Exists(loginFailKey("ip", ip)) {
+		t.Fatal("登录成功不应清空IP的失败次数")
+	}
+	UnlockLoginIP(ip)
+	if wait := LoginBlocked(account, ip); wait > 0 {
+		t.Fatalf("解除IP限制后 wait = %v", wait)
+	}
+}
+
+func TestLoginFailureWindow(t *testing.T) {
+	mr := setupRedis(t)
+	defaultSecuritySettings(t)
+	const account, ip = "account:test", "203.0.113.1"
+
+	// 统计窗口内没有新的失败，失败次数清零
+	for i := 0; i < 4; i++ {
+		AddLoginFailure(account, ip)
+	}
+	mr.FastForward(15*time.Minute + time.Second)
+	AddLoginFailure(account, ip)
+	if wait := LoginBlocked(account, ip); wait > 0 {
+		t.Fatalf("窗口过期后第一次失败 wait = %v", wait)
+	}
+
+	// IP的阈值单独计算
+	global.Settings.Security.MaxIPFailures = 3
+	for _, other := range []string{"account:a", "account:b", "account:c"} {
+		AddLoginFailure(other, "203.0.113.9")
+	}
+	if wait := LoginBlocked("account:new", "203.0.113.9"); wait != 5*time.Minute {
+		t.Fatalf("IP达到阈值后 wait = %v, want 5m", wait)
+	}
+}
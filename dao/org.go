@@ -0,0 +1,131 @@
+package dao
+
+import (
+	"errors"
+	"sso-go/global"
+	"sso-go/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotOrgMember = errors.New("该账号不属于此组织")
+	ErrOrgHasClient = errors.New("组织下还有客户端，不能删除")
+)
+
+// 组织列表
+func ListOrgs() ([]model.Organization, error) {
+	var orgs []model.Organization
+	err := global.DB.Order("id").Find(&orgs).Error
+	return orgs, err
+}
+
+// 根据ID获取组织
+func GetOrgByID(id uint) (*model.Organization, bool) {
+	var org model.Organization
+	rows := global.DB.Limit(1).Where("id = ?", id).Find(&org)
+	if rows.RowsAffected < 1 {
+		return nil, false
+	}
+	return &org, true
+}
+
+// 根据标识获取组织
+func GetOrgBySlug(slug string) (*model.Organization, bool) {
+	var org model.Organization
+	rows := global.DB.Limit(1).Where("slug = ?", slug).Find(&org)
+	if rows.RowsAffected < 1 {
+		return nil, false
+	}
+	return &org, true
+}
+
+// 创建组织
+func CreateOrg(org *model.Organization) error {
+	return global.DB.Create(org).Error
+}
+
+// 删除组织及其成员关系，组织下还有客户端时不能删除
+func DeleteOrg(orgID uint) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		var clients int64
+		if err := tx.Model(&model.Client{}).Where("org_id = ?", orgID).Count(&clients).Error; err != nil {
+			return err
+		}
+		if clients > 0 {
+			return ErrOrgHasClient
+		}
+		if err := tx.Where("org_id = ?", orgID).Delete(&model.OrgMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", orgID).Delete(&model.Organization{}).Error
+	})
+}
+
+// 获取用户在组织中的成员记录
+func GetOrgMember(orgID uint, userID uint) (*model.OrgMember, bool) {
+	var member model.OrgMember
+	rows := global.DB.Limit(1).Where("org_id = ? AND user_id = ?", orgID, userID).Find(&member)
+	if rows.RowsAffected < 1 {
+		return nil, false
+	}
+	return &member, true
+}
+
+// 用户所属的组织及角色
+func GetUserOrgs(userID uint) []map[string]interface{} {
+	type row struct {
+		model.Organization
+		Role string
+	}
+	var rows []row
+	global.DB.Model(&model.Organization{}).
+		Select("organizations.*, org_members.role").
+		Joins("JOIN org_members ON org_members.org_id = organizations.id").
+		Where("org_members.user_id = ?", userID).
+		Order("organizations.id").Scan(&rows)
+	orgs := make([]map[string]interface{}, 0, len(rows))
+	for _, r := range rows {
+		orgs = append(orgs, map[string]interface{}{
+			"id":   r.ID,
+			"slug": r.Slug,
+			"name": r.Name,
+			"role": r.Role,
+		})
+	}
+	return orgs
+}
+
+// 添加成员，已经是成员时更新角色
+func SaveOrgMember(orgID uint, userID uint, role string) error {
+	// (org_id, user_id)唯一，已经是成员时只更新角色，并发添加同一个成员也只会有一条记录
+	return global.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "org_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"role": role, "updated_at": time.Now()}),
+	}).Create(&model.OrgMember{OrgID: orgID, UserID: userID, Role: role}).Error
+}
+
+// 移除成员
+func DeleteOrgMember(orgID uint, userID uint) error {
+	return global.DB.Where("org_id = ? AND user_id = ?", orgID, userID).Delete(&model.OrgMember{}).Error
+}
+
+// 组织中某个角色的成员数量
+func CountOrgRole(orgID uint, role string) int64 {
+	var count int64
+	global.DB.Model(&model.OrgMember{}).Where("org_id = ? AND role = ?", orgID, role).Count(&count)
+	return count
+}
+
+// 组织成员列表，按用户ID倒序游标分页
+func ListOrgMembers(orgID uint, cursor uint, limit int) ([]model.OrgMember, error) {
+	db := global.DB.Where("org_id = ?", orgID)
+	if cursor > 0 {
+		db = db.Where("user_id < ?", cursor)
+	}
+	var members []model.OrgMember
+	err := db.Order("user_id DESC").Limit(limit).Find(&members).Error
+	return members, err
+}
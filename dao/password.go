@@ -0,0 +1,72 @@
+package dao
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sso-go/global"
+	"sso-go/model"
+	"sso-go/utils"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// 取出并删除重置密码token，保证只能使用一次
+var takePasswordResetScript = redis.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v then
+	redis.call('DEL', KEYS[1])
+	redis.call('DEL', 'PasswordResetUser:' .. v)
+	return v
+end
+return false
+`)
+
+// redis中只保存token的哈希
+func passwordResetKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("PasswordReset:%s", hex.EncodeToString(sum[:]))
+}
+
+func passwordResetUserKey(userID uint) string {
+	return fmt.Sprintf("PasswordResetUser:%d", userID)
+}
+
+// 生成重置密码token，同一用户只保留最新的一个
+func SavePasswordResetToken(userID uint, ttl time.Duration) (string, error) {
+	if old, err := global.Redis.Get(passwordResetUserKey(userID)).Result(); err == nil {
+		global.Redis.Del(old)
+	}
+	token := utils.GenerateCode()
+	key := passwordResetKey(token)
+	pipe := global.Redis.TxPipeline()
+	pipe.Set(key, userID, ttl)
+	pipe.Set(passwordResetUserKey(userID), key, ttl)
+	if _, err := pipe.Exec(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// 使用重置密码token，返回对应的用户ID
+func TakePasswordResetToken(token string) (uint, bool) {
+	if token == "" {
+		return 0, false
+	}
+	data, err := takePasswordResetScript.Run(global.Redis, []string{passwordResetKey(token)}).String()
+	if err != nil {
+		return 0, false
+	}
+	userID, err := strconv.ParseUint(data, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(userID), true
+}
+
+// 更新用户密码，password为加密后的密码
+func UpdateUserPassword(userID uint, password string) error {
+	return global.DB.Model(&model.User{}).Where("id = ?", userID).Update("password", password).Error
+}
@@ -0,0 +1,75 @@
+package dao
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPasswordResetToken(t *testing.T) {
+	mr := setupRedis(t)
+	token, err := SavePasswordResetToken(1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// redis中只保存token的哈希
+	if mr.Exists("PasswordReset:" + token) {
+		t.Fatal("redis中保存了token原文")
+	}
+
+	userID, ok := TakePasswordResetToken(token)
+	if !ok || userID != 1 {
+		t.Fatalf("TakePasswordResetToken() = %d, %v", userID, ok)
+	}
+	// token只能使用一次，使用后用户的记录一并删除
+	if _, ok := TakePasswordResetToken(token); ok {
+		t.Fatal("token被使用了两次")
+	}
+	if mr.Exists(passwordResetUserKey(1)) {
+		t.Fatal("使用token后没有删除用户的记录")
+	}
+	if _, ok := TakePasswordResetToken(""); ok {
+		t.Fatal("空token不应通过")
+	}
+	if _, ok := TakePasswordResetToken("unknown"); ok {
+		t.Fatal("不存在的token不应通过")
+	}
+}
+
+func TestPasswordResetTokenReplaced(t *testing.T) {
+	setupRedis(t)
+	old, err := SavePasswordResetToken(1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := SavePasswordResetToken(2, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := SavePasswordResetToken(1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一用户只保留最新的token
+	if _, ok := TakePasswordResetToken(old); ok {
+		t.Fatal("重新申请后旧token仍然可用")
+	}
+	if userID, ok := TakePasswordResetToken(latest); !ok || userID != 1 {
+		t.Fatalf("最新的token = %d, %v", userID, ok)
+	}
+	if userID, ok := TakePasswordResetToken(other); !ok || userID != 2 {
+		t.Fatalf("其他用户的token = %d, %v", userID, ok)
+	}
+}
+
+func TestPasswordResetTokenExpired(t *testing.T) {
+	mr := setupRedis(t)
+	token, err := SavePasswordResetToken(1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(time.Hour + time.Second)
+	if _, ok := TakePasswordResetToken(token); ok {
+		t.Fatal("过期的token不应通过")
+	}
+}
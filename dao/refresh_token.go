@@ -0,0 +1,153 @@
+package dao
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sso-go/global"
+	"sso-go/model"
+	"sso-go/utils"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh_token无效或已过期")
+	ErrRefreshTokenReused  = errors.New("refresh_token已被使用")
+)
+
+// redis中只保存refresh_token的哈希，防止redis泄露后token被直接使用
+func refreshTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func refreshTokenKey(hash string) string {
+	return fmt.Sprintf("RefreshToken:%s", hash)
+}
+
+func refreshTokenUsedKey(hash string) string {
+	return fmt.Sprintf("RefreshTokenUsed:%s", hash)
+}
+
+func refreshFamilyKey(familyID string) string {
+	return fmt.Sprintf("RefreshFamily:%s", familyID)
+}
+
+func userRefreshFamiliesKey(userID uint) string {
+	return fmt.Sprintf("UserRefreshFamilies:%d", userID)
+}
+
+// 签发refresh_token，FamilyID为空时开启一个新的family
+func IssueRefreshToken(record *model.RefreshToken) (string, error) {
+	if record.FamilyID == "" {
+		record.FamilyID = utils.GenerateHexCode(16)
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	token := utils.GenerateCode()
+	hash := refreshTokenHash(token)
+	ttl := time.Duration(utils.RefreshTokenExpireSeconds()) * time.Second
+
+	pipe := global.Redis.TxPipeline()
+	pipe.Set(refreshTokenKey(hash), data, ttl)
+	pipe.SAdd(refreshFamilyKey(record.FamilyID), hash)
+	pipe.Expire(refreshFamilyKey(record.FamilyID), ttl)
+	pipe.SAdd(userRefreshFamiliesKey(record.UserID), record.FamilyID)
+	pipe.Expire(userRefreshFamiliesKey(record.UserID), ttl)
+	if _, err := pipe.Exec(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// 使用refresh_token，每个refresh_token只能使用一次
+// 已经轮换过的refresh_token再次出现说明token被盗用，整个family全部作废
+func UseRefreshToken(token string) (*model.RefreshToken, error) {
+	record, ok := GetRefreshToken(token)
+	if !ok {
+		return nil, ErrRefreshTokenInvalid
+	}
+	hash := refreshTokenHash(token)
+	// 用SETNX标记已使用，并发请求中只有一个能成功
+	ttl := time.Duration(utils.RefreshTokenExpireSeconds()) * time.Second
+	first, err := global.Redis.SetNX(refreshTokenUsedKey(hash), 1, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !first {
+		global.Lg.Warn("RefreshTokenReused", zap.Any("user_id", record.UserID), zap.Any("family_id", record.FamilyID))
+		RevokeRefreshFamily(record.FamilyID)
+		return nil, ErrRefreshTokenReused
+	}
+	return record, nil
+}
+
+// 作废整个family的refresh_token
+func RevokeRefreshFamily(familyID string) {
+	hashes := global.Redis.SMembers(refreshFamilyKey(familyID)).Val()
+	keys := []string{refreshFamilyKey(familyID)}
+	for _, hash := range hashes {
+		keys = append(keys, refreshTokenKey(hash), refreshTokenUsedKey(hash))
+	}
+	global.Redis.Del(keys...)
+}
+
+// 作废用户所有的refresh_token，修改、重置密码后所有登录都需要重新登录
+func RevokeUserRefreshTokens(userID uint) {
+	key := userRefreshFamiliesKey(userID)
+	for _, familyID := range global.Redis.SMembers(key).Val() {
+		RevokeRefreshFamily(familyID)
+	}
+	global.Redis.Del(key)
+}
+
+// 作废用户签发给某个客户端的refresh_token，撤销对该客户端的授权后使用
+func RevokeUserClientRefreshTokens(userID uint, clientID string) {
+	key := userRefreshFamiliesKey(userID)
+	for _, familyID := range global.Redis.SMembers(key).Val() {
+		// 同一个family属于同一个客户端，取其中任意一条还在的记录判断
+		for _, hash := range global.Redis.SMembers(refreshFamilyKey(familyID)).Val() {
+			data, err := global.Redis.Get(refreshTokenKey(hash)).Bytes()
+			if err != nil {
+				continue
+			}
+			record := model.RefreshToken{}
+			if json.Unmarshal(data, &record) == nil && record.ClientID == clientID {
+				RevokeRefreshFamily(familyID)
+				global.Redis.SRem(key, familyID)
+			}
+			break
+		}
+	}
+}
+
+// 查询refresh_token记录
+func GetRefreshToken(token string) (*model.RefreshToken, bool) {
+	data, err := global.Redis.Get(refreshTokenKey(refreshTokenHash(token))).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	record := model.RefreshToken{}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, false
+	}
+	return &record, true
+}
+
+// 查询还可以使用的refresh_token记录，已经轮换过的refresh_token视为无效
+func GetActiveRefreshToken(token string) (*model.RefreshToken, bool) {
+	record, ok := GetRefreshToken(token)
+	if !ok {
+		return nil, false
+	}
+	if global.Redis.Exists(refreshTokenUsedKey(refreshTokenHash(token))).Val() > 0 {
+		return nil, false
+	}
+	return record, true
+}
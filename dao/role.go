@@ -0,0 +1,312 @@
+package dao
+
+import (
+	"errors"
+	"sort"
+	"sso-go/global"
+	"sso-go/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownRole       = errors.New("角色不存在")
+	ErrUnknownPermission = errors.New("权限不存在")
+)
+
+// 角色列表，包含每个角色的权限
+func ListRoles() ([]model.Role, error) {
+	var roles []model.Role
+	err := global.DB.Preload("Permissions").Order("id").Find(&roles).Error
+	return roles, err
+}
+
+// 根据ID获取角色
+func GetRoleByID(id uint) (*model.Role, bool) {
+	var role model.Role
+	rows := global.DB.Preload("Permissions").Limit(1).Where("id = ?", id).Find(&role)
+	if rows.RowsAffected < 1 {
+		return nil, false
+	}
+	return &role, true
+}
+
+// 根据名称获取角色
+func GetRoleByName(name string) (*model.Role, bool) {
+	var role model.Role
+	rows := global.DB.Limit(1).Where("name = ?", name).Find(&role)
+	if rows.RowsAffected < 1 {
+		return nil, false
+	}
+	return &role, true
+}
+
+// 按名称查询角色ID，有不存在的角色时返回ErrUnknownRole
+func roleIDsByNames(tx *gorm.DB, names []string) ([]uint, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	var ids []uint
+	if err := tx.Model(&model.Role{}).Where("name IN ?", names).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) != len(uniqueStrings(names)) {
+		return nil, ErrUnknownRole
+	}
+	return ids, nil
+}
+
+// 按名称查询权限ID，有不存在的权限时返回ErrUnknownPermission
+func permissionIDsByNames(tx *gorm.DB, names []string) ([]uint, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	var ids []uint
+	if err := tx.Model(&model.Permission{}).Where("name IN ?", names).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) != len(uniqueStrings(names)) {
+		return nil, ErrUnknownPermission
+	}
+	return ids, nil
+}
+
+func uniqueStrings(items []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// 替换角色的权限
+func setRolePermissions(tx *gorm.DB, roleID uint, permissions []string) error {
+	ids, err := permissionIDsByNames(tx, permissions)
+	if err != nil {
+		return err
+	}
+	if err := tx.Where("role_id = ?", roleID).Delete(&model.RolePermission{}).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := tx.Create(&model.RolePermission{RoleID: roleID, PermissionID: id}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 创建角色，permissions为权限名称
+func CreateRole(role *model.Role, permissions []string) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Permissions").Create(role).Error; err != nil {
+			return err
+		}
+		return setRolePermissions(tx, role.ID, permissions)
+	})
+}
+
+// 修改角色的描述和权限
+func UpdateRole(roleID uint, description string, permissions []string) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Role{}).Where("id = ?", roleID).Update("description", description).Error; err != nil {
+			return err
+		}
+		return setRolePermissions(tx, roleID, permissions)
+	})
+}
+
+// 删除角色，同时解除与用户、权限的关联
+func DeleteRole(roleID uint) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", roleID).Delete(&model.RolePermission{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", roleID).Delete(&model.UserRole{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", roleID).Delete(&model.Role{}).Error
+	})
+}
+
+// 拥有该角色的用户ID
+func GetRoleUserIDs(roleID uint) []uint {
+	var ids []uint
+	global.DB.Model(&model.UserRole{}).Where("role_id = ?", roleID).Pluck("user_id", &ids)
+	return ids
+}
+
+// 拥有该权限的用户ID
+func GetPermissionUserIDs(permissionID uint) []uint {
+	var ids []uint
+	global.DB.Model(&model.UserRole{}).
+		Joins("JOIN role_permissions ON role_permissions.role_id = user_roles.role_id").
+		Where("role_permissions.permission_id = ?", permissionID).
+		Distinct().Pluck("user_roles.user_id", &ids)
+	return ids
+}
+
+// 权限列表
+func ListPermissions() ([]model.Permission, error) {
+	var permissions []model.Permission
+	err := global.DB.Order("name").Find(&permissions).Error
+	return permissions, err
+}
+
+// 根据ID获取权限
+func GetPermissionByID(id uint) (*model.Permission, bool) {
+	var permission model.Permission
+	rows := global.DB.Limit(1).Where("id = ?", id).Find(&permission)
+	if rows.RowsAffected < 1 {
+		return nil, false
+	}
+	return &permission, true
+}
+
+// 权限名称是否已存在
+func HasPermission(name string) bool {
+	var count int64
+	global.DB.Model(&model.Permission{}).Where("name = ?", name).Count(&count)
+	return count > 0
+}
+
+// 创建权限
+func CreatePermission(permission *model.Permission) error {
+	return global.DB.Create(permission).Error
+}
+
+// 删除权限，同时从所有角色中移除
+func DeletePermission(permissionID uint) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("permission_id = ?", permissionID).Delete(&model.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", permissionID).Delete(&model.Permission{}).Error
+	})
+}
+
+// 用户的角色
+func GetUserRoles(userID uint) []model.Role {
+	var roles []model.Role
+	global.DB.Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).Order("roles.id").Find(&roles)
+	return roles
+}
+
+// 替换用户的角色，roles为角色名称
+func SetUserRoles(userID uint, roles []string) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		ids, err := roleIDsByNames(tx, roles)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&model.UserRole{}).Error; err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := tx.Create(&model.UserRole{UserID: userID, RoleID: id}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 用户的角色名称和权限名称（多个角色的权限合并去重），签发token时写入
+func GetUserAuthorization(userID uint) ([]string, []string) {
+	roles := []string{}
+	for _, role := range GetUserRoles(userID) {
+		roles = append(roles, role.Name)
+	}
+	var permissions []string
+	global.DB.Model(&model.Permission{}).
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN user_roles ON user_roles.role_id = role_permissions.role_id").
+		Where("user_roles.user_id = ?", userID).
+		Distinct().Pluck("permissions.name", &permissions)
+	sort.Strings(permissions)
+	return roles, permissions
+}
+
+// 包含sso:权限的角色ID子查询
+func ssoRoleIDs() *gorm.DB {
+	return global.DB.Model(&model.RolePermission{}).Select("role_permissions.role_id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("permissions.name LIKE ?", "sso:%")
+}
+
+// 角色是否包含SSO管理接口的权限
+func RoleHasSsoPermission(roleID uint) bool {
+	var count int64
+	global.DB.Model(&model.Role{}).Where("id = ? AND id IN (?)", roleID, ssoRoleIDs()).Count(&count)
+	return count > 0
+}
+
+// 拥有SSO管理接口权限的用户ID
+func GetSsoRoleUserIDs() []uint {
+	var ids []uint
+	global.DB.Model(&model.UserRole{}).Where("role_id IN (?)", ssoRoleIDs()).Distinct().Pluck("user_id", &ids)
+	return ids
+}
+
+// 包含SSO管理接口权限的角色ID
+func GetSsoRoleIDs() map[uint]bool {
+	var ids []uint
+	global.DB.Model(&model.Role{}).Where("id IN (?)", ssoRoleIDs()).Pluck("id", &ids)
+	result := map[uint]bool{}
+	for _, id := range ids {
+		result[id] = true
+	}
+	return result
+}
+
+// 分页查询不含SSO管理权限的角色，name不为空时按名称精确匹配，返回当页角色和总数
+func ListNonSsoRoles(name string, offset int, limit int) ([]model.Role, int64, error) {
+	db := global.DB.Model(&model.Role{}).Where("id NOT IN (?)", ssoRoleIDs())
+	if name != "" {
+		db = db.Where("name = ?", name)
+	}
+	var count int64
+	if err := db.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	var roles []model.Role
+	err := db.Order("id").Offset(offset).Limit(limit).Find(&roles).Error
+	return roles, count, err
+}
+
+// 修改角色名称
+func RenameRole(roleID uint, name string) error {
+	return global.DB.Model(&model.Role{}).Where("id = ?", roleID).Update("name", name).Error
+}
+
+// 为多个用户添加角色，已拥有的跳过
+func AddRoleUsers(roleID uint, userIDs []uint) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		for _, userID := range userIDs {
+			var count int64
+			if err := tx.Model(&model.UserRole{}).Where("user_id = ? AND role_id = ?", userID, roleID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			if err := tx.Create(&model.UserRole{UserID: userID, RoleID: roleID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 移除多个用户的角色
+func RemoveRoleUsers(roleID uint, userIDs []uint) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	return global.DB.Where("role_id = ? AND user_id IN ?", roleID, userIDs).Delete(&model.UserRole{}).Error
+}
@@ -0,0 +1,58 @@
+package dao
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sso-go/global"
+	"sso-go/model"
+	"sso-go/utils"
+	"time"
+)
+
+// 数据库中只保存SCIM token的哈希
+func scimTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// 创建SCIM token，返回明文（只在创建时可见）
+func CreateScimToken(name string) (*model.ScimToken, string, error) {
+	token := utils.GenerateCode()
+	record := model.ScimToken{
+		Name:      name,
+		TokenHash: scimTokenHash(token),
+	}
+	if err := global.DB.Create(&record).Error; err != nil {
+		return nil, "", err
+	}
+	return &record, token, nil
+}
+
+// 根据明文token获取记录，并更新最后使用时间
+func GetScimToken(token string) (*model.ScimToken, bool) {
+	if token == "" {
+		return nil, false
+	}
+	var record model.ScimToken
+	rows := global.DB.Limit(1).Where("token_hash = ?", scimTokenHash(token)).Find(&record)
+	if rows.RowsAffected < 1 {
+		return nil, false
+	}
+	now := time.Now()
+	global.DB.Model(&model.ScimToken{}).Where("id = ?", record.ID).Update("last_used_at", now)
+	record.LastUsedAt = &now
+	return &record, true
+}
+
+// SCIM token列表
+func ListScimTokens() ([]model.ScimToken, error) {
+	var tokens []model.ScimToken
+	err := global.DB.Order("id").Find(&tokens).Error
+	return tokens, err
+}
+
+// 删除SCIM token，立即不能再使用
+func DeleteScimToken(id uint) (bool, error) {
+	result := global.DB.Where("id = ?", id).Delete(&model.ScimToken{})
+	return result.RowsAffected > 0, result.Error
+}
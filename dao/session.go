@@ -0,0 +1,122 @@
+package dao
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sso-go/global"
+	"sso-go/model"
+	"sso-go/utils"
+	"strings"
+	"time"
+)
+
+func ssoSessionKey(id string) string {
+	return fmt.Sprintf("SsoSession:%s", id)
+}
+
+func userSsoSessionsKey(userID uint) string {
+	return fmt.Sprintf("UserSsoSessions:%d", userID)
+}
+
+func ssoSessionSecretHash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// 创建登录会话，返回写入cookie的值（会话ID.密钥）
+func CreateSsoSession(session *model.SsoSession, ttl time.Duration) (string, error) {
+	secret := utils.GenerateCode()
+	session.ID = utils.GenerateHexCode(16)
+	session.SecretHash = ssoSessionSecretHash(secret)
+	session.CreatedAt = time.Now().Unix()
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+	pipe := global.Redis.TxPipeline()
+	pipe.Set(ssoSessionKey(session.ID), data, ttl)
+	pipe.SAdd(userSsoSessionsKey(session.UserID), session.ID)
+	pipe.Expire(userSsoSessionsKey(session.UserID), ttl)
+	if _, err := pipe.Exec(); err != nil {
+		return "", err
+	}
+	return session.ID + "." + secret, nil
+}
+
+// 根据会话ID查询登录会话
+func GetSsoSessionByID(id string) (*model.SsoSession, bool) {
+	if id == "" {
+		return nil, false
+	}
+	data, err := global.Redis.Get(ssoSessionKey(id)).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	session := model.SsoSession{}
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, false
+	}
+	return &session, true
+}
+
+// 根据cookie的值查询登录会话，密钥不匹配时视为不存在
+func GetSsoSession(cookieValue string) (*model.SsoSession, bool) {
+	id, secret, found := strings.Cut(cookieValue, ".")
+	if !found || secret == "" {
+		return nil, false
+	}
+	session, ok := GetSsoSessionByID(id)
+	if !ok {
+		return nil, false
+	}
+	if subtle.ConstantTimeCompare([]byte(session.SecretHash), []byte(ssoSessionSecretHash(secret))) != 1 {
+		return nil, false
+	}
+	return session, true
+}
+
+// 登录会话是否还有效
+func SsoSessionExists(id string) bool {
+	return id != "" && global.Redis.Exists(ssoSessionKey(id)).Val() > 0
+}
+
+// 更新会话选择的组织，有效期不变
+func UpdateSsoSessionOrg(id string, orgID uint) error {
+	session, ok := GetSsoSessionByID(id)
+	if !ok {
+		return nil
+	}
+	ttl := global.Redis.TTL(ssoSessionKey(id)).Val()
+	if ttl <= 0 {
+		return nil
+	}
+	session.OrgID = orgID
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return global.Redis.Set(ssoSessionKey(id), data, ttl).Err()
+}
+
+// 结束登录会话
+func DeleteSsoSession(id string) {
+	session, ok := GetSsoSessionByID(id)
+	if !ok {
+		return
+	}
+	global.Redis.Del(ssoSessionKey(id))
+	global.Redis.SRem(userSsoSessionsKey(session.UserID), id)
+}
+
+// 结束用户所有的登录会话
+func DeleteUserSsoSessions(userID uint) {
+	key := userSsoSessionsKey(userID)
+	keys := []string{key}
+	for _, id := range global.Redis.SMembers(key).Val() {
+		keys = append(keys, ssoSessionKey(id))
+	}
+	global.Redis.Del(keys...)
+}
@@ -0,0 +1,93 @@
+package dao
+
+import (
+	"sso-go/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSsoSession(t *testing.T) {
+	mr := setupRedis(t)
+	session := &model.SsoSession{UserID: 1, AuthTime: 100}
+	cookie, err := CreateSsoSession(session, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, secret, _ := strings.Cut(cookie, ".")
+	if id != session.ID || secret == "" {
+		t.Fatalf("cookie = %s", cookie)
+	}
+	// redis中只保存密钥的哈希
+	if data, _ := mr.Get(ssoSessionKey(id)); strings.Contains(data, secret) {
+		t.Fatal("redis中保存了会话密钥原文")
+	}
+
+	got, ok := GetSsoSession(cookie)
+	if !ok || got.UserID != 1 || got.AuthTime != 100 || got.ID != id {
+		t.Fatalf("GetSsoSession() = %+v, %v", got, ok)
+	}
+	for _, value := range []string{id + ".wrong", id, id + ".", "", "unknown." + secret} {
+		if _, ok := GetSsoSession(value); ok {
+			t.Errorf("GetSsoSession(%q)不应找到会话", value)
+		}
+	}
+	if !SsoSessionExists(id) || SsoSessionExists("") || SsoSessionExists("unknown") {
+		t.Fatal("SsoSessionExists()结果不正确")
+	}
+
+	// 切换组织不改变有效期
+	mr.FastForward(30 * time.Minute)
+	if err := UpdateSsoSessionOrg(id, 5); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := GetSsoSessionByID(id); !ok || got.OrgID != 5 {
+		t.Fatalf("切换组织后 = %+v, %v", got, ok)
+	}
+	if ttl := mr.TTL(ssoSessionKey(id)); ttl > 30*time.Minute {
+		t.Fatalf("切换组织后有效期 = %v", ttl)
+	}
+	mr.FastForward(31 * time.Minute)
+	if SsoSessionExists(id) {
+		t.Fatal("会话过期后仍然有效")
+	}
+}
+
+func TestDeleteSsoSession(t *testing.T) {
+	mr := setupRedis(t)
+	first := &model.SsoSession{UserID: 1}
+	if _, err := CreateSsoSession(first, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	second := &model.SsoSession{UserID: 1}
+	if _, err := CreateSsoSession(second, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	other := &model.SsoSession{UserID: 2}
+	if _, err := CreateSsoSession(other, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// 退出登录只结束当前会话
+	DeleteSsoSession(first.ID)
+	if SsoSessionExists(first.ID) || !SsoSessionExists(second.ID) {
+		t.Fatal("DeleteSsoSession()应只结束当前会话")
+	}
+	if members, _ := mr.Members(userSsoSessionsKey(1)); len(members) != 1 || members[0] != second.ID {
+		t.Fatalf("用户的会话列表 = %v", members)
+	}
+
+	// 修改密码等操作结束用户所有的会话，其他用户不受影响
+	DeleteUserSsoSessions(1)
+	if SsoSessionExists(second.ID) || mr.Exists(userSsoSessionsKey(1)) {
+		t.Fatal("DeleteUserSsoSessions()后仍有会话")
+	}
+	if !SsoSessionExists(other.ID) {
+		t.Fatal("其他用户的会话被结束")
+	}
+	// 不存在的会话
+	DeleteSsoSession("unknown")
+	if err := UpdateSsoSessionOrg("unknown", 1); err != nil || mr.Exists(ssoSessionKey("unknown")) {
+		t.Fatal("不存在的会话不应被创建")
+	}
+}
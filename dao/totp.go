@@ -0,0 +1,166 @@
+package dao
+
+import (
+	"encoding/json"
+	"fmt"
+	"sso-go/global"
+	"sso-go/model"
+	"sso-go/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 两步验证连续失败的次数上限，超过后在窗口期内拒绝验证
+const (
+	mfaMaxFailures   = 5
+	mfaFailureWindow = 15 * time.Minute
+)
+
+func mfaChallengeKey(token string) string {
+	return fmt.Sprintf("MfaChallenge:%s", token)
+}
+
+func mfaFailuresKey(userID uint) string {
+	return fmt.Sprintf("MfaFailures:%d", userID)
+}
+
+// 获取用户的TOTP配置
+func GetUserTotp(userID uint) (*model.UserTotp, bool) {
+	var totp model.UserTotp
+	rows := global.DB.Limit(1).Where("user_id = ?", userID).Find(&totp)
+	if rows.RowsAffected < 1 {
+		return nil, false
+	}
+	return &totp, true
+}
+
+// 保存待确认的TOTP密钥，重新扫码时替换之前未确认的密钥
+func SaveTotpSecret(userID uint, secret string) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND confirmed_at IS NULL", userID).Delete(&model.UserTotp{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.UserTotp{UserID: userID, Secret: secret}).Error
+	})
+}
+
+// 确认开启两步验证，同时生成新的恢复码
+func ConfirmTotp(totp *model.UserTotp, step int64, codeHashes []string) error {
+	now := time.Now()
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(totp).Updates(map[string]interface{}{"confirmed_at": now, "last_step": step}).Error; err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, totp.UserID, codeHashes)
+	})
+}
+
+// 记录验证通过的时间步，只有比上一次大才算成功，防止验证码在有效期内被重放
+func UseTotpStep(totp *model.UserTotp, step int64) bool {
+	rows := global.DB.Model(&model.UserTotp{}).
+		Where("id = ? AND last_step < ?", totp.ID, step).
+		Update("last_step", step)
+	return rows.Error == nil && rows.RowsAffected == 1
+}
+
+// 关闭两步验证，删除TOTP密钥和所有恢复码
+func DeleteUserTotp(userID uint) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.UserTotp{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error
+	})
+}
+
+// 重新生成恢复码，之前的恢复码全部作废
+func ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	codes := make([]model.RecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, model.RecoveryCode{UserID: userID, CodeHash: hash})
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
+
+// 使用恢复码，条件更新保证并发时同一个恢复码只能成功一次
+func UseRecoveryCode(userID uint, code string) bool {
+	rows := global.DB.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, utils.HashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	return rows.Error == nil && rows.RowsAffected == 1
+}
+
+// 剩余可用的恢复码数量
+func CountRecoveryCodes(userID uint) int64 {
+	var count int64
+	global.DB.Model(&model.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count)
+	return count
+}
+
+// 保存两步验证的登录挑战，返回mfa_token
+func SaveMfaChallenge(challenge *model.MfaChallenge, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(challenge)
+	if err != nil {
+		return "", err
+	}
+	token := utils.GenerateCode()
+	if err := global.Redis.Set(mfaChallengeKey(token), data, ttl).Err(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// 读取两步验证的登录挑战
+func GetMfaChallenge(token string) (*model.MfaChallenge, bool) {
+	if token == "" {
+		return nil, false
+	}
+	data, err := global.Redis.Get(mfaChallengeKey(token)).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	challenge := model.MfaChallenge{}
+	if err := json.Unmarshal(data, &challenge); err != nil {
+		return nil, false
+	}
+	return &challenge, true
+}
+
+// 作废登录挑战，返回false说明已经被其他请求使用
+func ConsumeMfaChallenge(token string) bool {
+	deleted, err := global.Redis.Del(mfaChallengeKey(token)).Result()
+	return err == nil && deleted == 1
+}
+
+// 两步验证失败次数是否已达上限
+func MfaLocked(userID uint) bool {
+	count, _ := global.Redis.Get(mfaFailuresKey(userID)).Int64()
+	return count >= mfaMaxFailures
+}
+
+// 记录一次两步验证失败
+func AddMfaFailure(userID uint) {
+	key := mfaFailuresKey(userID)
+	pipe := global.Redis.TxPipeline()
+	pipe.Incr(key)
+	pipe.Expire(key, mfaFailureWindow)
+	_, _ = pipe.Exec()
+}
+
+// 两步验证成功后清空失败次数
+func ClearMfaFailures(userID uint) {
+	global.Redis.Del(mfaFailuresKey(userID))
+}
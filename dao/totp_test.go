@@ -0,0 +1,130 @@
+package dao
+
+import (
+	"sso-go/global"
+	"sso-go/model"
+	"sso-go/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestMfaFailures(t *testing.T) {
+	mr := setupRedis(t)
+	for i := 0; i < mfaMaxFailures; i++ {
+		if MfaLocked(1) {
+			t.Fatalf("失败%d次就被锁定", i)
+		}
+		AddMfaFailure(1)
+	}
+	if !MfaLocked(1) {
+		t.Fatal("失败次数达到上限后应锁定")
+	}
+	if MfaLocked(2) {
+		t.Fatal("其他用户不应被锁定")
+	}
+
+	// 窗口期过后自动解锁
+	mr.FastForward(mfaFailureWindow + time.Second)
+	if MfaLocked(1) {
+		t.Fatal("窗口期过后应解锁")
+	}
+
+	// 验证成功后清空失败次数
+	for i := 0; i < mfaMaxFailures; i++ {
+		AddMfaFailure(1)
+	}
+	ClearMfaFailures(1)
+	if MfaLocked(1) {
+		t.Fatal("清空失败次数后应解锁")
+	}
+}
+
+func TestMfaChallenge(t *testing.T) {
+	setupRedis(t)
+	token, err := SaveMfaChallenge(&model.MfaChallenge{UserID: 1}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge, ok := GetMfaChallenge(token)
+	if !ok || challenge.UserID != 1 {
+		t.Fatalf("GetMfaChallenge() = %+v, %v", challenge, ok)
+	}
+	// 登录挑战只能使用一次
+	if !ConsumeMfaChallenge(token) {
+		t.Fatal("第一次使用登录挑战失败")
+	}
+	if ConsumeMfaChallenge(token) {
+		t.Fatal("登录挑战被使用了两次")
+	}
+	if _, ok := GetMfaChallenge(token); ok {
+		t.Fatal("使用后的登录挑战仍然可以读取")
+	}
+	if _, ok := GetMfaChallenge(""); ok {
+		t.Fatal("空token不应读取到登录挑战")
+	}
+}
+
+// 不连接数据库，只记录生成的SQL，affected为模拟的更新行数
+func setupDryRunDB(t *testing.T, affected func() int64) *[]string {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:3306)/sso", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statements []string
+	if err := db.Callback().Update().After("gorm:update").Register("test:record", func(tx *gorm.DB) {
+		statements = append(statements, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+		tx.RowsAffected = affected()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	old := global.DB
+	global.DB = db
+	t.Cleanup(func() { global.DB = old })
+	return &statements
+}
+
+func TestUseRecoveryCode(t *testing.T) {
+	// 模拟数据库：第一次更新命中未使用的恢复码，之后恢复码已被标记为已使用
+	used := false
+	statements := setupDryRunDB(t, func() int64 {
+		if used {
+			return 0
+		}
+		used = true
+		return 1
+	})
+	if !UseRecoveryCode(1, "ABCD EFGH IJKL MNOP") {
+		t.Fatal("第一次使用恢复码失败")
+	}
+	if UseRecoveryCode(1, "abcd-efgh-ijkl-mnop") {
+		t.Fatal("恢复码被使用了两次")
+	}
+	// 条件更新只匹配本人未使用的恢复码，按规范化后的哈希查找
+	hash := utils.HashRecoveryCode("abcd-efgh-ijkl-mnop")
+	if len(*statements) != 2 {
+		t.Fatalf("SQL = %v", *statements)
+	}
+	for _, sql := range *statements {
+		for _, want := range []string{"user_id = 1", "code_hash = '" + hash + "'", "used_at IS NULL"} {
+			if !strings.Contains(sql, want) {
+				t.Errorf("SQL缺少条件%q：%s", want, sql)
+			}
+		}
+	}
+}
+
+func TestUseTotpStep(t *testing.T) {
+	statements := setupDryRunDB(t, func() int64 { return 1 })
+	if !UseTotpStep(&model.UserTotp{ID: 7}, 100) {
+		t.Fatal("UseTotpStep() = false")
+	}
+	// 只有比上一次验证通过的时间步大才更新，防止验证码被重放
+	if len(*statements) != 1 || !strings.Contains((*statements)[0], "last_step < 100") {
+		t.Fatalf("SQL = %v", *statements)
+	}
+}
@@ -2,46 +2,232 @@ package dao
 
 import (
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 	"sso-go/global"
 	"sso-go/model"
 	"sso-go/utils"
+	"strings"
+	"time"
 )
 
-var user model.User
+// 账号不存在时用来比对的假密码哈希，让登录耗时和账号存在时一致，避免通过响应时间判断账号是否注册
+var dummyPasswordHash = utils.HashAndSalt("sso-go-dummy-password")
 
-// 用户是否存在
-func HasUser(nameOrEmail string) bool {
-	var whereMap map[string]interface{}
+func accountWhere(nameOrEmail string) map[string]interface{} {
 	if utils.IsEmail(nameOrEmail) {
-		whereMap = map[string]interface{}{"email": nameOrEmail}
-	} else {
-		whereMap = map[string]interface{}{"name": nameOrEmail}
+		return map[string]interface{}{"email": nameOrEmail}
 	}
-	rows := global.DB.Limit(1).Where(whereMap).First(&user)
+	return map[string]interface{}{"name": nameOrEmail}
+}
+
+// 用户是否存在，传了orgID时只查找该组织的成员
+func HasUser(nameOrEmail string, orgID ...uint) bool {
+	user, ok := GetUserByAccount(nameOrEmail)
+	if !ok || len(orgID) == 0 || orgID[0] == 0 {
+		return ok
+	}
+	_, ok = GetOrgMember(orgID[0], user.ID)
+	return ok
+}
+
+// 根据用户名或邮箱获取用户信息
+func GetUserByAccount(nameOrEmail string) (*model.User, bool) {
+	var user model.User
+	rows := global.DB.Limit(1).Where(accountWhere(nameOrEmail)).Find(&user)
 	if rows.RowsAffected < 1 {
-		return false
+		return nil, false
 	}
-	return true
+	return &user, true
 }
 
-// UsernameFindUserInfo 通过username找到用户信息
-func GetUserInfoByPw(username string, password string) (*model.User, bool, string) {
-	var whereMap map[string]interface{}
-	if utils.IsEmail(username) {
-		whereMap = map[string]interface{}{"email": username}
-	} else {
-		whereMap = map[string]interface{}{"name": username}
-	}
-	rows := global.DB.Limit(1).Where(whereMap).First(&user)
-	global.Lg.Info("Login", zap.Any("GetUserInfoByPw", whereMap))
+// 根据邮箱获取用户信息，直接查email字段
+// 邮箱格式由表单的email校验保证，不经过IsEmail判断，避免合法邮箱被当作用户名查找
+func GetUserByEmail(email string) (*model.User, bool) {
+	var user model.User
+	rows := global.DB.Limit(1).Where("email = ?", email).Find(&user)
 	if rows.RowsAffected < 1 {
-		global.Lg.Info("Login", zap.Any("GetUserInfoByPw:noRegister", map[string]interface{}{"whereMap": whereMap, "rows": rows.RowsAffected}))
-		return &user, false, "该用户未注册"
+		return nil, false
+	}
+	return &user, true
+}
+
+// 邮箱是否已注册
+func HasEmail(email string) bool {
+	_, ok := GetUserByEmail(email)
+	return ok
+}
+
+// UsernameFindUserInfo 通过username找到用户信息
+// 账号不存在和密码错误返回同样的提示，避免被用来探测哪些账号已注册
+// orgID不为0时只允许该组织的成员登录，其他组织的账号视为不存在
+func GetUserInfoByPw(username string, password string, orgID uint) (*model.User, bool, string) {
+	user, ok := GetUserByAccount(username)
+	global.Lg.Info("Login", zap.Any("GetUserInfoByPw", accountWhere(username)))
+	if ok && orgID != 0 {
+		_, ok = GetOrgMember(orgID, user.ID)
+	}
+	if !ok {
+		utils.ComparePasswords(dummyPasswordHash, password)
+		global.Lg.Info("Login", zap.Any("GetUserInfoByPw:noRegister", accountWhere(username)))
+		return nil, false, "用户名或密码错误"
 	}
 	// 校验密码
 	verifyPassword := utils.ComparePasswords(user.Password, password)
 	if !verifyPassword {
-		return &user, false, "密码验证失败"
+		return nil, false, "用户名或密码错误"
+	}
+	// 密码正确才提示已禁用，不会暴露账号状态
+	if user.Disabled() {
+		return nil, false, "账号已被禁用"
+	}
+	return user, true, "登录成功"
+}
+
+// 更换邮箱，新邮箱已通过验证码验证
+func UpdateUserEmail(userID uint, email string, verifiedAt string) error {
+	return global.DB.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"email":             email,
+		"email_verified_at": verifiedAt,
+	}).Error
+}
+
+// 更新用户资料，只更新传入的字段
+func UpdateUserProfile(userID uint, fields map[string]interface{}) error {
+	return global.DB.Model(&model.User{}).Where("id = ?", userID).Updates(fields).Error
+}
+
+// 根据用户ID获取用户信息
+func GetUserByID(id uint) (*model.User, bool) {
+	var u model.User
+	rows := global.DB.Limit(1).Where("id = ?", id).Find(&u)
+	if rows.RowsAffected < 1 {
+		return nil, false
+	}
+	return &u, true
+}
+
+// 根据用户ID获取未被禁用的用户，签发token、刷新token前使用
+func GetActiveUserByID(id uint) (*model.User, bool) {
+	user, ok := GetUserByID(id)
+	if !ok || user.Disabled() {
+		return nil, false
+	}
+	return user, true
+}
+
+// UserQuery 管理后台查询用户的条件，默认按ID倒序返回
+type UserQuery struct {
+	Keyword     string     // 用户名或邮箱模糊匹配
+	Name        string     // 用户名精确匹配
+	Email       string     // 邮箱精确匹配
+	CreatedFrom *time.Time // 注册时间下限（含）
+	CreatedTo   *time.Time // 注册时间上限（不含）
+	Disabled    *bool      // 是否禁用
+	OrgID       uint       // 只查询该组织的成员，为0表示所有用户
+	ExcludeIDs  []uint     // 排除的用户，SCIM查询时排除管理员等受保护的账号
+	Cursor      uint       // 上一页最后一个用户的ID，为0表示第一页
+	Offset      int        // 跳过的条数，SCIM按startIndex分页时使用，与Cursor二选一
+	Asc         bool       // 按ID正序返回，新用户排在最后，偏移分页时不会打乱已翻过的页
+	Limit       int
+}
+
+// 转义LIKE中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(s)
+}
+
+// 按条件过滤用户，不含分页
+func filterUsers(q UserQuery) *gorm.DB {
+	db := global.DB.Model(&model.User{})
+	if q.Keyword != "" {
+		like := "%" + escapeLike(q.Keyword) + "%"
+		db = db.Where("name LIKE ? OR email LIKE ?", like, like)
+	}
+	if q.Name != "" {
+		db = db.Where("name = ?", q.Name)
+	}
+	if q.Email != "" {
+		db = db.Where("email = ?", q.Email)
+	}
+	if q.CreatedFrom != nil {
+		db = db.Where("created_at >= ?", *q.CreatedFrom)
+	}
+	if q.CreatedTo != nil {
+		db = db.Where("created_at < ?", *q.CreatedTo)
+	}
+	if q.Disabled != nil {
+		if *q.Disabled {
+			db = db.Where("disabled_at IS NOT NULL")
+		} else {
+			db = db.Where("disabled_at IS NULL")
+		}
+	}
+	if q.OrgID > 0 {
+		db = db.Where("id IN (?)", global.DB.Model(&model.OrgMember{}).Select("user_id").Where("org_id = ?", q.OrgID))
+	}
+	if len(q.ExcludeIDs) > 0 {
+		db = db.Where("id NOT IN ?", q.ExcludeIDs)
+	}
+	return db
+}
+
+// 按条件查询用户，基于ID的游标分页，翻页不受新注册用户影响
+func ListUsers(q UserQuery) ([]model.User, error) {
+	db := filterUsers(q)
+	if q.Cursor > 0 {
+		db = db.Where("id < ?", q.Cursor)
+	}
+	if q.Offset > 0 {
+		db = db.Offset(q.Offset)
+	}
+	order := "id DESC"
+	if q.Asc {
+		order = "id"
+	}
+	var users []model.User
+	err := db.Order(order).Limit(q.Limit).Find(&users).Error
+	return users, err
+}
+
+// 符合条件的用户总数，忽略分页参数
+func CountUsers(q UserQuery) (int64, error) {
+	var count int64
+	err := filterUsers(q).Count(&count).Error
+	return count, err
+}
+
+// 创建用户
+func CreateUser(user *model.User) error {
+	return global.DB.Create(user).Error
+}
+
+// 禁用或恢复账号，disabledAt为空表示恢复
+func SetUserDisabledAt(userID uint, disabledAt *time.Time) error {
+	return global.DB.Model(&model.User{}).Where("id = ?", userID).Update("disabled_at", disabledAt).Error
+}
+
+// 删除用户，同时删除两步验证、WebAuthn凭证等关联数据
+func DeleteUser(userID uint) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		for _, m := range []interface{}{&model.UserTotp{}, &model.RecoveryCode{}, &model.WebauthnCredential{}, &model.UserRole{}, &model.OrgMember{}, &model.Consent{}} {
+			if err := tx.Where("user_id = ?", userID).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("id = ?", userID).Delete(&model.User{}).Error
+	})
+}
+
+// 批量获取用户
+func GetUsersByIDs(ids []uint) map[uint]*model.User {
+	users := map[uint]*model.User{}
+	if len(ids) == 0 {
+		return users
+	}
+	var list []model.User
+	global.DB.Where("id IN ?", ids).Find(&list)
+	for i := range list {
+		users[list[i].ID] = &list[i]
 	}
-	return &user, true, "登录成功"
+	return users
 }
@@ -0,0 +1,89 @@
+package dao
+
+import (
+	"encoding/json"
+	"fmt"
+	"sso-go/global"
+	"sso-go/model"
+	"sso-go/utils"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// 取出并删除WebAuthn挑战，保证同一个挑战只能使用一次
+var takeWebauthnSessionScript = redis.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v then
+	redis.call('DEL', KEYS[1])
+	return v
+end
+return false
+`)
+
+func webauthnSessionKey(token string) string {
+	return fmt.Sprintf("WebauthnSession:%s", token)
+}
+
+// 用户注册的所有WebAuthn凭证
+func GetWebauthnCredentials(userID uint) []model.WebauthnCredential {
+	credentials := []model.WebauthnCredential{}
+	global.DB.Where("user_id = ?", userID).Order("id").Find(&credentials)
+	return credentials
+}
+
+// 用户是否注册过WebAuthn凭证
+func HasWebauthnCredential(userID uint) bool {
+	var count int64
+	global.DB.Model(&model.WebauthnCredential{}).Where("user_id = ?", userID).Count(&count)
+	return count > 0
+}
+
+// 保存新注册的凭证
+func CreateWebauthnCredential(credential *model.WebauthnCredential) error {
+	return global.DB.Create(credential).Error
+}
+
+// 认证成功后更新签名计数器和备份状态
+func UpdateWebauthnCredentialUsage(credential *model.WebauthnCredential, signCount uint32, backupState bool) error {
+	return global.DB.Model(credential).Updates(map[string]interface{}{
+		"sign_count":   signCount,
+		"backup_state": backupState,
+		"last_used_at": time.Now(),
+	}).Error
+}
+
+// 删除用户的凭证
+func DeleteWebauthnCredential(userID uint, id uint) bool {
+	rows := global.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&model.WebauthnCredential{})
+	return rows.Error == nil && rows.RowsAffected == 1
+}
+
+// 保存WebAuthn挑战，返回session_token
+func SaveWebauthnSession(session *model.WebauthnSession, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+	token := utils.GenerateCode()
+	if err := global.Redis.Set(webauthnSessionKey(token), data, ttl).Err(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// 取出WebAuthn挑战，取出后立即失效
+func TakeWebauthnSession(token string) (*model.WebauthnSession, bool) {
+	if token == "" {
+		return nil, false
+	}
+	data, err := takeWebauthnSessionScript.Run(global.Redis, []string{webauthnSessionKey(token)}).String()
+	if err != nil {
+		return nil, false
+	}
+	session := model.WebauthnSession{}
+	if err := json.Unmarshal([]byte(data), &session); err != nil {
+		return nil, false
+	}
+	return &session, true
+}
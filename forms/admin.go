@@ -0,0 +1,95 @@
+package forms
+
+type UnlockLoginForm struct {
+	// 被锁定的账号，用户名或邮箱
+	Account string `form:"account" json:"account" binding:"required_without=IP"`
+	// 被锁定的IP
+	IP string `form:"ip" json:"ip" binding:"omitempty,ip"`
+}
+
+type UserListForm struct {
+	// 用户名或邮箱模糊搜索
+	Keyword string `form:"keyword"`
+	// 用户名、邮箱精确匹配
+	Name  string `form:"name"`
+	Email string `form:"email"`
+	// 注册日期范围，格式2006-01-02，包含首尾两天
+	CreatedFrom string `form:"created_from" binding:"omitempty,datetime=2006-01-02"`
+	CreatedTo   string `form:"created_to" binding:"omitempty,datetime=2006-01-02"`
+	// 只查询禁用或正常的账号
+	Disabled *bool `form:"disabled"`
+	// 上一页返回的next_cursor，第一页不传
+	Cursor uint `form:"cursor"`
+	// 每页数量，默认20，最多100
+	Limit int `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type UserIDForm struct {
+	ID uint `form:"id" json:"id" binding:"required"`
+}
+
+type AdminCreateUserForm struct {
+	Username string `form:"name" json:"name" binding:"required,min=2,max=20"`
+	Email    string `form:"email" json:"email" binding:"required,email"`
+	PassWord string `form:"password" json:"password" binding:"required,min=6,max=20"`
+}
+
+type AdminUpdateUserForm struct {
+	ID uint `form:"id" json:"id" binding:"required"`
+	// 只更新传了的字段
+	Username string `form:"name" json:"name" binding:"omitempty,min=2,max=20"`
+	Email    string `form:"email" json:"email" binding:"omitempty,email"`
+	PassWord string `form:"password" json:"password" binding:"omitempty,min=6,max=20"`
+}
+
+type RoleForm struct {
+	// 角色标识，如 admin、editor，创建后不能修改
+	Name string `form:"name" json:"name" binding:"required,min=2,max=64"`
+	// 描述
+	Description string `form:"description" json:"description" binding:"max=255"`
+	// 角色拥有的权限名称
+	Permissions []string `form:"permissions" json:"permissions"`
+}
+
+type UpdateRoleForm struct {
+	ID          uint     `form:"id" json:"id" binding:"required"`
+	Description string   `form:"description" json:"description" binding:"max=255"`
+	Permissions []string `form:"permissions" json:"permissions"`
+}
+
+type PermissionForm struct {
+	// 权限名称，如 users:read
+	Name        string `form:"name" json:"name" binding:"required,min=2,max=128"`
+	Description string `form:"description" json:"description" binding:"max=255"`
+}
+
+type UserRolesForm struct {
+	ID uint `form:"id" json:"id" binding:"required"`
+	// 用户的全部角色名称，为空表示移除所有角色
+	Roles []string `form:"roles" json:"roles"`
+}
+
+type OrgForm struct {
+	// 组织标识，小写字母、数字和-，登录时用来指定组织，创建后不能修改
+	Slug string `form:"slug" json:"slug" binding:"required,min=2,max=64"`
+	Name string `form:"name" json:"name" binding:"required,max=191"`
+}
+
+type OrgMembersForm struct {
+	OrgID  uint `form:"org_id" json:"org_id" binding:"required"`
+	Cursor uint `form:"cursor"`
+	Limit  int  `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type OrgMemberForm struct {
+	OrgID uint `form:"org_id" json:"org_id" binding:"required"`
+	// 用户名或邮箱
+	Account string `form:"account" json:"account" binding:"required"`
+	// 组织内的角色：owner、admin、member
+	Role string `form:"role" json:"role" binding:"required,oneof=owner admin member"`
+}
+
+type RemoveOrgMemberForm struct {
+	OrgID  uint `form:"org_id" json:"org_id" binding:"required"`
+	UserID uint `form:"user_id" json:"user_id" binding:"required"`
+}
@@ -0,0 +1,18 @@
+package forms
+
+type TotpCodeForm struct {
+	// 验证器上的6位验证码，关闭两步验证时也可以使用恢复码
+	Code string `form:"code" json:"code" binding:"required"`
+}
+
+type WebauthnCredentialForm struct {
+	// 凭证列表中的id
+	ID uint `form:"id" json:"id" binding:"required"`
+}
+
+type MfaLoginForm struct {
+	// 登录接口返回的mfa_token
+	MfaToken string `form:"mfa_token" json:"mfa_token" binding:"required"`
+	// 验证器上的6位验证码或恢复码，绑定TOTP时为6位验证码
+	Code string `form:"code" json:"code"`
+}
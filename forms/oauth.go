@@ -0,0 +1,60 @@
+package forms
+
+type AuthorizeForm struct {
+	// 授权类型，目前只支持code
+	ResponseType string `form:"response_type" json:"response_type" binding:"required,eq=code"`
+	// 客户端ID
+	ClientID string `form:"client_id" json:"client_id" binding:"required"`
+	// 回调地址，必须是客户端注册过的地址
+	RedirectUri string `form:"redirect_uri" json:"redirect_uri" binding:"required,url"`
+	// 申请的授权范围，多个用空格分隔
+	Scope string `form:"scope" json:"scope"`
+	// 客户端自定义的状态值，原样带回
+	State string `form:"state" json:"state"`
+	// OIDC的nonce，原样写入id_token
+	Nonce string `form:"nonce" json:"nonce"`
+	// PKCE参数，公开客户端必传
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method" binding:"omitempty,oneof=S256 plain"`
+	// 第三方客户端需要用户确认授权，approve同意，deny拒绝
+	Consent string `form:"consent" json:"consent" binding:"omitempty,oneof=approve deny"`
+	// 同意授权时必传，返回consent_required时签发的一次性凭证
+	ConsentTicket string `form:"consent_ticket" json:"consent_ticket"`
+	// 为none时不展示任何页面，没有登录或需要确认授权时直接带着错误回跳
+	Prompt string `form:"prompt" json:"prompt"`
+	// 登录认证距今超过max_age秒时需要重新登录
+	MaxAge *int64 `form:"max_age" json:"max_age" binding:"omitempty,min=0"`
+}
+
+type TokenForm struct {
+	// 授权类型
+	GrantType string `form:"grant_type" binding:"required"`
+	// 授权码
+	Code string `form:"code"`
+	// 回调地址，必须与申请授权码时一致
+	RedirectUri string `form:"redirect_uri"`
+	// PKCE校验串，申请授权码时带了code_challenge则必传
+	CodeVerifier string `form:"code_verifier"`
+	// grant_type=refresh_token时必传
+	RefreshToken string `form:"refresh_token"`
+	// 刷新时可以申请缩小授权范围
+	Scope string `form:"scope"`
+	// grant_type=urn:ietf:params:oauth:grant-type:device_code时必传
+	DeviceCode string `form:"device_code"`
+}
+
+type DeviceCodeForm struct {
+	// 设备上显示的验证码，不区分大小写，可以带横线
+	UserCode string `form:"user_code" json:"user_code" binding:"required"`
+}
+
+type DeviceDecisionForm struct {
+	UserCode string `form:"user_code" json:"user_code" binding:"required"`
+	// approve同意授权，deny拒绝
+	Action string `form:"action" json:"action" binding:"required,oneof=approve deny"`
+}
+
+type ConsentForm struct {
+	// 要撤销授权的客户端ID
+	ClientID string `form:"client_id" json:"client_id" binding:"required"`
+}
@@ -0,0 +1,60 @@
+package forms
+
+import "encoding/json"
+
+type ScimListForm struct {
+	// 过滤条件，如 userName eq "zhangsan"
+	Filter string `form:"filter"`
+	// 从1开始的起始位置
+	StartIndex int `form:"startIndex"`
+	// 每页数量，默认100，最多200
+	Count *int `form:"count"`
+}
+
+type ScimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type ScimUserForm struct {
+	Schemas []string `json:"schemas"`
+	// 用户名，同时也是登录账号
+	UserName string `json:"userName"`
+	// 邮箱，取primary为true的一个，没有时取第一个
+	Emails []ScimEmail `json:"emails"`
+	// 为false时禁用账号
+	Active *bool `json:"active"`
+	// 初始密码，不传时生成随机密码，用户通过忘记密码设置
+	Password string `json:"password"`
+}
+
+// ScimUserFields SCIM用户对应到本地账号的字段，按本地账号的规则校验
+type ScimUserFields struct {
+	Username string `json:"userName" binding:"required,min=2,max=20"`
+	Email    string `json:"emails" binding:"required,email"`
+	PassWord string `json:"password" binding:"omitempty,min=6,max=20"`
+}
+
+type ScimMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+type ScimGroupForm struct {
+	Schemas []string `json:"schemas"`
+	// 组名称，对应角色标识
+	DisplayName string       `json:"displayName"`
+	Members     []ScimMember `json:"members"`
+}
+
+type ScimPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+type ScimPatchForm struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []ScimPatchOperation `json:"Operations"`
+}
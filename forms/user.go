@@ -16,8 +16,70 @@ type LoginForm struct {
 	Username string `form:"name" json:"name" binding:"required,min=2,max=20"`
 	// 密码
 	PassWord string `form:"password" json:"password" binding:"required,min=6,max=20"`
+	// 登录的组织标识，可选，指定后只有该组织的成员可以登录
+	Org string `form:"org" json:"org"`
+}
+
+type CreateCodeForm struct {
+	// 申请code的客户端，只能由该客户端以相同的回调地址换取token
+	ClientID string `form:"client_id" json:"client_id" binding:"required"`
+	// 回调地址，必须是客户端注册的地址或匹配env.toml中的通配规则
+	RedirectUri string `form:"redirect_uri" json:"redirect_uri" binding:"required"`
+	// 原样拼接到回调地址上
+	State string `form:"state" json:"state"`
+	// 申请的授权范围，多个用空格分隔
+	Scope string `form:"scope" json:"scope"`
+	// PKCE参数，浏览器、移动端直接换取token时建议携带
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method" binding:"omitempty,oneof=S256 plain"`
+}
+
+type GetTokenByCodeForm struct {
+	// 临时授权码
+	Code string `form:"code" binding:"required"`
+	// PKCE校验串，申请code时带了code_challenge则必传
+	CodeVerifier string `form:"code_verifier"`
+	// 必须和申请code时的回调地址相同
+	RedirectUri string `form:"redirect_uri"`
 }
 
 type EmailParams struct {
 	Email string `json:"email" binding:"required,email"`
 }
+
+type ForgotPasswordForm struct {
+	Email string `form:"email" json:"email" binding:"required,email"`
+}
+
+type ResetPasswordForm struct {
+	// 重置密码邮件中的token
+	Token string `form:"token" json:"token" binding:"required"`
+	// 新密码
+	PassWord string `form:"password" json:"password" binding:"required,min=6,max=20"`
+}
+
+type ChangePasswordForm struct {
+	// 当前密码
+	OldPassWord string `form:"old_password" json:"old_password" binding:"required"`
+	// 新密码
+	PassWord string `form:"password" json:"password" binding:"required,min=6,max=20"`
+}
+
+type ChangeEmailForm struct {
+	// 新邮箱
+	Email string `form:"email" json:"email" binding:"required,email"`
+	// 发送到新邮箱的验证码
+	Code string `form:"code" json:"code" binding:"required,len=6"`
+	// 当前密码
+	PassWord string `form:"password" json:"password" binding:"required"`
+}
+
+type ProfileForm struct {
+	// 用户名，同时也是登录账号
+	Username string `form:"name" json:"name" binding:"required,min=2,max=20"`
+}
+
+type SwitchOrgForm struct {
+	// 要切换到的组织，为0表示不选择组织
+	OrgID uint `form:"org_id" json:"org_id"`
+}
@@ -3,9 +3,11 @@ package global
 import (
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-redis/redis"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 	"sso-go/config"
+	"sso-go/storage"
 )
 
 var (
@@ -15,4 +17,6 @@ var (
 	Email    config.EmailConfig
 	DB       *gorm.DB
 	Redis    *redis.Client
+	WebAuthn *webauthn.WebAuthn
+	Storage  storage.Storage
 )
@@ -5,15 +5,20 @@ import (
 	"github.com/fatih/color"
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"net/url"
 	"sso-go/config"
 	"sso-go/global"
 	"sso-go/middlewares"
 	"sso-go/router"
+	"sso-go/storage"
 	"sso-go/utils"
+	"strings"
+	"time"
 )
 
 /*
@@ -43,16 +48,118 @@ func InitConfig() {
 	color.Blue("initConfig", global.Settings.LogsAddress)
 }
 
+/*
+* 初始化jwt签名密钥
+ */
+func InitJWT() {
+	if err := middlewares.LoadKeyring(global.Settings.JWTKey); err != nil {
+		panic(err)
+	}
+	// 定时检查密钥环文件，命令行轮换密钥后自动生效
+	go func() {
+		for range time.Tick(time.Minute) {
+			if err := middlewares.ReloadKeyring(); err != nil {
+				global.Lg.Error("ReloadKeyring", zap.Error(err))
+			}
+		}
+	}()
+}
+
+/*
+* 初始化上传文件的存储后端
+ */
+func InitStorage() {
+	switch driver := storageDriver(); driver {
+	case "local":
+		global.Storage = storage.NewLocal(localStorageDir(), storageBaseURL())
+	default:
+		panic(fmt.Sprintf("不支持的存储后端：%s", driver))
+	}
+}
+
+func storageDriver() string {
+	if driver := global.Settings.Storage.Driver; driver != "" {
+		return driver
+	}
+	return "local"
+}
+
+func localStorageDir() string {
+	if dir := global.Settings.Storage.LocalDir; dir != "" {
+		return dir
+	}
+	return "./uploads"
+}
+
+// 上传文件对外访问的地址前缀，默认issuer加/uploads
+func storageBaseURL() string {
+	if baseURL := global.Settings.Storage.BaseURL; baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/")
+	}
+	return strings.TrimSuffix(global.Settings.Issuer, "/") + "/uploads"
+}
+
+// 本地存储的静态文件路由，取访问地址的路径部分
+func localStorageRoute() string {
+	u, err := url.Parse(storageBaseURL())
+	if err != nil || u.Path == "" || u.Path == "/" {
+		return "/uploads"
+	}
+	return u.Path
+}
+
+/*
+* 初始化WebAuthn，没有配置rpId时不开启
+ */
+func InitWebAuthn() {
+	cfg := global.Settings.WebAuthn
+	if cfg.RPID == "" {
+		return
+	}
+	rpName := cfg.RPName
+	if rpName == "" {
+		rpName = global.Settings.Name
+	}
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: rpName,
+		RPOrigins:     cfg.Origins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: 5 * time.Minute, TimeoutUVD: 5 * time.Minute},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: 5 * time.Minute, TimeoutUVD: 5 * time.Minute},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	global.WebAuthn = w
+}
+
 /*
 * 初始化路由
  */
 func InitRouters() *gin.Engine {
 	Router := gin.Default()
+	// 只信任配置的代理传递的客户端IP，登录失败、验证码按IP计数依赖真实IP
+	// gin默认信任所有代理，没有配置时传nil，不信任任何X-Forwarded-For，直接使用连接的地址
+	if err := Router.SetTrustedProxies(global.Settings.Security.TrustedProxies); err != nil {
+		panic(err)
+	}
 	// 加载自定义中间件
 	Router.Use(middlewares.GinLogger(), middlewares.GinRecovery(true), middlewares.CORSMiddleware())
+	// 本地存储的上传文件由SSO服务直接提供访问
+	if storageDriver() == "local" {
+		Router.Static(localStorageRoute(), localStorageDir())
+	}
 	// 路由分组
 	ApiGroup := Router.Group("/v1/")
 	router.AccountRouter(ApiGroup) // 注册AccountRouter组路由
+	router.AdminRouter(ApiGroup)   // 注册AdminRouter组路由
+	// OAuth2、OIDC相关路由按协议约定挂在根路径下
+	RootGroup := Router.Group("/")
+	router.OAuthRouter(RootGroup)
+	router.OIDCRouter(RootGroup)
+	router.ScimRouter(RootGroup)
 	return Router
 }
 
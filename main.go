@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"sso-go/command"
 	"sso-go/global"
 	"sso-go/initialize"
 )
@@ -21,6 +23,16 @@ func main() {
 	initialize.InitMysqlDB()
 	// 6.初始化redis
 	initialize.InitRedis()
+	// 7.执行命令行子命令，如 ./ssoService client create，执行完直接退出
+	if command.Run(os.Args[1:]) {
+		return
+	}
+	// 8.初始化jwt签名密钥
+	initialize.InitJWT()
+	// 9.初始化WebAuthn
+	initialize.InitWebAuthn()
+	// 10.初始化上传文件存储
+	initialize.InitStorage()
 
 	Router.Run(fmt.Sprintf(":%d", global.Settings.Port))
 }
@@ -0,0 +1,88 @@
+package middlewares
+
+import (
+	"net/http"
+	"sso-go/global"
+	"sso-go/response"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AdminAuth 管理接口鉴权，需要放在JWTAuth之后，只接受登录SSO签发的token，具体权限由各接口的RequirePermission校验
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("claims")
+		claims, ok := value.(*CustomClaims)
+		if !ok || claims.ClientID != "" {
+			response.Err(c, http.StatusOK, 403, "没有权限", "")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// GlobalScope 修改账号、角色等全局数据的接口，不能在选择了组织的token下调用，避免组织管理员影响其他组织
+func GlobalScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("claims")
+		claims, ok := value.(*CustomClaims)
+		if !ok || claims.OrgID != 0 {
+			response.Err(c, http.StatusOK, 403, "请切换到全局后操作", "")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// IsAdmin 是否为env.toml中配置的管理员登录SSO签发的token，管理员拥有所有权限，签发给业务系统的token即使属于管理员也不能调用管理接口
+// 结果记录在claims上，每个请求解析出的claims只查询一次
+func IsAdmin(claims *CustomClaims) bool {
+	if claims.ClientID != "" || claims.ID == 0 {
+		return false
+	}
+	if claims.admin == nil {
+		admin := IsAdminUser(claims.ID)
+		claims.admin = &admin
+	}
+	return *claims.admin
+}
+
+// 已验证的管理员邮箱对应的用户
+// 只按已验证的邮箱匹配：用户名可以随意修改，空出来的管理员用户名会被其他人注册；email_verified_at未验证时为空字符串
+func adminUsers() *gorm.DB {
+	return global.DB.Table("users").Where("email IN ? AND email_verified_at <> ''", global.Settings.Security.Admins)
+}
+
+// AdminUserIDs env.toml中配置的管理员对应的用户ID
+func AdminUserIDs() []uint {
+	if len(global.Settings.Security.Admins) == 0 {
+		return nil
+	}
+	var ids []uint
+	adminUsers().Pluck("id", &ids)
+	return ids
+}
+
+// IsAdminUser 用户是否为env.toml中配置的管理员
+func IsAdminUser(userID uint) bool {
+	if len(global.Settings.Security.Admins) == 0 {
+		return false
+	}
+	var count int64
+	adminUsers().Where("id = ?", userID).Count(&count)
+	return count > 0
+}
+
+// IsAdminEmail 邮箱是否为env.toml中配置的管理员邮箱，只有管理员自己可以把账号的邮箱改成它
+func IsAdminEmail(email string) bool {
+	for _, admin := range global.Settings.Security.Admins {
+		if strings.EqualFold(admin, email) {
+			return true
+		}
+	}
+	return false
+}
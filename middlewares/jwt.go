@@ -2,7 +2,6 @@ package middlewares
 
 import (
 	"errors"
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
 	"go.uber.org/zap"
@@ -14,22 +13,67 @@ import (
 )
 
 type JWT struct {
-	SigningKey []byte
+	Keyring *Keyring
 }
 
+// 客户端凭证签发的服务token，主体是客户端本身
+const TokenUseClient = "client"
+
+// token头部的typ：access_token使用at+jwt（RFC 9068），解析时据此拒绝id_token等其他同一密钥签名的JWT
+const (
+	accessTokenType = "at+jwt"
+	idTokenType     = "JWT"
+)
+
 type CustomClaims struct {
 	ID       uint
 	NickName string
 	Email    string
 	HeadUrl  string
+	ClientID string `json:"client_id,omitempty"` // 通过OAuth授权签发时对应的客户端
+	Scope    string `json:"scope,omitempty"`     // 授权范围，多个用空格分隔
+	AuthTime int64  `json:"auth_time,omitempty"` // 用户实际完成登录认证的时间
+	OrgID    uint   `json:"org_id,omitempty"`    // 当前选择的组织
+	OrgRole  string `json:"org_role,omitempty"`  // 在当前组织中的角色
+	// SSO自身登录签发的token对应的登录会话，退出登录后不能再用来申请授权码
+	SessionID string `json:"sid,omitempty"`
+	// 用户的角色和权限，OAuth授权签发的token只有申请了roles时才包含
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	// 为client时表示客户端凭证签发的服务token，sub为client_id，没有用户信息
+	TokenUse string `json:"token_use,omitempty"`
+	// 签发时间（毫秒），iat只精确到秒，退出登录、修改密码的同一秒内签发的token需要据此区分先后
+	IssuedAtMs int64 `json:"iat_ms,omitempty"`
+	jwt.StandardClaims
+	// 是否为管理员，同一个请求中只查询一次数据库，不写入token
+	admin *bool
+}
+
+// 是否为客户端凭证签发的服务token
+func (c *CustomClaims) IsClientToken() bool {
+	return c.TokenUse == TokenUseClient
+}
+
+// IDTokenClaims OIDC的id_token
+type IDTokenClaims struct {
+	Nonce         string   `json:"nonce,omitempty"`
+	AuthTime      int64    `json:"auth_time,omitempty"`
+	Email         string   `json:"email,omitempty"`
+	EmailVerified bool     `json:"email_verified,omitempty"`
+	Name          string   `json:"name,omitempty"`
+	Picture       string   `json:"picture,omitempty"`
+	Roles         []string `json:"roles,omitempty"`
 	jwt.StandardClaims
 }
 
 var (
-	TokenExpired     = errors.New("Token is expired")
-	TokenNotValidYet = errors.New("Token not active yet")
-	TokenMalformed   = errors.New("That's not even a token")
-	TokenInvalid     = errors.New("Couldn't handle this token:")
+	TokenExpired      = errors.New("Token is expired")
+	TokenNotValidYet  = errors.New("Token not active yet")
+	TokenMalformed    = errors.New("That's not even a token")
+	TokenInvalid      = errors.New("Couldn't handle this token:")
+	TokenKeyRetired   = errors.New("Token signing key is retired")
+	TokenRevoked      = errors.New("Token has been revoked")
+	TokenUserDisabled = errors.New("User has been disabled")
 )
 
 func JWTAuth() gin.HandlerFunc {
@@ -44,10 +88,8 @@ func JWTAuth() gin.HandlerFunc {
 		}
 		// parseToken 解析token包含的信息
 		token := ExtractTokenFromHeader(authorization)
-		fmt.Println(token)
 		j := NewJWT()
 		claims, err := j.ParseToken(token)
-		jwt.TimeFunc = time.Now
 		if err != nil {
 			if err == TokenExpired {
 				if err == TokenExpired {
@@ -56,10 +98,26 @@ func JWTAuth() gin.HandlerFunc {
 					return
 				}
 			}
+			if err == TokenRevoked {
+				response.Err(c, http.StatusOK, 401, "授权已失效，请重新登录", "")
+				c.Abort()
+				return
+			}
+			if err == TokenUserDisabled {
+				response.Err(c, http.StatusOK, 401, "账号已被禁用", "")
+				c.Abort()
+				return
+			}
 			response.Err(c, http.StatusOK, 401, "未登陆", "")
 			c.Abort()
 			return
 		}
+		// 服务token没有用户，不能访问账号相关接口
+		if claims.IsClientToken() {
+			response.Err(c, http.StatusOK, 401, "服务token不能访问该接口", "")
+			c.Abort()
+			return
+		}
 		// gin的上下文记录claims和userId的值
 		c.Set("claims", claims)
 		c.Set("userId", claims.ID)
@@ -67,6 +125,31 @@ func JWTAuth() gin.HandlerFunc {
 	}
 }
 
+// BearerAuth 供OAuth2/OIDC标准接口使用的鉴权中间件，失败时按RFC 6750返回401
+func BearerAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := ExtractTokenFromHeader(c.GetHeader("Authorization"))
+		if token == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="sso"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		j := NewJWT()
+		claims, err := j.ParseToken(token)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]interface{}{
+				"error":             "invalid_token",
+				"error_description": err.Error(),
+			})
+			return
+		}
+		c.Set("claims", claims)
+		c.Set("userId", claims.ID)
+		c.Next()
+	}
+}
+
 // 辅助函数：从 Authorization 头部中提取 Token
 func ExtractTokenFromHeader(authHeader string) string {
 	// Token 应该以 "Bearer " 前缀开始，因此我们可以简单地删除前缀以获取 Token
@@ -75,24 +158,59 @@ func ExtractTokenFromHeader(authHeader string) string {
 
 func NewJWT() *JWT {
 	return &JWT{
-		[]byte(global.Settings.JWTKey.SigningKey),
+		currentKeyring(),
 	}
 }
 
 // 创建一个token
 func (j *JWT) CreateToken(claims CustomClaims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(j.SigningKey)
+	return j.sign(claims, accessTokenType)
+}
+
+// 创建一个id_token
+func (j *JWT) CreateIDToken(claims IDTokenClaims) (string, error) {
+	return j.sign(claims, idTokenType)
+}
+
+// 用密钥环中当前的私钥签名，并在头部写入typ和kid，kid方便验签方选择公钥
+func (j *JWT) sign(claims jwt.Claims, typ string) (string, error) {
+	key := j.Keyring.Current(time.Now())
+	if key == nil {
+		return "", errors.New("没有可用于签名的密钥")
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["typ"] = typ
+	if key.Kid != "" {
+		token.Header["kid"] = key.Kid
+	}
+	return token.SignedString(key.PrivateKey)
+}
+
+// 根据token头部的kid选择验签公钥
+func (j *JWT) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key := j.Keyring.Lookup(kid)
+	if key == nil {
+		return nil, TokenInvalid
+	}
+	if key.Retired(time.Now()) {
+		return nil, TokenKeyRetired
+	}
+	// 只接受该密钥对应的算法，防止用公钥冒充HMAC密钥等算法混淆攻击
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, TokenInvalid
+	}
+	return key.PublicKey, nil
 }
 
 // 解析 token
 func (j *JWT) ParseToken(tokenString string) (*CustomClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (i interface{}, e error) {
-		return j.SigningKey, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, j.keyFunc)
 	if err != nil {
 		if ve, ok := err.(*jwt.ValidationError); ok {
-			if ve.Errors&jwt.ValidationErrorMalformed != 0 {
+			if ve.Inner == TokenKeyRetired {
+				return nil, TokenKeyRetired
+			} else if ve.Errors&jwt.ValidationErrorMalformed != 0 {
 				return nil, TokenMalformed
 			} else if ve.Errors&jwt.ValidationErrorExpired != 0 {
 				// Token is expired
@@ -105,7 +223,18 @@ func (j *JWT) ParseToken(tokenString string) (*CustomClaims, error) {
 		}
 	}
 	if token != nil {
+		// 只接受access_token，id_token虽然由同一密钥签名也不能当作access_token使用
+		if typ, _ := token.Header["typ"].(string); typ != accessTokenType {
+			return nil, TokenInvalid
+		}
 		if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
+			// 已退出登录或被吊销的token
+			if IsTokenRevoked(claims) {
+				return nil, TokenRevoked
+			}
+			if IsUserDisabled(claims.ID) {
+				return nil, TokenUserDisabled
+			}
 			return claims, nil
 		}
 		return nil, TokenInvalid
@@ -114,22 +243,3 @@ func (j *JWT) ParseToken(tokenString string) (*CustomClaims, error) {
 		return nil, TokenInvalid
 	}
 }
-
-// 更新token
-func (j *JWT) RefreshToken(tokenString string) (string, error) {
-	jwt.TimeFunc = func() time.Time {
-		return time.Unix(0, 0)
-	}
-	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return j.SigningKey, nil
-	})
-	if err != nil {
-		return "", err
-	}
-	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
-		jwt.TimeFunc = time.Now
-		claims.StandardClaims.ExpiresAt = time.Now().Add(1 * time.Hour).Unix()
-		return j.CreateToken(*claims)
-	}
-	return "", TokenInvalid
-}
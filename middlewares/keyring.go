@@ -0,0 +1,231 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sso-go/config"
+	"sync"
+	"time"
+)
+
+// Keyring 密钥环：多个密钥同时用于验签，只有一个用于签名
+type Keyring struct {
+	Keys []*SigningKey
+}
+
+// KeyringEntry 密钥环文件中的一个密钥
+type KeyringEntry struct {
+	Kid        string     `json:"kid"`
+	Alg        string     `json:"alg"`
+	PrivateKey string     `json:"privateKey"` // PEM文件路径，相对路径基于密钥环文件所在目录，为空表示使用env.toml中[jwt]配置的密钥
+	ActiveAt   time.Time  `json:"activeAt"`
+	RetireAt   *time.Time `json:"retireAt,omitempty"`
+}
+
+// KeyringFile 密钥环文件
+type KeyringFile struct {
+	Keys []KeyringEntry `json:"keys"`
+}
+
+var (
+	keyringMu      sync.RWMutex
+	keyring        *Keyring
+	keyringConfig  config.JWTConfig
+	keyringModTime time.Time
+)
+
+// Current 当前用于签名的密钥：已启用、未停用的密钥中启用时间最晚的一个
+func (r *Keyring) Current(now time.Time) *SigningKey {
+	var current *SigningKey
+	for _, key := range r.Keys {
+		if !key.Activated(now) || key.Retired(now) {
+			continue
+		}
+		if current == nil || key.ActiveAt.After(current.ActiveAt) {
+			current = key
+		}
+	}
+	return current
+}
+
+// Lookup 根据kid查找密钥
+func (r *Keyring) Lookup(kid string) *SigningKey {
+	for _, key := range r.Keys {
+		if key.Kid == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// Published 需要公开的密钥：所有未停用的密钥，包括尚未启用的，方便业务系统提前缓存公钥
+func (r *Keyring) Published(now time.Time) []*SigningKey {
+	keys := []*SigningKey{}
+	for _, key := range r.Keys {
+		if !key.Retired(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// LoadKeyring 根据配置加载密钥环，没有配置密钥环文件时使用jwt中配置的单个密钥
+func LoadKeyring(cfg config.JWTConfig) error {
+	keyringConfig = cfg
+	if cfg.Keyring != "" {
+		if _, err := os.Stat(cfg.Keyring); err == nil {
+			return ReloadKeyring()
+		}
+	}
+	key, err := ParseConfigKey(cfg)
+	if err != nil {
+		return err
+	}
+	setKeyring(&Keyring{Keys: []*SigningKey{key}})
+	return nil
+}
+
+// ParseConfigKey 解析env.toml中[jwt]配置的单个密钥
+func ParseConfigKey(cfg config.JWTConfig) (*SigningKey, error) {
+	return ParseSigningKey(cfg.Algorithm, cfg.SigningKey, cfg.PrivateKey, cfg.Kid)
+}
+
+// ReloadKeyring 密钥环文件有变化时重新加载，通过命令行轮换密钥后无需重启服务
+func ReloadKeyring() error {
+	keyringPath := keyringConfig.Keyring
+	if keyringPath == "" {
+		return nil
+	}
+	info, err := os.Stat(keyringPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(keyringModTime) {
+		return checkCurrentKey()
+	}
+	file, err := ReadKeyringFile(keyringPath)
+	if err != nil {
+		return err
+	}
+	ring := &Keyring{}
+	for _, entry := range file.Keys {
+		var key *SigningKey
+		if entry.PrivateKey == "" {
+			// 使用[jwt]配置的密钥，kid、alg以密钥环中记录的为准，保证和已签发token头部的kid一致
+			alg := entry.Alg
+			if alg == "" {
+				alg = keyringConfig.Algorithm
+			}
+			key, err = ParseSigningKey(alg, keyringConfig.SigningKey, keyringConfig.PrivateKey, entry.Kid)
+		} else {
+			key, err = ParseSigningKey(entry.Alg, "", resolveKeyPath(keyringPath, entry.PrivateKey), entry.Kid)
+		}
+		if err != nil {
+			return err
+		}
+		key.ActiveAt = entry.ActiveAt
+		if entry.RetireAt != nil {
+			key.RetireAt = *entry.RetireAt
+		}
+		ring.Keys = append(ring.Keys, key)
+	}
+	if ring.Current(time.Now()) == nil {
+		if err := checkCurrentKey(); err != nil {
+			return err
+		}
+		return errors.New("密钥环文件中没有可用于签名的密钥，继续使用之前加载的密钥环")
+	}
+	setKeyring(ring)
+	keyringModTime = info.ModTime()
+	return nil
+}
+
+// 正在使用的密钥环中的签名密钥可能到期停用，此时所有token签发都会失败，返回错误由定时任务持续报警
+func checkCurrentKey() error {
+	if ring := currentKeyring(); ring != nil && ring.Current(time.Now()) == nil {
+		return errors.New("没有可用于签名的密钥，token签发将全部失败，请立即通过 key generate/promote 启用新密钥")
+	}
+	return nil
+}
+
+// ReadKeyringFile 读取密钥环文件，文件不存在时返回空密钥环
+func ReadKeyringFile(path string) (*KeyringFile, error) {
+	file := &KeyringFile{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// WriteKeyringFile 写入密钥环文件，按启用时间排序
+func WriteKeyringFile(path string, file *KeyringFile) error {
+	sort.Slice(file.Keys, func(i, j int) bool {
+		return file.Keys[i].ActiveAt.Before(file.Keys[j].ActiveAt)
+	})
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 先写临时文件再改名，避免服务读到写了一半的文件
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func resolveKeyPath(keyringFile string, keyFile string) string {
+	if filepath.IsAbs(keyFile) {
+		return keyFile
+	}
+	return filepath.Join(filepath.Dir(keyringFile), keyFile)
+}
+
+func setKeyring(ring *Keyring) {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+	keyring = ring
+}
+
+func currentKeyring() *Keyring {
+	keyringMu.RLock()
+	defer keyringMu.RUnlock()
+	return keyring
+}
+
+// JWKS 对外公开的公钥集合
+func JWKS() []map[string]interface{} {
+	keys := []map[string]interface{}{}
+	for _, key := range currentKeyring().Published(time.Now()) {
+		if jwk, ok := key.JWK(); ok {
+			keys = append(keys, jwk)
+		}
+	}
+	return keys
+}
+
+// SigningAlgs 可能出现的签名算法
+func SigningAlgs() []string {
+	algs := []string{}
+	seen := map[string]bool{}
+	for _, key := range currentKeyring().Published(time.Now()) {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
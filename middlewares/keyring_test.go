@@ -0,0 +1,283 @@
+package middlewares
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sso-go/config"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// 生成私钥文件并解析成签名密钥
+func newTestKey(t *testing.T, alg string, kid string) *SigningKey {
+	if alg == "HS256" {
+		key, err := ParseSigningKey(alg, "secret-"+kid, "", kid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	pemBytes, err := GenerateKeyPEM(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), kid+".pem")
+	if err := os.WriteFile(path, pemBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseSigningKey(alg, "", path, kid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// 测试结束后恢复全局密钥环
+func useKeyring(t *testing.T, ring *Keyring) {
+	old, oldConfig, oldModTime := currentKeyring(), keyringConfig, keyringModTime
+	setKeyring(ring)
+	t.Cleanup(func() {
+		setKeyring(old)
+		keyringConfig, keyringModTime = oldConfig, oldModTime
+	})
+}
+
+func TestKeyringCurrent(t *testing.T) {
+	now := time.Now()
+	old := newTestKey(t, "ES256", "old")
+	old.RetireAt = now.Add(time.Hour)
+	current := newTestKey(t, "ES256", "current")
+	current.ActiveAt = now.Add(-time.Hour)
+	next := newTestKey(t, "ES256", "next")
+	next.ActiveAt = now.Add(time.Hour)
+	ring := &Keyring{Keys: []*SigningKey{old, current, next}}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		{"启用时间最晚的密钥", now, "current"},
+		{"新密钥到达启用时间", now.Add(time.Hour), "next"},
+		{"新密钥启用之前", now.Add(-2 * time.Hour), "old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ring.Current(tt.now); got == nil || got.Kid != tt.want {
+				t.Errorf("Current() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// 唯一的密钥停用后没有可用于签名的密钥
+	retired := &Keyring{Keys: []*SigningKey{old}}
+	if got := retired.Current(now.Add(2 * time.Hour)); got != nil {
+		t.Errorf("Current() = %s, want nil", got.Kid)
+	}
+}
+
+func TestKeyringLookup(t *testing.T) {
+	a := newTestKey(t, "HS256", "a")
+	b := newTestKey(t, "ES256", "b")
+	ring := &Keyring{Keys: []*SigningKey{a, b}}
+	if got := ring.Lookup("b"); got != b {
+		t.Errorf("Lookup(b) = %v", got)
+	}
+	if got := ring.Lookup("c"); got != nil {
+		t.Errorf("Lookup(c) = %v, want nil", got)
+	}
+	if got := ring.Lookup(""); got != nil {
+		t.Errorf("Lookup(\"\") = %v, want nil", got)
+	}
+}
+
+func TestKeyFunc(t *testing.T) {
+	now := time.Now()
+	es := newTestKey(t, "ES256", "es")
+	rs := newTestKey(t, "RS256", "rs")
+	retired := newTestKey(t, "ES256", "retired")
+	retired.RetireAt = now.Add(-time.Minute)
+	j := &JWT{Keyring: &Keyring{Keys: []*SigningKey{es, rs, retired}}}
+
+	sign := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		token := jwt.NewWithClaims(method, CustomClaims{StandardClaims: jwt.StandardClaims{ExpiresAt: now.Add(time.Hour).Unix()}})
+		token.Header["typ"] = accessTokenType
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	// 用RSA公钥的PEM当HMAC密钥签名，验证方如果按token头部的alg验签就会通过
+	der, err := x509.MarshalPKIXPublicKey(rs.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	tests := []struct {
+		name    string
+		token   string
+		valid   bool
+		wantErr error // 验签失败时keyFunc返回的错误，nil表示不检查
+	}{
+		{"ES256", sign(jwt.SigningMethodES256, "es", es.PrivateKey), true, nil},
+		{"RS256", sign(jwt.SigningMethodRS256, "rs", rs.PrivateKey), true, nil},
+		{"未知kid", sign(jwt.SigningMethodES256, "unknown", es.PrivateKey), false, TokenInvalid},
+		{"没有kid", sign(jwt.SigningMethodES256, "", es.PrivateKey), false, TokenInvalid},
+		{"已停用的kid", sign(jwt.SigningMethodES256, "retired", retired.PrivateKey), false, TokenKeyRetired},
+		{"算法与kid不符", sign(jwt.SigningMethodPS256, "rs", rs.PrivateKey), false, TokenInvalid},
+		{"用公钥冒充HMAC密钥", sign(jwt.SigningMethodHS256, "rs", rsaPEM), false, TokenInvalid},
+		{"alg为none", sign(jwt.SigningMethodNone, "es", jwt.UnsafeAllowNoneSignatureType), false, TokenInvalid},
+		{"其他密钥签名", sign(jwt.SigningMethodES256, "es", retired.PrivateKey), false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := jwt.ParseWithClaims(tt.token, &CustomClaims{}, j.keyFunc)
+			if (err == nil) != tt.valid {
+				t.Fatalf("err = %v, valid %v", err, tt.valid)
+			}
+			if tt.wantErr != nil {
+				if ve, ok := err.(*jwt.ValidationError); !ok || ve.Inner != tt.wantErr {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			}
+		})
+	}
+
+	// 停用的密钥签发的token在ParseToken中单独报错，方便客户端重新登录
+	if _, err := j.ParseToken(sign(jwt.SigningMethodES256, "retired", retired.PrivateKey)); err != TokenKeyRetired {
+		t.Errorf("ParseToken() err = %v, want %v", err, TokenKeyRetired)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	now := time.Now()
+	hs := newTestKey(t, "HS256", "hs")
+	es := newTestKey(t, "ES384", "es")
+	rs := newTestKey(t, "RS256", "rs")
+	ed := newTestKey(t, "EdDSA", "ed")
+	next := newTestKey(t, "ES256", "next")
+	next.ActiveAt = now.Add(time.Hour)
+	retired := newTestKey(t, "ES256", "retired")
+	retired.RetireAt = now.Add(-time.Minute)
+	useKeyring(t, &Keyring{Keys: []*SigningKey{hs, es, rs, ed, next, retired}})
+
+	// 对称密钥和已停用的密钥不公开，尚未启用的密钥提前公开
+	jwks := map[string]map[string]interface{}{}
+	for _, jwk := range JWKS() {
+		jwks[jwk["kid"].(string)] = jwk
+	}
+	if len(jwks) != 4 || jwks["es"] == nil || jwks["rs"] == nil || jwks["ed"] == nil || jwks["next"] == nil {
+		t.Fatalf("JWKS() = %v", jwks)
+	}
+	for kid, jwk := range jwks {
+		if jwk["use"] != "sig" || jwk["d"] != nil {
+			t.Errorf("%s: %v", kid, jwk)
+		}
+	}
+
+	decode := func(v interface{}) *big.Int {
+		b, err := base64.RawURLEncoding.DecodeString(v.(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return new(big.Int).SetBytes(b)
+	}
+	ecKey := es.PublicKey.(*ecdsa.PublicKey)
+	if jwk := jwks["es"]; jwk["kty"] != "EC" || jwk["crv"] != "P-384" || jwk["alg"] != "ES384" ||
+		decode(jwk["x"]).Cmp(ecKey.X) != 0 || decode(jwk["y"]).Cmp(ecKey.Y) != 0 || len(jwk["x"].(string)) != 64 {
+		t.Errorf("ES384 JWK = %v", jwk)
+	}
+	rsaKey := rs.PublicKey.(*rsa.PublicKey)
+	if jwk := jwks["rs"]; jwk["kty"] != "RSA" || jwk["alg"] != "RS256" ||
+		decode(jwk["n"]).Cmp(rsaKey.N) != 0 || decode(jwk["e"]).Int64() != int64(rsaKey.E) {
+		t.Errorf("RS256 JWK = %v", jwk)
+	}
+	if jwk := jwks["ed"]; jwk["kty"] != "OKP" || jwk["crv"] != "Ed25519" || jwk["alg"] != "EdDSA" ||
+		jwk["x"] != base64.RawURLEncoding.EncodeToString(ed.PublicKey.(ed25519.PublicKey)) {
+		t.Errorf("EdDSA JWK = %v", jwk)
+	}
+
+	algs := map[string]bool{}
+	for _, alg := range SigningAlgs() {
+		if algs[alg] {
+			t.Errorf("SigningAlgs()中%s重复", alg)
+		}
+		algs[alg] = true
+	}
+	if len(algs) != 5 || !algs["HS256"] || !algs["ES384"] || !algs["RS256"] || !algs["EdDSA"] || !algs["ES256"] {
+		t.Errorf("SigningAlgs() = %v", algs)
+	}
+}
+
+func TestReloadKeyring(t *testing.T) {
+	useKeyring(t, nil)
+	dir := t.TempDir()
+	now := time.Now().Truncate(time.Second)
+	for _, kid := range []string{"k1", "k2"} {
+		pemBytes, err := GenerateKeyPEM("ES256")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, kid+".pem"), pemBytes, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	retireAt := now.Add(time.Hour)
+	path := filepath.Join(dir, "keyring.json")
+	if err := WriteKeyringFile(path, &KeyringFile{Keys: []KeyringEntry{
+		{Kid: "k2", Alg: "ES256", PrivateKey: "k2.pem", ActiveAt: now.Add(-time.Minute)},
+		{Kid: "k1", Alg: "ES256", PrivateKey: filepath.Join(dir, "k1.pem"), ActiveAt: now.Add(-time.Hour), RetireAt: &retireAt},
+		{Kid: "config", Alg: "HS256", ActiveAt: now.Add(-2 * time.Hour), RetireAt: &retireAt},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	// 写入时按启用时间排序
+	file, err := ReadKeyringFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Keys[0].Kid != "config" || file.Keys[2].Kid != "k2" {
+		t.Fatalf("密钥环文件 = %+v", file.Keys)
+	}
+
+	if err := LoadKeyring(config.JWTConfig{SigningKey: "secret", Keyring: path}); err != nil {
+		t.Fatal(err)
+	}
+	ring := currentKeyring()
+	if got := ring.Current(time.Now()); got == nil || got.Kid != "k2" {
+		t.Fatalf("Current() = %v, want k2", got)
+	}
+	// 私钥为空的条目使用[jwt]配置的密钥
+	if key := ring.Lookup("config"); key == nil || string(key.PrivateKey.([]byte)) != "secret" || !key.RetireAt.Equal(retireAt) {
+		t.Fatalf("Lookup(config) = %+v", key)
+	}
+
+	// 新的密钥环中没有可用于签名的密钥时保留之前的密钥环
+	past := now.Add(-time.Minute)
+	if err := WriteKeyringFile(path, &KeyringFile{Keys: []KeyringEntry{
+		{Kid: "k1", Alg: "ES256", PrivateKey: "k1.pem", RetireAt: &past},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	keyringModTime = time.Time{}
+	if err := ReloadKeyring(); err == nil {
+		t.Fatal("没有可用于签名的密钥时应返回错误")
+	}
+	if currentKeyring() != ring {
+		t.Fatal("加载失败时不应替换密钥环")
+	}
+}
@@ -0,0 +1,168 @@
+package middlewares
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// SigningKey token签名密钥
+type SigningKey struct {
+	Kid        string
+	Method     jwt.SigningMethod
+	PrivateKey interface{} // 签名用：[]byte、*rsa.PrivateKey、*ecdsa.PrivateKey、ed25519.PrivateKey
+	PublicKey  interface{} // 验签用：HS256与签名密钥相同
+	ActiveAt   time.Time   // 启用时间，之后才能用于签名，零值表示一直启用
+	RetireAt   time.Time   // 停用时间，之后签出的token全部失效，零值表示不停用
+}
+
+// 各ECDSA算法对应的曲线
+var ecdsaCurves = map[string]string{
+	"ES256": "P-256",
+	"ES384": "P-384",
+	"ES512": "P-521",
+}
+
+// ParseSigningKey 解析签名密钥，HS256使用secret，其余算法从PEM文件读取私钥
+func ParseSigningKey(alg string, secret string, privateKeyFile string, kid string) (*SigningKey, error) {
+	if alg == "" {
+		alg = "HS256"
+	}
+	method := jwt.GetSigningMethod(alg)
+	if method == nil || alg == "none" {
+		return nil, fmt.Errorf("不支持的签名算法：%s", alg)
+	}
+	key := &SigningKey{Kid: kid, Method: method}
+	if strings.HasPrefix(alg, "HS") {
+		if secret == "" {
+			return nil, errors.New("jwt.key不得为空")
+		}
+		key.PrivateKey = []byte(secret)
+		key.PublicKey = []byte(secret)
+		return key, nil
+	}
+
+	pemBytes, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取私钥文件失败：%w", err)
+	}
+	switch {
+	case strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS"):
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		key.PrivateKey, key.PublicKey = privateKey, &privateKey.PublicKey
+	case strings.HasPrefix(alg, "ES"):
+		privateKey, err := jwt.ParseECPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		if privateKey.Curve.Params().Name != ecdsaCurves[alg] {
+			return nil, fmt.Errorf("%s需要%s曲线的私钥", alg, ecdsaCurves[alg])
+		}
+		key.PrivateKey, key.PublicKey = privateKey, &privateKey.PublicKey
+	case alg == "EdDSA":
+		privateKey, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		edKey, ok := privateKey.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("EdDSA只支持Ed25519私钥")
+		}
+		key.PrivateKey, key.PublicKey = edKey, edKey.Public()
+	default:
+		return nil, fmt.Errorf("不支持的签名算法：%s", alg)
+	}
+	// 没有配置kid时使用公钥的指纹
+	if key.Kid == "" {
+		der, err := x509.MarshalPKIXPublicKey(key.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(der)
+		key.Kid = base64.RawURLEncoding.EncodeToString(sum[:])[:16]
+	}
+	return key, nil
+}
+
+// JWK 公钥的JWK格式，对称密钥不能公开，返回false
+func (k *SigningKey) JWK() (map[string]interface{}, bool) {
+	jwk := map[string]interface{}{
+		"kid": k.Kid,
+		"alg": k.Method.Alg(),
+		"use": "sig",
+	}
+	switch publicKey := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk["kty"] = "RSA"
+		jwk["n"] = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		jwk["kty"] = "EC"
+		jwk["crv"] = publicKey.Curve.Params().Name
+		jwk["x"] = base64.RawURLEncoding.EncodeToString(publicKey.X.FillBytes(make([]byte, size)))
+		jwk["y"] = base64.RawURLEncoding.EncodeToString(publicKey.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk["kty"] = "OKP"
+		jwk["crv"] = "Ed25519"
+		jwk["x"] = base64.RawURLEncoding.EncodeToString(publicKey)
+	default:
+		return nil, false
+	}
+	return jwk, true
+}
+
+// 是否已到启用时间
+func (k *SigningKey) Activated(now time.Time) bool {
+	return k.ActiveAt.IsZero() || !now.Before(k.ActiveAt)
+}
+
+// 是否已停用
+func (k *SigningKey) Retired(now time.Time) bool {
+	return !k.RetireAt.IsZero() && !now.Before(k.RetireAt)
+}
+
+// GenerateKeyPEM 生成指定算法的私钥，返回PKCS8格式的PEM
+func GenerateKeyPEM(alg string) ([]byte, error) {
+	var privateKey interface{}
+	var err error
+	switch alg {
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ES384":
+		privateKey, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "ES512":
+		privateKey, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case "EdDSA":
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("不支持生成%s密钥", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"net/http"
+	"sso-go/response"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SSO自身管理接口的权限，需要先在permissions表中创建，再分配给角色
+const (
+	PermissionUsersRead   = "sso:users:read"   // 查看用户
+	PermissionUsersWrite  = "sso:users:write"  // 创建、修改、禁用、删除用户
+	PermissionLoginUnlock = "sso:login:unlock" // 解除登录锁定
+	PermissionRolesManage = "sso:roles:manage" // 管理角色、权限及用户的角色
+	PermissionOrgsManage  = "sso:orgs:manage"  // 管理所有组织及其成员
+)
+
+// RequirePermission 要求token拥有全部指定的权限，需要放在JWTAuth之后
+// env.toml中配置的管理员拥有所有权限；token中的权限在签发时确定，角色变更后已签发的access_token会被作废，刷新后生效
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("claims")
+		claims, ok := value.(*CustomClaims)
+		if !ok {
+			response.Err(c, http.StatusOK, 401, "请登录", "")
+			c.Abort()
+			return
+		}
+		for _, permission := range permissions {
+			if !HasPermission(claims, permission) {
+				response.Err(c, http.StatusOK, 403, "没有权限", "")
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// HasPermission token是否拥有指定权限
+func HasPermission(claims *CustomClaims, permission string) bool {
+	for _, p := range claims.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return IsAdmin(claims)
+}
+
+// RequireScope 要求token的scope包含全部指定的scope，需要放在BearerAuth之后
+// 业务系统之间用客户端凭证换取的服务token调用时，按scope保护接口
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("claims")
+		claims, ok := value.(*CustomClaims)
+		if !ok {
+			c.Header("WWW-Authenticate", `Bearer realm="sso"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		granted := strings.Fields(claims.Scope)
+		for _, scope := range scopes {
+			found := false
+			for _, g := range granted {
+				if g == scope {
+					found = true
+					break
+				}
+			}
+			if !found {
+				c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(scopes, " ")+`"`)
+				c.AbortWithStatusJSON(http.StatusForbidden, map[string]interface{}{
+					"error":             "insufficient_scope",
+					"error_description": "token缺少scope：" + scope,
+				})
+				return
+			}
+		}
+		c.Next()
+	}
+}
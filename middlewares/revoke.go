@@ -0,0 +1,104 @@
+package middlewares
+
+import (
+	"fmt"
+	"sso-go/global"
+	"strconv"
+	"time"
+)
+
+func revokedTokenKey(jti string) string {
+	return fmt.Sprintf("RevokedToken:%s", jti)
+}
+
+// RevokeToken 把token的jti加入黑名单，保留到token过期为止
+func RevokeToken(claims *CustomClaims) {
+	RevokeTokenID(claims.Id, claims.ExpiresAt)
+}
+
+// RevokeTokenID 根据jti和过期时间吊销token
+func RevokeTokenID(jti string, expiresAt int64) {
+	if jti == "" {
+		return
+	}
+	ttl := time.Until(time.Unix(expiresAt, 0))
+	if ttl <= 0 {
+		return
+	}
+	global.Redis.Set(revokedTokenKey(jti), 1, ttl)
+}
+
+func tokensValidAfterKey(userID uint) string {
+	return fmt.Sprintf("TokensValidAfter:%d", userID)
+}
+
+// RevokeUserTokens 吊销用户此刻之前签发的所有token，保留到这些token全部过期为止
+// 时间精确到毫秒，同一秒内先签发的token被吊销，之后重新签发的token不受影响
+func RevokeUserTokens(userID uint, accessTTL time.Duration) {
+	global.Redis.Set(tokensValidAfterKey(userID), time.Now().UnixMilli(), accessTTL)
+}
+
+func clientTokensValidAfterKey(userID uint, clientID string) string {
+	return fmt.Sprintf("ClientTokensValidAfter:%d:%s", userID, clientID)
+}
+
+// RevokeUserClientTokens 吊销此刻之前签发给某个客户端的用户token，用户撤销对该客户端的授权后使用
+func RevokeUserClientTokens(userID uint, clientID string, accessTTL time.Duration) {
+	global.Redis.Set(clientTokensValidAfterKey(userID, clientID), time.Now().UnixMilli(), accessTTL)
+}
+
+func userDisabledKey(userID uint) string {
+	return fmt.Sprintf("UserDisabled:%d", userID)
+}
+
+// SetUserDisabled 账号被禁用后已签发的token立即失效，标记保留到这些token全部过期为止，之后的登录、刷新由数据库中的状态拦截
+func SetUserDisabled(userID uint, accessTTL time.Duration) {
+	global.Redis.Set(userDisabledKey(userID), 1, accessTTL)
+}
+
+// ClearUserDisabled 账号恢复后清除禁用标记
+func ClearUserDisabled(userID uint) {
+	global.Redis.Del(userDisabledKey(userID))
+}
+
+// IsUserDisabled token所属账号是否已被禁用
+func IsUserDisabled(userID uint) bool {
+	return global.Redis.Exists(userDisabledKey(userID)).Val() > 0
+}
+
+// IsTokenRevoked token是否已被吊销，redis不可用时无法确认，按已吊销处理
+func IsTokenRevoked(claims *CustomClaims) bool {
+	if claims.Id != "" {
+		n, err := global.Redis.Exists(revokedTokenKey(claims.Id)).Result()
+		if err != nil || n > 0 {
+			return true
+		}
+	}
+	// 服务token没有用户，只能按jti单独吊销
+	if claims.IsClientToken() {
+		return false
+	}
+	keys := []string{tokensValidAfterKey(claims.ID)}
+	if claims.ClientID != "" {
+		keys = append(keys, clientTokensValidAfterKey(claims.ID, claims.ClientID))
+	}
+	values, err := global.Redis.MGet(keys...).Result()
+	if err != nil {
+		return true
+	}
+	// 没有毫秒签发时间的token按所在秒的开始计算，同一秒内吊销时视为已吊销
+	issuedAt := claims.IssuedAtMs
+	if issuedAt == 0 {
+		issuedAt = claims.IssuedAt * 1000
+	}
+	for _, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if validAfter, err := strconv.ParseInt(s, 10, 64); err == nil && issuedAt < validAfter {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package middlewares
+
+import (
+	"sso-go/global"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/golang-jwt/jwt"
+)
+
+func setupRedis(t *testing.T) *miniredis.Miniredis {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	global.Redis = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		global.Redis.Close()
+		mr.Close()
+	})
+	return mr
+}
+
+func userClaims(issuedAt time.Time, clientID string) *CustomClaims {
+	return &CustomClaims{
+		ID:         1,
+		ClientID:   clientID,
+		IssuedAtMs: issuedAt.UnixMilli(),
+		StandardClaims: jwt.StandardClaims{
+			Id:       "jti-" + issuedAt.String(),
+			IssuedAt: issuedAt.Unix(),
+		},
+	}
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	setupRedis(t)
+	before := userClaims(time.Now(), "")
+	time.Sleep(2 * time.Millisecond)
+	RevokeUserTokens(1, time.Hour)
+	time.Sleep(2 * time.Millisecond)
+	after := userClaims(time.Now(), "")
+
+	if !IsTokenRevoked(before) {
+		t.Error("吊销之前签发的token应当失效")
+	}
+	// 同一秒内之后签发的token不受影响
+	if IsTokenRevoked(after) {
+		t.Error("吊销之后签发的token不应失效")
+	}
+	// 没有毫秒签发时间的token，同一秒内的按已吊销处理
+	legacy := userClaims(time.Now(), "")
+	legacy.IssuedAtMs = 0
+	if !IsTokenRevoked(legacy) {
+		t.Error("吊销所在秒签发、没有毫秒时间的token应当失效")
+	}
+	other := userClaims(time.Now().Add(-time.Minute), "")
+	other.ID = 2
+	if IsTokenRevoked(other) {
+		t.Error("其他用户的token不受影响")
+	}
+}
+
+func TestRevokeUserClientTokens(t *testing.T) {
+	setupRedis(t)
+	client1 := userClaims(time.Now(), "client-1")
+	client2 := userClaims(time.Now(), "client-2")
+	sso := userClaims(time.Now(), "")
+	time.Sleep(2 * time.Millisecond)
+	RevokeUserClientTokens(1, "client-1", time.Hour)
+
+	if !IsTokenRevoked(client1) {
+		t.Error("签发给该客户端的token应当失效")
+	}
+	if IsTokenRevoked(client2) || IsTokenRevoked(sso) {
+		t.Error("签发给其他客户端和SSO自身的token不受影响")
+	}
+}
+
+func TestRevokeTokenID(t *testing.T) {
+	mr := setupRedis(t)
+	claims := userClaims(time.Now(), "")
+	claims.ExpiresAt = time.Now().Add(time.Minute).Unix()
+	RevokeToken(claims)
+	if !IsTokenRevoked(claims) {
+		t.Error("按jti吊销的token应当失效")
+	}
+	// 黑名单保留到token过期为止
+	mr.FastForward(2 * time.Minute)
+	if mr.Exists(revokedTokenKey(claims.Id)) {
+		t.Error("token过期后不需要保留黑名单")
+	}
+	// 已过期的token不写入黑名单
+	RevokeTokenID("expired", time.Now().Add(-time.Second).Unix())
+	if mr.Exists(revokedTokenKey("expired")) {
+		t.Error("已过期的token不需要写入黑名单")
+	}
+}
+
+func TestIsTokenRevokedRedisDown(t *testing.T) {
+	mr := setupRedis(t)
+	claims := userClaims(time.Now(), "")
+	if IsTokenRevoked(claims) {
+		t.Fatal("没有吊销的token应当有效")
+	}
+	// redis不可用时无法确认是否吊销，按已吊销处理
+	mr.Close()
+	if !IsTokenRevoked(claims) {
+		t.Error("redis不可用时应当按已吊销处理")
+	}
+	client := &CustomClaims{ClientID: "client-1", TokenUse: TokenUseClient, StandardClaims: jwt.StandardClaims{Id: "jti"}}
+	if !IsTokenRevoked(client) {
+		t.Error("redis不可用时服务token同样按已吊销处理")
+	}
+}
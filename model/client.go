@@ -0,0 +1,58 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Client 接入SSO的业务系统（OAuth2客户端）
+type Client struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	ClientID     string    `json:"client_id"`
+	ClientSecret string    `json:"-"`             // bcrypt加密后的密钥，公开客户端为空
+	PublicKey    string    `json:"public_key"`    // PEM格式的公钥，配置后客户端可以用私钥签名的JWT认证（private_key_jwt）
+	Name         string    `json:"name"`          // 业务系统名称
+	RedirectUris string    `json:"redirect_uris"` // 允许的回调地址，多个用空格分隔
+	Scopes       string    `json:"scopes"`        // 允许申请的scope，多个用空格分隔
+	OrgID        uint      `json:"org_id"`        // 所属组织，为0表示所有用户都可以授权
+	FirstParty   bool      `json:"first_party"`   // 自家业务系统，授权时不需要用户确认
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (Client) TableName() string {
+	return "clients"
+}
+
+// 是否为公开客户端（SPA、移动端等无法保存密钥的客户端）
+func (c *Client) IsPublic() bool {
+	return c.ClientSecret == "" && c.PublicKey == ""
+}
+
+// 回调地址是否在注册列表中（精确匹配）
+func (c *Client) HasRedirectUri(redirectUri string) bool {
+	for _, uri := range strings.Fields(c.RedirectUris) {
+		if uri == redirectUri {
+			return true
+		}
+	}
+	return false
+}
+
+// 申请的scope是否都在允许范围内
+func (c *Client) AllowScopes(scope string) bool {
+	allowed := strings.Fields(c.Scopes)
+	for _, s := range strings.Fields(scope) {
+		found := false
+		for _, a := range allowed {
+			if s == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
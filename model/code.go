@@ -0,0 +1,47 @@
+package model
+
+// AuthCode 授权码记录，存放在redis中，兑换时根据用户ID重新签发token
+type AuthCode struct {
+	UserID      uint   `json:"user_id"`
+	ClientID    string `json:"client_id"`
+	RedirectUri string `json:"redirect_uri"`
+	Scope       string `json:"scope"`
+	IssuedAt    int64  `json:"issued_at"`
+	Nonce       string `json:"nonce,omitempty"`
+	AuthTime    int64  `json:"auth_time"`
+	OrgID       uint   `json:"org_id,omitempty"`
+	CodeChallenge
+}
+
+// AuthCodeTokens 用授权码签发的token，授权码被重复兑换时用来吊销
+type AuthCodeTokens struct {
+	TokenID   string `json:"jti"`
+	ExpiresAt int64  `json:"exp"`
+	FamilyID  string `json:"family_id"`
+}
+
+// PKCE参数（RFC 7636）
+type CodeChallenge struct {
+	Challenge string `json:"code_challenge,omitempty"`
+	Method    string `json:"code_challenge_method,omitempty"`
+}
+
+// 设备授权的状态
+const (
+	DeviceCodePending  = "pending"  // 等待用户输入user_code并确认
+	DeviceCodeApproved = "approved" // 用户已同意，下一次轮询签发token
+	DeviceCodeDenied   = "denied"   // 用户已拒绝
+)
+
+// DeviceCode 设备授权记录（RFC 8628），存放在redis中，用户确认后设备轮询换取token
+type DeviceCode struct {
+	ClientID  string `json:"client_id"`
+	Scope     string `json:"scope"`
+	UserCode  string `json:"user_code"`
+	Status    string `json:"status"`
+	UserID    uint   `json:"user_id,omitempty"`
+	OrgID     uint   `json:"org_id,omitempty"`
+	AuthTime  int64  `json:"auth_time,omitempty"`
+	Interval  int64  `json:"interval"`   // 最小轮询间隔（秒），轮询过快时增加
+	ExpiresAt int64  `json:"expires_at"` // 过期时间戳
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Consent 用户同意第三方客户端访问的授权范围，同一用户和客户端只有一条记录
+type Consent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id"`
+	ClientID  string    `json:"client_id"`
+	Scopes    string    `json:"scopes"` // 已同意的scope，多个用空格分隔
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"` // 最近一次同意的时间
+}
+
+func (Consent) TableName() string {
+	return "consents"
+}
+
+// 申请的scope是否都已同意过
+func (c *Consent) Covers(scope string) bool {
+	granted := strings.Fields(c.Scopes)
+	for _, s := range strings.Fields(scope) {
+		found := false
+		for _, g := range granted {
+			if s == g {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// 申请的scope中还没有同意过的部分
+func (c *Consent) Missing(scope string) []string {
+	missing := []string{}
+	for _, s := range strings.Fields(scope) {
+		if !c.Covers(s) {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+// ConsentTicket 展示授权确认页面时签发的一次性凭证，存放在redis中，确认授权时必须带上
+// 与用户、登录会话、客户端和scope绑定，防止客户端直接带consent=approve跳过确认页面
+type ConsentTicket struct {
+	UserID    uint   `json:"user_id"`
+	SessionID string `json:"sid,omitempty"`
+	ClientID  string `json:"client_id"`
+	Scope     string `json:"scope"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// 组织内的角色
+const (
+	OrgRoleOwner  = "owner"  // 所有者，可以管理所有成员
+	OrgRoleAdmin  = "admin"  // 管理员，可以管理普通成员
+	OrgRoleMember = "member" // 普通成员
+)
+
+// Organization 组织（租户），不同组织之间的用户和客户端互相隔离
+type Organization struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Slug      string    `json:"slug"` // 组织标识，登录时用来指定组织
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+// OrgMember 组织成员，一个用户可以属于多个组织
+type OrgMember struct {
+	OrgID     uint      `json:"org_id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"primaryKey"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (OrgMember) TableName() string {
+	return "org_members"
+}
+
+// 是否可以管理组织成员
+func (m *OrgMember) CanManage() bool {
+	return m.Role == OrgRoleOwner || m.Role == OrgRoleAdmin
+}
+
+// 是否为有效的组织角色
+func IsOrgRole(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleAdmin || role == OrgRoleMember
+}
@@ -0,0 +1,13 @@
+package model
+
+// RefreshToken refresh_token记录，存放在redis中，以token的哈希为key
+type RefreshToken struct {
+	UserID   uint   `json:"user_id"`
+	ClientID string `json:"client_id"` // 为空表示SSO自身登录签发
+	Scope    string `json:"scope"`
+	FamilyID string `json:"family_id"` // 同一次登录轮换出来的refresh_token属于同一个family
+	AuthTime int64  `json:"auth_time"`
+	OrgID    uint   `json:"org_id,omitempty"` // 签发时选择的组织
+	// SSO自身登录签发时对应的登录会话，会话结束后不能再刷新
+	SessionID string `json:"sid,omitempty"`
+}
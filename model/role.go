@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+// Role 角色，name为英文标识，如 admin、editor，会写入token
+type Role struct {
+	ID          uint         `json:"id" gorm:"primaryKey"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+// Permission 权限，name建议用 资源:操作 的格式，如 users:read；sso:开头的是SSO自身管理接口的权限
+type Permission struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (Permission) TableName() string {
+	return "permissions"
+}
+
+// RolePermission 角色拥有的权限
+type RolePermission struct {
+	RoleID       uint `gorm:"primaryKey"`
+	PermissionID uint `gorm:"primaryKey"`
+}
+
+func (RolePermission) TableName() string {
+	return "role_permissions"
+}
+
+// UserRole 用户拥有的角色
+type UserRole struct {
+	UserID uint `gorm:"primaryKey"`
+	RoleID uint `gorm:"primaryKey"`
+}
+
+func (UserRole) TableName() string {
+	return "user_roles"
+}
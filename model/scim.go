@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// ScimToken SCIM同步使用的bearer token，供HR、IT系统自动创建和停用账号
+type ScimToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Name       string     `json:"name"`         // 使用方名称，如 HR系统
+	TokenHash  string     `json:"-"`            // token的sha256，明文只在创建时显示一次
+	LastUsedAt *time.Time `json:"last_used_at"` // 最后一次使用时间
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (ScimToken) TableName() string {
+	return "scim_tokens"
+}
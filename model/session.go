@@ -0,0 +1,11 @@
+package model
+
+// SsoSession SSO自身的浏览器登录会话，存放在redis中，cookie中保存会话ID和密钥
+type SsoSession struct {
+	ID         string `json:"id"`
+	SecretHash string `json:"secret_hash"` // cookie中密钥的哈希，防止redis泄露后会话被直接使用
+	UserID     uint   `json:"user_id"`
+	OrgID      uint   `json:"org_id,omitempty"` // 登录或切换时选择的组织
+	AuthTime   int64  `json:"auth_time"`        // 用户实际完成登录认证的时间
+	CreatedAt  int64  `json:"created_at"`
+}
@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// UserTotp 用户的TOTP两步验证，ConfirmedAt为空表示扫码后还没有确认，不生效
+type UserTotp struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id"`
+	Secret      string     `json:"-"`
+	LastStep    int64      `json:"-"` // 最后一次验证通过的时间步，同一个验证码只能使用一次
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (UserTotp) TableName() string {
+	return "user_totps"
+}
+
+// 是否已经开启两步验证
+func (t *UserTotp) Enabled() bool {
+	return t.ConfirmedAt != nil
+}
+
+// RecoveryCode 两步验证的恢复码，丢失验证器时使用，只保存哈希，每个只能使用一次
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id"`
+	CodeHash  string     `json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (RecoveryCode) TableName() string {
+	return "user_recovery_codes"
+}
+
+// MfaChallenge 密码验证通过、等待两步验证的登录，存放在redis中
+type MfaChallenge struct {
+	UserID  uint     `json:"user_id"`
+	Enroll  bool     `json:"enroll"`  // 必须开启两步验证但还没有绑定的账号，需要先绑定TOTP
+	Methods []string `json:"methods"` // 可以使用的第二因素：totp、webauthn
+	OrgID   uint     `json:"org_id"`  // 登录时指定的组织
+}
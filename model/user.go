@@ -3,16 +3,22 @@ package model
 import "time"
 
 type User struct {
-	ID              uint      `json:"id" gorm:"primaryKey"`
-	Name            string    `json:"name"`
-	Email           string    `json:"email"`
-	HeadUrl         string    `json:"head_url"`
-	EmailVerifiedAt string    `json:"email_verified_at"`
-	Password        string    `json:"password"`
-	CreatedAt       time.Time `json:"created_at" gorm:"type:date"`
-	UpdatedAt       time.Time `json:"updated_at" gorm:"type:date"`
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	HeadUrl         string     `json:"head_url"`
+	EmailVerifiedAt string     `json:"email_verified_at"`
+	Password        string     `json:"password"`
+	DisabledAt      *time.Time `json:"disabled_at"` // 被管理员禁用的时间，为空表示正常
+	CreatedAt       time.Time  `json:"created_at" gorm:"type:date"`
+	UpdatedAt       time.Time  `json:"updated_at" gorm:"type:date"`
 }
 
 func (User) TableName() string {
 	return "users"
 }
+
+// 账号是否已被禁用
+func (u *User) Disabled() bool {
+	return u.DisabledAt != nil
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// WebauthnCredential 用户注册的WebAuthn凭证（通行密钥、安全密钥等）
+type WebauthnCredential struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	UserID          uint       `json:"user_id"`
+	Name            string     `json:"name"`          // 用户自己起的名称，如"办公室电脑"
+	CredentialID    string     `json:"credential_id"` // 凭证ID，base64url编码
+	PublicKey       []byte     `json:"-"`             // COSE格式的公钥
+	AttestationType string     `json:"-"`
+	Transports      string     `json:"transports"` // 支持的传输方式，多个用空格分隔
+	AAGUID          []byte     `json:"-" gorm:"column:aaguid"`
+	SignCount       uint32     `json:"-"` // 签名计数器，用来发现被克隆的认证器
+	BackupEligible  bool       `json:"backup_eligible"`
+	BackupState     bool       `json:"backup_state"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func (WebauthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+// 转换成webauthn库使用的凭证
+func (w *WebauthnCredential) Credential() webauthn.Credential {
+	id, _ := base64.RawURLEncoding.DecodeString(w.CredentialID)
+	transports := []protocol.AuthenticatorTransport{}
+	for _, transport := range strings.Fields(w.Transports) {
+		transports = append(transports, protocol.AuthenticatorTransport(transport))
+	}
+	return webauthn.Credential{
+		ID:              id,
+		PublicKey:       w.PublicKey,
+		AttestationType: w.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: w.BackupEligible,
+			BackupState:    w.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    w.AAGUID,
+			SignCount: w.SignCount,
+		},
+	}
+}
+
+// WebauthnUser 实现webauthn.User接口
+type WebauthnUser struct {
+	User        *User
+	Credentials []WebauthnCredential
+}
+
+// 用户句柄，使用用户ID，不包含邮箱等个人信息
+func WebauthnUserHandle(userID uint) []byte {
+	return []byte(strconv.FormatUint(uint64(userID), 10))
+}
+
+func (u *WebauthnUser) WebAuthnID() []byte {
+	return WebauthnUserHandle(u.User.ID)
+}
+
+func (u *WebauthnUser) WebAuthnName() string {
+	return u.User.Email
+}
+
+func (u *WebauthnUser) WebAuthnDisplayName() string {
+	return u.User.Name
+}
+
+func (u *WebauthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *WebauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.Credentials))
+	for i := range u.Credentials {
+		credentials = append(credentials, u.Credentials[i].Credential())
+	}
+	return credentials
+}
+
+// WebauthnSession 注册、认证过程中的挑战，存放在redis中，只能使用一次
+type WebauthnSession struct {
+	UserID   uint                 `json:"user_id"`
+	Purpose  string               `json:"purpose"`             // register、login（无密码登录）、mfa（两步验证）
+	MfaToken string               `json:"mfa_token,omitempty"` // 两步验证时对应的登录挑战
+	Data     webauthn.SessionData `json:"data"`
+}
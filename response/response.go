@@ -3,6 +3,7 @@ package response
 import (
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strconv"
 )
 
 // 返回成功
@@ -23,3 +24,44 @@ func Err(c *gin.Context, httpCode int, code int, msg string, jsonStr interface{}
 	})
 	return
 }
+
+// 返回OAuth2标准格式的成功响应
+func OAuth(c *gin.Context, data interface{}) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, data)
+}
+
+// 返回OAuth2标准格式的错误响应
+func OAuthErr(c *gin.Context, httpCode int, errCode string, description string) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(httpCode, map[string]interface{}{
+		"error":             errCode,
+		"error_description": description,
+	})
+}
+
+// 返回SCIM 2.0格式的响应，data为nil时只返回状态码（如删除成功的204）
+func Scim(c *gin.Context, httpCode int, data interface{}) {
+	if data == nil {
+		c.Status(httpCode)
+		return
+	}
+	c.Header("Content-Type", "application/scim+json; charset=utf-8")
+	c.JSON(httpCode, data)
+}
+
+// 返回SCIM 2.0格式的错误，scimType为空时省略
+func ScimErr(c *gin.Context, httpCode int, scimType string, detail string) {
+	body := map[string]interface{}{
+		"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:Error"},
+		"status":  strconv.Itoa(httpCode),
+		"detail":  detail,
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	c.Header("Content-Type", "application/scim+json; charset=utf-8")
+	c.AbortWithStatusJSON(httpCode, body)
+}
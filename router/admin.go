@@ -0,0 +1,53 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"sso-go/controller"
+	"sso-go/middlewares"
+)
+
+func AdminRouter(Router *gin.RouterGroup) {
+	AdminRouter := Router.Group("admin", middlewares.JWTAuth(), middlewares.AdminAuth())
+	{
+		usersRead := middlewares.RequirePermission(middlewares.PermissionUsersRead)
+		usersWrite := middlewares.RequirePermission(middlewares.PermissionUsersWrite)
+		rolesManage := middlewares.RequirePermission(middlewares.PermissionRolesManage)
+		orgsManage := middlewares.RequirePermission(middlewares.PermissionOrgsManage)
+		// 修改全局数据的接口不能在选择了组织的token下调用
+		globalScope := middlewares.GlobalScope()
+		// 解除账号或IP的登录锁定
+		AdminRouter.POST("unlock_login", middlewares.RequirePermission(middlewares.PermissionLoginUnlock), controller.UnlockLogin)
+		// 用户列表，按用户名、邮箱、注册日期搜索，游标分页
+		AdminRouter.GET("users", usersRead, controller.ListUsers)
+		// 用户详情
+		AdminRouter.GET("user", usersRead, controller.GetUser)
+		// 创建、修改用户
+		AdminRouter.POST("users/create", usersWrite, controller.CreateUser)
+		AdminRouter.POST("users/update", usersWrite, globalScope, controller.UpdateUser)
+		// 禁用、恢复账号
+		AdminRouter.POST("users/disable", usersWrite, globalScope, controller.DisableUser)
+		AdminRouter.POST("users/enable", usersWrite, globalScope, controller.EnableUser)
+		// 删除用户
+		AdminRouter.POST("users/delete", usersWrite, globalScope, controller.DeleteUser)
+		// 角色管理
+		AdminRouter.GET("roles", rolesManage, controller.ListRoles)
+		AdminRouter.POST("roles/create", rolesManage, globalScope, controller.CreateRole)
+		AdminRouter.POST("roles/update", rolesManage, globalScope, controller.UpdateRole)
+		AdminRouter.POST("roles/delete", rolesManage, globalScope, controller.DeleteRole)
+		// 权限管理
+		AdminRouter.GET("permissions", rolesManage, controller.ListPermissions)
+		AdminRouter.POST("permissions/create", rolesManage, globalScope, controller.CreatePermission)
+		AdminRouter.POST("permissions/delete", rolesManage, globalScope, controller.DeletePermission)
+		// 查看、设置用户的角色
+		AdminRouter.GET("user/roles", rolesManage, controller.GetUserRoles)
+		AdminRouter.POST("user/roles", rolesManage, globalScope, controller.SetUserRoles)
+		// 组织管理
+		AdminRouter.GET("orgs", orgsManage, controller.ListOrgs)
+		AdminRouter.POST("orgs/create", orgsManage, globalScope, controller.CreateOrg)
+		AdminRouter.POST("orgs/delete", orgsManage, globalScope, controller.DeleteOrg)
+		// 组织成员管理，组织的owner、admin也可以管理本组织的成员
+		AdminRouter.GET("orgs/members", controller.ListOrgMembers)
+		AdminRouter.POST("orgs/members/save", controller.SaveOrgMember)
+		AdminRouter.POST("orgs/members/remove", controller.RemoveOrgMember)
+	}
+}
@@ -0,0 +1,36 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"sso-go/controller"
+	"sso-go/middlewares"
+)
+
+func OAuthRouter(Router *gin.RouterGroup) {
+	OAuthRouter := Router.Group("oauth")
+	{
+		// 登录用户为客户端签发授权码，支持Authorization头和登录会话cookie
+		OAuthRouter.GET("authorize", controller.Authorize)
+		OAuthRouter.POST("authorize", controller.Authorize)
+		// 客户端用授权码换取token
+		OAuthRouter.POST("token", controller.Token)
+		// 客户端吊销token
+		OAuthRouter.POST("revoke", controller.Revoke)
+		// 资源服务器内省token
+		OAuthRouter.POST("introspect", controller.Introspect)
+		// 设备授权：设备申请device_code和user_code，登录用户查看并确认授权
+		OAuthRouter.POST("device_authorization", controller.DeviceAuthorization)
+		OAuthRouter.GET("device", middlewares.JWTAuth(), controller.DeviceInfo)
+		OAuthRouter.POST("device", middlewares.JWTAuth(), controller.DeviceDecision)
+	}
+}
+
+func OIDCRouter(Router *gin.RouterGroup) {
+	// OIDC发现文档
+	Router.GET(".well-known/openid-configuration", controller.Discovery)
+	// 签名公钥
+	Router.GET(".well-known/jwks.json", controller.JWKS)
+	// OIDC标准用户信息
+	Router.GET("userinfo", middlewares.BearerAuth(), controller.OIDCUserInfo)
+	Router.POST("userinfo", middlewares.BearerAuth(), controller.OIDCUserInfo)
+}
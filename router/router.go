@@ -15,8 +15,58 @@ func AccountRouter(Router *gin.RouterGroup) {
 		AccountRouter.POST("register", controller.Register)
 		// 登录
 		AccountRouter.POST("login", controller.Login)
+		// 忘记密码，发送重置密码邮件
+		AccountRouter.POST("password/forgot", controller.ForgotPassword)
+		// 通过邮件中的token重置密码
+		AccountRouter.POST("password/reset", controller.ResetPassword)
+		// 修改密码，需要当前密码
+		AccountRouter.POST("password/change", middlewares.JWTAuth(), controller.ChangePassword)
+		// 更换邮箱，先向新邮箱发送验证码，再带验证码和当前密码确认
+		AccountRouter.POST("email/change/code", middlewares.JWTAuth(), controller.SendChangeEmailCode)
+		AccountRouter.POST("email/change", middlewares.JWTAuth(), controller.ChangeEmail)
+		// 登录第二步，用mfa_token和验证码完成两步验证
+		AccountRouter.POST("login/mfa", controller.LoginMfa)
+		// 必须开启两步验证的账号首次登录时绑定TOTP
+		AccountRouter.POST("login/totp/setup", controller.LoginTotpSetup)
+		AccountRouter.POST("login/totp/confirm", controller.LoginTotpConfirm)
+		// WebAuthn无密码登录
+		AccountRouter.POST("webauthn/login/begin", controller.WebauthnLoginBegin)
+		AccountRouter.POST("webauthn/login/finish", controller.WebauthnLoginFinish)
+		// 密码登录后用WebAuthn完成两步验证
+		AccountRouter.POST("login/webauthn/begin", controller.WebauthnMfaBegin)
+		AccountRouter.POST("login/webauthn/finish", controller.WebauthnMfaFinish)
+		// 注册、管理WebAuthn凭证
+		AccountRouter.POST("webauthn/register/begin", middlewares.JWTAuth(), controller.WebauthnRegisterBegin)
+		AccountRouter.POST("webauthn/register/finish", middlewares.JWTAuth(), controller.WebauthnRegisterFinish)
+		AccountRouter.GET("webauthn/credentials", middlewares.JWTAuth(), controller.WebauthnCredentials)
+		AccountRouter.POST("webauthn/credentials/delete", middlewares.JWTAuth(), controller.WebauthnDeleteCredential)
+		// 两步验证状态
+		AccountRouter.GET("totp", middlewares.JWTAuth(), controller.TotpStatus)
+		// 绑定TOTP，获取密钥和二维码
+		AccountRouter.POST("totp/setup", middlewares.JWTAuth(), controller.TotpSetup)
+		// 确认绑定，开启两步验证并返回恢复码
+		AccountRouter.POST("totp/confirm", middlewares.JWTAuth(), controller.TotpConfirm)
+		// 关闭两步验证
+		AccountRouter.POST("totp/disable", middlewares.JWTAuth(), controller.TotpDisable)
+		// 重新生成恢复码
+		AccountRouter.POST("totp/recovery_codes", middlewares.JWTAuth(), controller.RegenerateRecoveryCodes)
+		// 退出登录
+		AccountRouter.POST("logout", middlewares.JWTAuth(), controller.Logout)
 		// 获取用户信息
 		AccountRouter.GET("user", middlewares.JWTAuth(), controller.UserInfo)
+		// 当前用户所属的组织
+		AccountRouter.GET("orgs", middlewares.JWTAuth(), controller.MyOrgs)
+		// 切换组织，返回带新组织的token
+		AccountRouter.POST("org/switch", middlewares.JWTAuth(), controller.SwitchOrg)
+		// 已授权的第三方应用，撤销授权后该应用的token全部失效
+		AccountRouter.GET("consents", middlewares.JWTAuth(), controller.MyConsents)
+		AccountRouter.POST("consents/revoke", middlewares.JWTAuth(), controller.RevokeConsent)
+		// 修改用户名
+		AccountRouter.POST("profile", middlewares.JWTAuth(), controller.UpdateProfile)
+		// 上传头像，multipart表单字段avatar
+		AccountRouter.POST("profile/avatar", middlewares.JWTAuth(), controller.UploadAvatar)
+		// 删除头像，恢复默认头像
+		AccountRouter.POST("profile/avatar/delete", middlewares.JWTAuth(), controller.DeleteAvatar)
 		// 创建授权code
 		AccountRouter.POST("create_code", middlewares.JWTAuth(), controller.CreateCode)
 		// 外部客户端拿code换取token
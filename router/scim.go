@@ -0,0 +1,35 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"sso-go/controller"
+)
+
+func ScimRouter(Router *gin.RouterGroup) {
+	ScimRouter := Router.Group("scim/v2")
+	{
+		// 服务能力、资源类型、schema说明，IdP配置同步时读取
+		ScimRouter.GET("ServiceProviderConfig", controller.ScimServiceProviderConfig)
+		ScimRouter.GET("ResourceTypes", controller.ScimResourceTypes)
+		ScimRouter.GET("ResourceTypes/:id", controller.ScimResourceTypes)
+		ScimRouter.GET("Schemas", controller.ScimSchemas)
+		ScimRouter.GET("Schemas/:id", controller.ScimSchemas)
+	}
+	ScimAuthRouter := ScimRouter.Group("", controller.ScimAuth())
+	{
+		// 用户的创建、查询、修改、删除
+		ScimAuthRouter.GET("Users", controller.ScimListUsers)
+		ScimAuthRouter.POST("Users", controller.ScimCreateUser)
+		ScimAuthRouter.GET("Users/:id", controller.ScimGetUser)
+		ScimAuthRouter.PUT("Users/:id", controller.ScimReplaceUser)
+		ScimAuthRouter.PATCH("Users/:id", controller.ScimPatchUser)
+		ScimAuthRouter.DELETE("Users/:id", controller.ScimDeleteUser)
+		// 组的创建、查询、修改、删除，组对应角色
+		ScimAuthRouter.GET("Groups", controller.ScimListGroups)
+		ScimAuthRouter.POST("Groups", controller.ScimCreateGroup)
+		ScimAuthRouter.GET("Groups/:id", controller.ScimGetGroup)
+		ScimAuthRouter.PUT("Groups/:id", controller.ScimReplaceGroup)
+		ScimAuthRouter.PATCH("Groups/:id", controller.ScimPatchGroup)
+		ScimAuthRouter.DELETE("Groups/:id", controller.ScimDeleteGroup)
+	}
+}
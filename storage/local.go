@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Local 保存在本地磁盘，由SSO服务自身提供静态文件访问，适合单机部署
+type Local struct {
+	Dir     string // 文件保存目录
+	BaseURL string // 对外访问的地址前缀
+}
+
+func NewLocal(dir string, baseURL string) *Local {
+	return &Local{Dir: dir, BaseURL: baseURL}
+}
+
+// 把key转换成磁盘路径，不允许跳出保存目录
+func (l *Local) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "\\") {
+		return "", errors.New("文件路径不合法")
+	}
+	return filepath.Join(l.Dir, filepath.FromSlash(clean)), nil
+}
+
+func (l *Local) Put(key string, data []byte, contentType string) (string, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", err
+	}
+	// 先写临时文件再重命名，访问时不会读到写了一半的文件
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return joinURL(l.BaseURL, key), nil
+}
+
+func (l *Local) Delete(key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) Key(url string) (string, bool) {
+	return trimURL(l.BaseURL, url)
+}
@@ -0,0 +1,29 @@
+package storage
+
+import "strings"
+
+// Storage 上传文件的存储后端，key为相对路径，如 avatars/1/xxx_256.png
+// 接入OSS、S3等对象存储时实现这个接口，并在 initialize.InitStorage 中按driver创建
+type Storage interface {
+	// Put 保存文件，返回对外访问的地址
+	Put(key string, data []byte, contentType string) (string, error)
+	// Delete 删除文件，文件不存在时不报错
+	Delete(key string) error
+	// Key 从对外访问的地址反解出key，不是该存储的文件返回false
+	Key(url string) (string, bool)
+}
+
+// 拼接对外访问的地址
+func joinURL(baseURL string, key string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(key, "/")
+}
+
+// 从对外访问的地址反解出key
+func trimURL(baseURL string, url string) (string, bool) {
+	prefix := strings.TrimSuffix(baseURL, "/") + "/"
+	if baseURL == "" || !strings.HasPrefix(url, prefix) {
+		return "", false
+	}
+	key := strings.TrimPrefix(url, prefix)
+	return key, key != ""
+}
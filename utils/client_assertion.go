@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// 客户端用私钥签名的JWT断言代替密钥认证（RFC 7523，private_key_jwt）
+const ClientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// 断言的最长有效期，客户端应当每次请求都生成新的断言
+const clientAssertionMaxAge = 10 * time.Minute
+
+var (
+	ErrClientPublicKey = errors.New("公钥格式不正确，需要PEM格式的RSA或ECDSA公钥")
+	ErrClientAssertion = errors.New("client_assertion无效")
+)
+
+// ClientAssertion 校验通过的断言，ID和过期时间用来防止重放
+type ClientAssertion struct {
+	ID        string
+	ExpiresAt int64
+}
+
+// 解析客户端注册的PEM公钥，支持RSA和ECDSA
+func ParseClientPublicKey(data string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, ErrClientPublicKey
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, ErrClientPublicKey
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	}
+	return nil, ErrClientPublicKey
+}
+
+// 不验签取出断言中的sub，用来查找客户端
+func ClientAssertionSubject(assertion string) string {
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(assertion, claims); err != nil {
+		return ""
+	}
+	sub, _ := claims["sub"].(string)
+	return sub
+}
+
+// 校验客户端断言：用客户端注册的公钥验签，iss和sub都必须是client_id，aud包含audiences中的一个，
+// 必须带exp和jti，且有效期不超过10分钟
+func VerifyClientAssertion(assertion string, clientID string, publicKey string, audiences []string) (*ClientAssertion, error) {
+	key, err := ParseClientPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(assertion, claims, func(token *jwt.Token) (interface{}, error) {
+		// 只接受与公钥类型匹配的算法，防止算法混淆
+		switch key.(type) {
+		case *rsa.PublicKey:
+			switch token.Method.(type) {
+			case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+				return key, nil
+			}
+		case *ecdsa.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodECDSA); ok {
+				return key, nil
+			}
+		}
+		return nil, ErrClientAssertion
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrClientAssertion
+	}
+	iss, _ := claims["iss"].(string)
+	sub, _ := claims["sub"].(string)
+	jti, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+	if iss != clientID || sub != clientID || jti == "" || exp == 0 {
+		return nil, ErrClientAssertion
+	}
+	if time.Until(time.Unix(int64(exp), 0)) > clientAssertionMaxAge {
+		return nil, ErrClientAssertion
+	}
+	for _, audience := range audiences {
+		if claims.VerifyAudience(audience, true) {
+			return &ClientAssertion{ID: jti, ExpiresAt: int64(exp)}, nil
+		}
+	}
+	return nil, ErrClientAssertion
+}
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func publicKeyPEM(t *testing.T, key crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func signAssertion(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	assertion, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return assertion
+}
+
+func TestVerifyClientAssertion(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPEM := publicKeyPEM(t, &ecKey.PublicKey)
+	rsaPEM := publicKeyPEM(t, &rsaKey.PublicKey)
+
+	const clientID = "client-1"
+	audiences := []string{"https://sso.example.com/oauth/token", "https://sso.example.com"}
+	now := time.Now()
+	claims := func(modify func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss": clientID,
+			"sub": clientID,
+			"aud": audiences[0],
+			"jti": "jti-1",
+			"exp": now.Add(5 * time.Minute).Unix(),
+		}
+		if modify != nil {
+			modify(c)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name      string
+		assertion string
+		publicKey string
+		wantErr   bool
+	}{
+		{"ES256", signAssertion(t, jwt.SigningMethodES256, ecKey, claims(nil)), ecPEM, false},
+		{"RS256", signAssertion(t, jwt.SigningMethodRS256, rsaKey, claims(nil)), rsaPEM, false},
+		{"aud为issuer", signAssertion(t, jwt.SigningMethodES256, ecKey, claims(func(c jwt.MapClaims) {
+			c["aud"] = []string{"https://other.example.com", audiences[1]}
+		})), ecPEM, false},
+		{"其他私钥签名", signAssertion(t, jwt.SigningMethodES256, otherKey, claims(nil)), ecPEM, true},
+		{"算法与公钥类型不匹配", signAssertion(t, jwt.SigningMethodRS256, rsaKey, claims(nil)), ecPEM, true},
+		{"用公钥当HMAC密钥", signAssertion(t, jwt.SigningMethodHS256, []byte(ecPEM), claims(nil)), ecPEM, true},
+		{"alg为none", signAssertion(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, claims(nil)), ecPEM, true},
+		{"iss不是client_id", signAssertion(t, jwt.SigningMethodES256, ecKey, claims(func(c jwt.MapClaims) {
+			c["iss"] = "client-2"
+		})), ecPEM, true},
+		{"sub不是client_id", signAssertion(t, jwt.SigningMethodES256, ecKey, claims(func(c jwt.MapClaims) {
+			c["sub"] = "client-2"
+		})), ecPEM, true},
+		{"没有jti", signAssertion(t, jwt.SigningMethodES256, ecKey, claims(func(c jwt.MapClaims) {
+			delete(c, "jti")
+		})), ecPEM, true},
+		{"没有exp", signAssertion(t, jwt.SigningMethodES256, ecKey, claims(func(c jwt.MapClaims) {
+			delete(c, "exp")
+		})), ecPEM, true},
+		{"已过期", signAssertion(t, jwt.SigningMethodES256, ecKey, claims(func(c jwt.MapClaims) {
+			c["exp"] = now.Add(-time.Minute).Unix()
+		})), ecPEM, true},
+		{"有效期超过10分钟", signAssertion(t, jwt.SigningMethodES256, ecKey, claims(func(c jwt.MapClaims) {
+			c["exp"] = now.Add(time.Hour).Unix()
+		})), ecPEM, true},
+		{"aud不匹配", signAssertion(t, jwt.SigningMethodES256, ecKey, claims(func(c jwt.MapClaims) {
+			c["aud"] = "https://other.example.com"
+		})), ecPEM, true},
+		{"没有aud", signAssertion(t, jwt.SigningMethodES256, ecKey, claims(func(c jwt.MapClaims) {
+			delete(c, "aud")
+		})), ecPEM, true},
+		{"公钥格式错误", signAssertion(t, jwt.SigningMethodES256, ecKey, claims(nil)), "not a pem", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := VerifyClientAssertion(tt.assertion, clientID, tt.publicKey, audiences)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyClientAssertion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (result.ID != "jti-1" || result.ExpiresAt != now.Add(5*time.Minute).Unix()) {
+				t.Errorf("VerifyClientAssertion() = %+v", result)
+			}
+		})
+	}
+}
+
+func TestParseClientPublicKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseClientPublicKey(publicKeyPEM(t, &ecKey.PublicKey)); err != nil {
+		t.Errorf("ECDSA公钥解析失败：%v", err)
+	}
+	// 私钥、非PEM格式和内容错误的公钥都不接受
+	der, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		"私钥":   string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})),
+		"非PEM": "not a pem",
+		"空":    "",
+		"内容错误": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("bad")})),
+	} {
+		if _, err := ParseClientPublicKey(data); err != ErrClientPublicKey {
+			t.Errorf("%s：err = %v, want %v", name, err, ErrClientPublicKey)
+		}
+	}
+}
+
+func TestClientAssertionSubject(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion := signAssertion(t, jwt.SigningMethodES256, ecKey, jwt.MapClaims{"sub": "client-1"})
+	if got := ClientAssertionSubject(assertion); got != "client-1" {
+		t.Errorf("ClientAssertionSubject() = %q, want %q", got, "client-1")
+	}
+	if got := ClientAssertionSubject("not a jwt"); got != "" {
+		t.Errorf("ClientAssertionSubject() = %q, want empty", got)
+	}
+}
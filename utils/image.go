@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"net/http"
+	"sso-go/global"
+	"sso-go/model"
+)
+
+// 解码前限制图片像素数，防止很小的文件解压出超大图片耗尽内存
+const maxImagePixels = 4096 * 4096
+
+var (
+	ErrImageType = errors.New("只支持jpg、png、gif格式的图片")
+	ErrImageSize = errors.New("图片尺寸过大")
+)
+
+// 允许上传的图片类型，按文件内容判断，不信任文件名和Content-Type
+var allowedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// 校验并解码上传的图片，gif只取第一帧
+func DecodeImage(data []byte) (image.Image, error) {
+	if !allowedImageTypes[http.DetectContentType(data)] {
+		return nil, ErrImageType
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrImageType
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return nil, ErrImageSize
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrImageType
+	}
+	return img, nil
+}
+
+// 居中裁剪成正方形并缩放到size×size，缩小时取区域内像素的平均值
+func SquareThumbnail(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	// 先转换成RGBA，后面直接读写像素数组
+	src := image.NewRGBA(image.Rect(0, 0, side, side))
+	offset := image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2)
+	draw.Draw(src, src.Bounds(), img, offset, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for dy := 0; dy < size; dy++ {
+		y0, y1 := dy*side/size, (dy+1)*side/size
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for dx := 0; dx < size; dx++ {
+			x0, x1 := dx*side/size, (dx+1)*side/size
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var sum [4]int
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride+x0*4 : y*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			p := dst.Pix[dy*dst.Stride+dx*4:]
+			for i := 0; i < 4; i++ {
+				p[i] = uint8(sum[i] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// 编码为png，重新编码会去掉原图中的EXIF等元数据
+func EncodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 用户头像地址，没有上传头像时使用配置的默认头像
+func UserHeadUrl(user *model.User) string {
+	if user.HeadUrl != "" {
+		return user.HeadUrl
+	}
+	if global.Settings.Avatar.Default != "" {
+		return global.Settings.Avatar.Default
+	}
+	return "http://resource.djp.org.cn/images/head_default.png"
+}
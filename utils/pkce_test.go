@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// RFC 7636 附录B的示例
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		method    string
+		want      bool
+	}{
+		{"S256", verifier, challenge, "S256", true},
+		{"S256不匹配", verifier, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cN", "S256", false},
+		{"plain", verifier, verifier, "plain", true},
+		{"method为空按plain", verifier, verifier, "", true},
+		{"plain不匹配", verifier, challenge, "plain", false},
+		{"S256的challenge按plain校验", verifier, challenge, "", false},
+		{"不支持的method", verifier, verifier, "S512", false},
+		{"verifier太短", "abc", "abc", "plain", false},
+		{"verifier太长", strings.Repeat("a", 129), strings.Repeat("a", 129), "plain", false},
+		{"verifier有非法字符", strings.Repeat("a", 42) + "+", strings.Repeat("a", 42) + "+", "plain", false},
+		{"verifier为空", "", "", "plain", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyPKCE(tt.verifier, tt.challenge, tt.method); got != tt.want {
+				t.Errorf("VerifyPKCE(%q, %q, %q) = %v, want %v", tt.verifier, tt.challenge, tt.method, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPKCEValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{strings.Repeat("a", 43), true},
+		{strings.Repeat("a", 128), true},
+		{"abc-._~ABC012" + strings.Repeat("x", 30), true},
+		{strings.Repeat("a", 42), false},
+		{strings.Repeat("a", 129), false},
+		{strings.Repeat("a", 42) + "=", false},
+		{strings.Repeat("a", 42) + " ", false},
+	}
+	for _, tt := range tests {
+		if got := IsPKCEValue(tt.value); got != tt.want {
+			t.Errorf("IsPKCEValue(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package utils
+
+import "testing"
+
+func TestMatchRedirectPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		target  string
+		want    bool
+	}{
+		{"完全相同", "https://app.example.com/cb", "https://app.example.com/cb", true},
+		{"子路径", "https://app.example.com/cb", "https://app.example.com/cb/next?x=1", true},
+		{"规则没有路径", "https://app.example.com", "https://app.example.com/any", true},
+		{"路径前缀不是目录", "https://app.example.com/cb", "https://app.example.com/cb-evil", false},
+		{"通配子域名", "https://*.example.com/", "https://a.b.example.com/cb", true},
+		{"通配不匹配根域名", "https://*.example.com/", "https://example.com/cb", false},
+		{"通配不匹配相似域名", "https://*.example.com/", "https://evilexample.com/cb", false},
+		{"域名大小写", "https://app.example.com/", "https://APP.Example.com/cb", true},
+		{"协议不同", "https://app.example.com/", "http://app.example.com/cb", false},
+		{"端口不同", "https://app.example.com/", "https://app.example.com:8443/cb", false},
+		{"携带用户信息", "https://app.example.com/", "https://user@app.example.com/cb", false},
+		{"携带fragment", "https://app.example.com/", "https://app.example.com/cb#x", false},
+		{"上级目录", "https://app.example.com/cb", "https://app.example.com/cb/../evil", false},
+		{"当前目录", "https://app.example.com/cb", "https://app.example.com/cb/./next", false},
+		{"编码的上级目录", "https://app.example.com/cb", "https://app.example.com/cb/%2e%2e/evil", false},
+		{"大写编码的上级目录", "https://app.example.com/cb", "https://app.example.com/cb/%2E%2E/evil", false},
+		{"编码的斜杠", "https://app.example.com/cb", "https://app.example.com/cb/..%2fevil", false},
+		{"反斜杠", "https://app.example.com/cb", `https://app.example.com/cb/..\evil`, false},
+		{"包含点的文件名", "https://app.example.com/cb", "https://app.example.com/cb/..name/v1.0", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchRedirectPattern(tt.pattern, tt.target); got != tt.want {
+				t.Errorf("MatchRedirectPattern(%q, %q) = %v, want %v", tt.pattern, tt.target, got, tt.want)
+			}
+		})
+	}
+}
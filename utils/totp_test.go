@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+// RFC 6238附录B的测试密钥"12345678901234567890"（SHA1）
+const rfcTotpSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTotpCode(t *testing.T) {
+	// RFC 6238附录B的测试向量，取8位验证码的后6位
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := TotpCode(rfcTotpSecret, tt.unix/totpPeriod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("TotpCode(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+	// 密钥不区分大小写
+	if got, _ := TotpCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 59/totpPeriod); got != "287082" {
+		t.Errorf("小写密钥 TotpCode() = %s, want 287082", got)
+	}
+	if _, err := TotpCode("not base32!", 1); err == nil {
+		t.Error("密钥格式错误时应返回错误")
+	}
+}
+
+func TestVerifyTotp(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+	code := func(step int64) string {
+		c, err := TotpCode(rfcTotpSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOk   bool
+	}{
+		{"当前时间步", code(current), current, true},
+		{"慢一个时间步", code(current - 1), current - 1, true},
+		{"快一个时间步", code(current + 1), current + 1, true},
+		{"慢两个时间步", code(current - 2), 0, false},
+		{"快两个时间步", code(current + 2), 0, false},
+		{"位数不对", code(current)[:5], 0, false},
+		{"空", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := VerifyTotp(rfcTotpSecret, tt.code, now)
+			if ok != tt.wantOk || step != tt.wantStep {
+				t.Errorf("VerifyTotp() = %d, %v, want %d, %v", step, ok, tt.wantStep, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestIsTotpCode(t *testing.T) {
+	for code, want := range map[string]bool{
+		"123456":              true,
+		"12345":               false,
+		"1234567":             false,
+		"12345a":              false,
+		"abcd-efgh-ijkl-mnop": false,
+	} {
+		if got := IsTotpCode(code); got != want {
+			t.Errorf("IsTotpCode(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes := GenerateRecoveryCodes(10)
+	if len(codes) != 10 {
+		t.Fatalf("生成了%d个恢复码，want 10", len(codes))
+	}
+	format := regexp.MustCompile(`^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$`)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("恢复码格式错误：%s", code)
+		}
+		if seen[code] {
+			t.Errorf("恢复码重复：%s", code)
+		}
+		seen[code] = true
+		// 恢复码不能被当成TOTP验证码
+		if IsTotpCode(code) {
+			t.Errorf("恢复码被识别为TOTP验证码：%s", code)
+		}
+	}
+
+	// 用户输入时忽略大小写、空格和连字符
+	hash := HashRecoveryCode("abcd-efgh-ijkl-mnop")
+	for _, input := range []string{"ABCD-EFGH-IJKL-MNOP", "abcdefghijklmnop", "abcd efgh ijkl mnop"} {
+		if HashRecoveryCode(input) != hash {
+			t.Errorf("HashRecoveryCode(%q)与原恢复码不一致", input)
+		}
+	}
+	if HashRecoveryCode("abcd-efgh-ijkl-mnoq") == hash {
+		t.Error("不同的恢复码哈希相同")
+	}
+}
@@ -1,7 +1,11 @@
 package utils
 
 import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -10,13 +14,16 @@ import (
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 	"log"
-	"math/rand"
+	"math/big"
 	"net/http"
 	"net/smtp"
+	"net/url"
 	"regexp"
 	"sso-go/global"
 	"sso-go/middlewares"
+	"sso-go/model"
 	"sso-go/response"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -103,15 +110,8 @@ func ComparePasswords(hashedPwd string, plainPwd string) bool {
 
 // 发送邮箱验证码，支持群发
 func SendEmailValidate(em []string) (string, error) {
-	e := email.NewEmail()
-	//服务器相关的配置
-	emailConfig := global.Settings.EmailInfo
-	e.Subject = "邮箱验证"
-	e.From = fmt.Sprintf("%s <%s>", emailConfig.SendName, emailConfig.SendEmail)
-	e.To = em
 	// 生成6位随机验证码
-	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
-	vCode := fmt.Sprintf("%06v", rnd.Int31n(1000000))
+	vCode := GenerateDigitCode(6)
 	t := time.Now().Format("2006-01-02 15:04:05")
 	//设置文件发送的内容
 	content := fmt.Sprintf(`
@@ -127,43 +127,263 @@ func SendEmailValidate(em []string) (string, error) {
 		</div>
 	</div>
 	`, em[0], t, vCode)
-	e.HTML = []byte(content)
-	global.Lg.Info("log email info ", zap.Any("emailInfo", emailConfig))
-	err := e.Send(emailConfig.Address, smtp.PlainAuth("", emailConfig.SendEmail, emailConfig.Password, emailConfig.Host))
+	err := SendEmail(em, "邮箱验证", content)
 	return vCode, err
 }
 
-// 生成jwt的token
-func CreateToken(c *gin.Context, Id uint, NickName string, Email string, HeadUrl string) string {
-	//生成token信息
+// 发送重置密码邮件，link为带token的重置页面地址
+func SendPasswordResetEmail(to string, link string, expire time.Duration) error {
+	t := time.Now().Format("2006-01-02 15:04:05")
+	content := fmt.Sprintf(`
+	<div>
+		<div>
+			尊敬的%s，您好！
+		</div>
+		<div style="padding: 8px 40px 8px 50px;">
+			<p>您于 %s 申请重置密码，请点击下面的链接设置新密码，链接%d分钟内有效且只能使用一次：</p>
+			<p><a href="%s">%s</a></p>
+			<p>如果不是本人操作，请忽略这封邮件，您的密码不会被修改。</p>
+		</div>
+		<div>
+			<p>此邮箱为系统邮箱，请勿回复。</p>
+		</div>
+	</div>
+	`, to, t, int(expire.Minutes()), link, link)
+	return SendEmail([]string{to}, "重置密码", content)
+}
+
+// 发送账号安全通知邮件，如密码、邮箱被修改
+func SendSecurityNoticeEmail(to string, notice string) error {
+	t := time.Now().Format("2006-01-02 15:04:05")
+	content := fmt.Sprintf(`
+	<div>
+		<div>
+			尊敬的%s，您好！
+		</div>
+		<div style="padding: 8px 40px 8px 50px;">
+			<p>您的账号于 %s %s。</p>
+			<p>如果不是本人操作，请立即重置密码并联系管理员。</p>
+		</div>
+		<div>
+			<p>此邮箱为系统邮箱，请勿回复。</p>
+		</div>
+	</div>
+	`, to, t, notice)
+	return SendEmail([]string{to}, "账号安全通知", content)
+}
+
+// 发送HTML邮件
+func SendEmail(to []string, subject string, html string) error {
+	e := email.NewEmail()
+	//服务器相关的配置
+	emailConfig := global.Settings.EmailInfo
+	e.Subject = subject
+	e.From = fmt.Sprintf("%s <%s>", emailConfig.SendName, emailConfig.SendEmail)
+	e.To = to
+	e.HTML = []byte(html)
+	global.Lg.Info("log email info ", zap.Any("host", emailConfig.Address), zap.Any("from", emailConfig.SendEmail), zap.Any("subject", subject))
+	return e.Send(emailConfig.Address, smtp.PlainAuth("", emailConfig.SendEmail, emailConfig.Password, emailConfig.Host))
+}
+
+// 生成n位数字验证码，使用crypto/rand保证不可预测
+func GenerateDigitCode(n int) string {
+	digits := make([]byte, n)
+	for i := range digits {
+		d, err := crand.Int(crand.Reader, big.NewInt(10))
+		if err != nil {
+			panic(err)
+		}
+		digits[i] = byte('0' + d.Int64())
+	}
+	return string(digits)
+}
+
+// 设备授权的user_code字符集，去掉元音和容易混淆的字符（RFC 8628 6.1）
+const userCodeCharset = "BCDFGHJKLMNPQRSTVWXZ"
+
+// 生成8位设备授权user_code，使用crypto/rand保证不可预测
+func GenerateUserCode() string {
+	code := make([]byte, 8)
+	for i := range code {
+		d, err := crand.Int(crand.Reader, big.NewInt(int64(len(userCodeCharset))))
+		if err != nil {
+			panic(err)
+		}
+		code[i] = userCodeCharset[d.Int64()]
+	}
+	return string(code)
+}
+
+// access_token有效期（秒），默认15分钟
+func AccessTokenExpireSeconds() int64 {
+	if global.Settings.JWTKey.AccessTTL > 0 {
+		return global.Settings.JWTKey.AccessTTL
+	}
+	return 60 * 15
+}
+
+// refresh_token有效期（秒），默认30天
+func RefreshTokenExpireSeconds() int64 {
+	if global.Settings.JWTKey.RefreshTTL > 0 {
+		return global.Settings.JWTKey.RefreshTTL
+	}
+	return 60 * 60 * 24 * 30
+}
+
+// SSO登录会话有效期（秒），默认与refresh_token相同
+func SessionExpireSeconds() int64 {
+	if global.Settings.Session.MaxAge > 0 {
+		return global.Settings.Session.MaxAge
+	}
+	return RefreshTokenExpireSeconds()
+}
+
+// 补全token的标准字段并签名，返回token和过期时间戳
+func SignToken(claims middlewares.CustomClaims) (string, int64, error) {
+	j := middlewares.NewJWT()
+	issuedAt := time.Now()
+	now := issuedAt.Unix()
+	if claims.AuthTime == 0 {
+		claims.AuthTime = now
+	}
+	claims.IssuedAtMs = issuedAt.UnixMilli()
+	jti := claims.Id
+	if jti == "" {
+		jti = GenerateHexCode(16)
+	}
+	claims.StandardClaims = jwt.StandardClaims{
+		Subject:   claims.Subject,
+		NotBefore: now,
+		IssuedAt:  now,
+		ExpiresAt: now + AccessTokenExpireSeconds(),
+		Issuer:    global.Settings.Issuer,
+		Id:        jti,
+	}
+	token, err := j.CreateToken(claims)
+	return token, claims.ExpiresAt, err
+}
+
+// 签发OIDC的id_token，按scope决定携带哪些用户信息
+// roles为用户的角色，scope中包含roles时写入
+func SignIDToken(user *model.User, roles []string, clientID string, scope string, nonce string, authTime int64) (string, error) {
 	j := middlewares.NewJWT()
-	claims := middlewares.CustomClaims{
-		ID:       Id,
-		NickName: NickName,
-		Email:    Email,
-		HeadUrl:  HeadUrl,
+	now := time.Now().Unix()
+	claims := middlewares.IDTokenClaims{
+		Nonce:    nonce,
+		AuthTime: authTime,
 		StandardClaims: jwt.StandardClaims{
-			NotBefore: time.Now().Unix(),
-			// TODO 设置token过期时间
-			ExpiresAt: time.Now().Unix() + 60*60*24*7, //token -->7天过期
-			Issuer:    "test",
+			Subject:   strconv.Itoa(int(user.ID)),
+			Audience:  clientID,
+			IssuedAt:  now,
+			ExpiresAt: now + AccessTokenExpireSeconds(),
+			Issuer:    global.Settings.Issuer,
+			Id:        GenerateHexCode(16),
 		},
 	}
-	//生成token
-	token, err := j.CreateToken(claims)
-	if err != nil {
-		response.Success(c, 401, "token生成失败,重新再试", "test")
-		return ""
+	if HasScope(scope, "email") {
+		claims.Email = user.Email
+		claims.EmailVerified = user.EmailVerifiedAt != ""
+	}
+	if HasScope(scope, "profile") {
+		claims.Name = user.Name
+		claims.Picture = UserHeadUrl(user)
+	}
+	if HasScope(scope, "roles") {
+		claims.Roles = roles
+	}
+	return j.CreateIDToken(claims)
+}
+
+// scope列表中是否包含指定的scope
+func HasScope(scope string, target string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == target {
+			return true
+		}
 	}
-	return token
+	return false
 }
 
 // 随机生成一个code码
 func GenerateCode() string {
 	token := make([]byte, 32)
-	_, err := rand.Read(token)
+	_, err := crand.Read(token)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// 随机生成一个n字节的16进制字符串
+func GenerateHexCode(n int) string {
+	token := make([]byte, n)
+	_, err := crand.Read(token)
 	if err != nil {
 		panic(err)
 	}
-	return base64.URLEncoding.EncodeToString(token)
+	return hex.EncodeToString(token)
+}
+
+// 校验PKCE的code_verifier与code_challenge是否匹配，method为空时按plain处理
+func VerifyPKCE(verifier string, challenge string, method string) bool {
+	if !IsPKCEValue(verifier) {
+		return false
+	}
+	switch method {
+	case "S256":
+		sum := sha256.Sum256([]byte(verifier))
+		return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+	case "", "plain":
+		return subtle.ConstantTimeCompare([]byte(verifier), []byte(challenge)) == 1
+	default:
+		return false
+	}
+}
+
+// 校验code_verifier/code_challenge格式：43到128位的[A-Za-z0-9-._~]
+func IsPKCEValue(value string) bool {
+	result, _ := regexp.MatchString(`^[A-Za-z0-9\-._~]{43,128}$`, value)
+	return result
+}
+
+// 回调地址是否匹配通配规则，规则的host以*.开头时匹配其所有子域名
+// 协议、端口必须一致，路径按目录前缀匹配，不允许携带用户信息、fragment和 . .. 路径段
+func MatchRedirectPattern(pattern string, target string) bool {
+	p, err := url.Parse(pattern)
+	if err != nil {
+		return false
+	}
+	t, err := url.Parse(target)
+	if err != nil || t.User != nil || t.Fragment != "" {
+		return false
+	}
+	if p.Scheme != t.Scheme || p.Port() != t.Port() {
+		return false
+	}
+	host := strings.ToLower(t.Hostname())
+	patternHost := strings.ToLower(p.Hostname())
+	if strings.HasPrefix(patternHost, "*.") {
+		if !strings.HasSuffix(host, patternHost[1:]) {
+			return false
+		}
+	} else if host != patternHost {
+		return false
+	}
+	// /cb/../evil 按前缀能匹配 /cb，但浏览器会跳到 /evil，解码后（%2e%2e同样是..）出现 . .. 路径段一律拒绝
+	if hasDotSegment(t.Path) {
+		return false
+	}
+	// 路径按目录前缀匹配，/cb 不能匹配 /cb-evil
+	patternPath, targetPath := p.EscapedPath(), t.EscapedPath()
+	return patternPath == "" || targetPath == patternPath || strings.HasPrefix(targetPath, strings.TrimSuffix(patternPath, "/")+"/")
+}
+
+// 路径中是否有 . 或 .. 路径段，部分服务端把反斜杠当作分隔符，一并按分隔符处理
+func hasDotSegment(p string) bool {
+	for _, seg := range strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if seg == "." || seg == ".." {
+			return true
+		}
+	}
+	return false
 }